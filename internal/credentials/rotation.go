@@ -0,0 +1,117 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubeerrs "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// credentialsFileConfigKey is the config key object store plugins read the path of their credentials file from.
+const credentialsFileConfigKey = "credentialsFile"
+
+// ConfigUpdater is implemented by plugins, such as object stores, which can be reinitialized with a new config
+// while they are in use.
+type ConfigUpdater interface {
+	UpdateConfig(ctx context.Context, config map[string]string) error
+}
+
+// Rotator keeps track of the plugins using credentials from each secret, so that they can be reinitialized with
+// fresh credentials when the secret changes instead of failing until they're recreated.
+type Rotator struct {
+	store FileStore
+
+	lock    sync.Mutex
+	nextID  int
+	plugins map[types.NamespacedName]map[int]*rotatedPlugin
+}
+
+// rotatedPlugin is a plugin registered with a Rotator.
+type rotatedPlugin struct {
+	selector *corev1api.SecretKeySelector
+	updater  ConfigUpdater
+	config   map[string]string
+}
+
+// NewRotator returns a Rotator which rewrites rotated credentials to store.
+func NewRotator(store FileStore) *Rotator {
+	return &Rotator{
+		store:   store,
+		plugins: make(map[types.NamespacedName]map[int]*rotatedPlugin),
+	}
+}
+
+// Register makes r reinitialize updater with config when the secret key defined by selector in namespace changes,
+// with the path of the credentials file updated. The returned function unregisters updater, and must be called once
+// it's no longer in use.
+func (r *Rotator) Register(namespace string, selector *corev1api.SecretKeySelector, updater ConfigUpdater, config map[string]string) func() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	secret := types.NamespacedName{Namespace: namespace, Name: selector.Name}
+	id := r.nextID
+	r.nextID++
+	if r.plugins[secret] == nil {
+		r.plugins[secret] = make(map[int]*rotatedPlugin)
+	}
+	r.plugins[secret][id] = &rotatedPlugin{selector: selector, updater: updater, config: config}
+
+	return func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+
+		delete(r.plugins[secret], id)
+		if len(r.plugins[secret]) == 0 {
+			delete(r.plugins, secret)
+		}
+	}
+}
+
+// Rotate rewrites the credentials from secret and reinitializes the plugins using them.
+func (r *Rotator) Rotate(ctx context.Context, secret types.NamespacedName) error {
+	r.lock.Lock()
+	plugins := make([]*rotatedPlugin, 0, len(r.plugins[secret]))
+	for _, plugin := range r.plugins[secret] {
+		plugins = append(plugins, plugin)
+	}
+	r.lock.Unlock()
+
+	var errs []error
+	for _, plugin := range plugins {
+		path, err := r.store.Path(plugin.selector)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		config := make(map[string]string, len(plugin.config)+1)
+		for k, v := range plugin.config {
+			config[k] = v
+		}
+		config[credentialsFileConfigKey] = path
+
+		if err := plugin.updater.UpdateConfig(ctx, config); err != nil {
+			errs = append(errs, errors.Wrapf(err, "unable to reinitialize plugin with rotated credentials from secret %s", secret))
+		}
+	}
+	return kubeerrs.NewAggregate(errs)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package credentials
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vmware-tanzu/velero/pkg/builder"
+	velerotest "github.com/vmware-tanzu/velero/pkg/test"
+)
+
+type recordingConfigUpdater struct {
+	configs []map[string]string
+	err     error
+}
+
+func (u *recordingConfigUpdater) UpdateConfig(ctx context.Context, config map[string]string) error {
+	u.configs = append(u.configs, config)
+	return u.err
+}
+
+func TestRotator(t *testing.T) {
+	client := velerotest.NewFakeControllerRuntimeClient(t)
+	secret := builder.ForSecret("velero", "cloud-credentials").Data(map[string][]byte{"cloud": []byte("old-secret-access-key")}).Result()
+	require.NoError(t, client.Create(context.Background(), secret))
+
+	fs := velerotest.NewFakeFileSystem()
+	fileStore, err := NewNamespacedFileStore(client, "velero", "/tmp/credentials", fs)
+	require.NoError(t, err)
+	selector := builder.ForSecretKeySelector("cloud-credentials", "cloud").Result()
+	path, err := fileStore.Path(selector)
+	require.NoError(t, err)
+
+	rotator := NewRotator(fileStore)
+	updater := &recordingConfigUpdater{}
+	unregister := rotator.Register("velero", selector, updater, map[string]string{"bucket": "velero", credentialsFileConfigKey: path})
+	other := &recordingConfigUpdater{}
+	defer rotator.Register("velero", builder.ForSecretKeySelector("other-credentials", "cloud").Result(), other, map[string]string{})()
+	// a secret with the same name in another namespace is a different secret
+	otherNamespace := &recordingConfigUpdater{}
+	defer rotator.Register("other", selector, otherNamespace, map[string]string{})()
+
+	secret.Data["cloud"] = []byte("new-key")
+	require.NoError(t, client.Update(context.Background(), secret))
+	require.NoError(t, rotator.Rotate(context.Background(), types.NamespacedName{Namespace: "velero", Name: "cloud-credentials"}))
+
+	assert.Equal(t, []map[string]string{{"bucket": "velero", credentialsFileConfigKey: path}}, updater.configs)
+	assert.Empty(t, other.configs)
+	assert.Empty(t, otherNamespace.configs)
+	contents, err := fs.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new-key", string(contents))
+
+	// errors reinitializing plugins are returned
+	updater.err = errors.New("invalid credentials")
+	assert.EqualError(t, rotator.Rotate(context.Background(), types.NamespacedName{Namespace: "velero", Name: "cloud-credentials"}),
+		"unable to reinitialize plugin with rotated credentials from secret velero/cloud-credentials: invalid credentials")
+
+	// unregistered plugins aren't reinitialized
+	unregister()
+	require.NoError(t, rotator.Rotate(context.Background(), types.NamespacedName{Namespace: "velero", Name: "cloud-credentials"}))
+	assert.Len(t, updater.configs, 2)
+}
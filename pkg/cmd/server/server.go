@@ -122,6 +122,7 @@ type serverConfig struct {
 	formatFlag                                                              *logging.FormatFlag
 	defaultResticMaintenanceFrequency                                       time.Duration
 	defaultVolumesToRestic                                                  bool
+	objectStoreMetricsMaxLabels                                             int
 }
 
 type controllerRunInfo struct {
@@ -151,6 +152,7 @@ func NewCommand(f client.Factory) *cobra.Command {
 			formatFlag:                        logging.NewFormatFlag(),
 			defaultResticMaintenanceFrequency: restic.DefaultMaintenanceFrequency,
 			defaultVolumesToRestic:            restic.DefaultVolumesToRestic,
+			objectStoreMetricsMaxLabels:       clientmgmt.DefaultObjectStoreMetricsMaxLabels,
 		}
 	)
 
@@ -210,6 +212,7 @@ func NewCommand(f client.Factory) *cobra.Command {
 	command.Flags().Float32Var(&config.clientQPS, "client-qps", config.clientQPS, "Maximum number of requests per second by the server to the Kubernetes API once the burst limit has been reached.")
 	command.Flags().IntVar(&config.clientBurst, "client-burst", config.clientBurst, "Maximum number of requests by the server to the Kubernetes API in a short period of time.")
 	command.Flags().IntVar(&config.clientPageSize, "client-page-size", config.clientPageSize, "Page size of requests by the server to the Kubernetes API when listing objects during a backup. Set to 0 to disable paging.")
+	command.Flags().IntVar(&config.objectStoreMetricsMaxLabels, "object-store-metrics-max-labels", config.objectStoreMetricsMaxLabels, "Maximum number of distinct backups and restores that object store request and byte metrics are broken down by. Beyond it, the series of the least recently active backup or restore are deleted to make room for the next one.")
 	command.Flags().StringVar(&config.profilerAddress, "profiler-address", config.profilerAddress, "The address to expose the pprof profiler.")
 	command.Flags().DurationVar(&config.resourceTerminatingTimeout, "terminating-resource-timeout", config.resourceTerminatingTimeout, "How long to wait on persistent volumes and namespaces to terminate during a restore before timing out.")
 	command.Flags().DurationVar(&config.defaultBackupTTL, "default-backup-ttl", config.defaultBackupTTL, "How long to wait by default before backups can be garbage collected.")
@@ -582,6 +585,7 @@ func (s *server) runControllers(defaultVolumeSnapshotLocations map[string]string
 	}()
 	s.metrics = metrics.NewServerMetrics()
 	s.metrics.RegisterAllMetrics()
+	clientmgmt.RegisterObjectStoreMetrics(s.config.objectStoreMetricsMaxLabels)
 	// Initialize manual backup metrics
 	s.metrics.InitSchedule("")
 
@@ -589,7 +593,12 @@ func (s *server) runControllers(defaultVolumeSnapshotLocations map[string]string
 		return clientmgmt.NewManager(logger, s.logLevel, s.pluginRegistry)
 	}
 
-	backupStoreGetter := persistence.NewObjectBackupStoreGetter(s.credentialFileStore)
+	credentialRotator := credentials.NewRotator(s.credentialFileStore)
+	backupStoreGetter := persistence.NewObjectBackupStoreGetter(
+		s.credentialFileStore,
+		persistence.WithCredentialRotator(credentialRotator),
+		persistence.WithEventRecorder(s.mgr.GetEventRecorderFor("velero")),
+	)
 
 	csiVSLister, csiVSCLister, csiVSClassLister := s.getCSISnapshotListers()
 
@@ -819,6 +828,10 @@ func (s *server) runControllers(defaultVolumeSnapshotLocations map[string]string
 		s.logger.Fatal(err, "unable to create controller", "controller", controller.BackupDeletion)
 	}
 
+	if err := controller.NewCredentialsRotationReconciler(s.namespace, s.logger, credentialRotator).SetupWithManager(s.mgr); err != nil {
+		s.logger.Fatal(err, "unable to create controller", "controller", controller.CredentialsRotation)
+	}
+
 	if _, ok := enabledRuntimeControllers[controller.ServerStatusRequest]; ok {
 		r := controller.ServerStatusRequestReconciler{
 			Scheme:         s.mgr.GetScheme(),
@@ -132,7 +132,7 @@ func (r *BackupStorageLocationReconciler) Reconcile(ctx context.Context, req ctr
 			location.Spec.Default = isDefault
 
 			log.Info("Validating backup storage location")
-			err = backupStore.IsValid()
+			err = backupStore.IsValid(ctx)
 		}()
 	}
 
@@ -17,12 +17,14 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -59,6 +61,12 @@ var _ = Describe("Backup Storage Location Reconciler", func() {
 				expectedIsDefault: false,
 				expectedPhase:     velerov1api.BackupStorageLocationPhaseUnavailable,
 			},
+			{
+				backupLocation:    builder.ForBackupStorageLocation("ns-1", "location-3").ValidationFrequency(1 * time.Second).Result(),
+				isValidError:      &clientmgmt.UnresponsiveError{Plugin: "velero.io/aws", Timeout: 5 * time.Second, Err: context.DeadlineExceeded},
+				expectedIsDefault: false,
+				expectedPhase:     velerov1api.BackupStorageLocationPhaseUnavailable,
+			},
 		}
 
 		// Setup
@@ -74,7 +82,7 @@ var _ = Describe("Backup Storage Location Reconciler", func() {
 			locations.Items = append(locations.Items, *location)
 			backupStores[location.Name] = &persistencemocks.BackupStore{}
 			backupStore := backupStores[location.Name]
-			backupStore.On("IsValid").Return(tests[i].isValidError)
+			backupStore.On("IsValid", mock.Anything).Return(tests[i].isValidError)
 		}
 
 		// Setup reconciler
@@ -140,7 +148,7 @@ var _ = Describe("Backup Storage Location Reconciler", func() {
 			locations.Items = append(locations.Items, *location)
 			backupStores[location.Name] = &persistencemocks.BackupStore{}
 			backupStore := backupStores[location.Name]
-			backupStore.On("IsValid").Return(tests[i].isValidError)
+			backupStore.On("IsValid", mock.Anything).Return(tests[i].isValidError)
 		}
 
 		// Setup reconciler
@@ -210,7 +218,7 @@ var _ = Describe("Backup Storage Location Reconciler", func() {
 			location := test.backupLocation
 			locations.Items = append(locations.Items, *location)
 			backupStores[location.Name] = &persistencemocks.BackupStore{}
-			backupStores[location.Name].On("IsValid").Return(tests[i].isValidError)
+			backupStores[location.Name].On("IsValid", mock.Anything).Return(tests[i].isValidError)
 		}
 
 		// Setup reconciler
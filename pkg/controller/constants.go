@@ -21,6 +21,7 @@ const (
 	BackupDeletion        = "backup-deletion"
 	BackupStorageLocation = "backup-storage-location"
 	BackupSync            = "backup-sync"
+	CredentialsRotation   = "credentials-rotation"
 	DownloadRequest       = "download-request"
 	GarbageCollection     = "gc"
 	PodVolumeBackup       = "pod-volume-backup"
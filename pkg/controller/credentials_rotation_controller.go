@@ -0,0 +1,94 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+	corev1api "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/vmware-tanzu/velero/internal/credentials"
+)
+
+// credentialsRotationReconciler reinitializes the plugins registered with a credentials.Rotator when the data of
+// the secret holding their credentials changes, so that rotated credentials are picked up without a restart.
+type credentialsRotationReconciler struct {
+	namespace string
+	rotator   *credentials.Rotator
+	logger    logrus.FieldLogger
+}
+
+func NewCredentialsRotationReconciler(namespace string, logger logrus.FieldLogger, rotator *credentials.Rotator) *credentialsRotationReconciler {
+	return &credentialsRotationReconciler{
+		namespace: namespace,
+		rotator:   rotator,
+		logger:    logger,
+	}
+}
+
+// SetupWithManager watches the secrets in Velero's namespace, which is where the credentials of backup storage
+// locations are read from.
+func (c *credentialsRotationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	inNamespace := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == c.namespace
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1api.Secret{}, builder.WithPredicates(inNamespace, secretDataChangedPredicate())).
+		Complete(c)
+}
+
+func (c *credentialsRotationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := c.logger.WithFields(logrus.Fields{
+		"controller": CredentialsRotation,
+		"secret":     req.NamespacedName,
+	})
+
+	log.Debug("Reinitializing plugins with rotated credentials")
+	if err := c.rotator.Rotate(ctx, req.NamespacedName); err != nil {
+		log.WithError(err).Error("Error reinitializing plugins with rotated credentials")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// secretDataChangedPredicate only lets updates of secrets which change their data through, as plugins are
+// initialized with the current credentials when they're created.
+func secretDataChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldSecret, ok := e.ObjectOld.(*corev1api.Secret)
+			if !ok {
+				return false
+			}
+			newSecret, ok := e.ObjectNew.(*corev1api.Secret)
+			if !ok {
+				return false
+			}
+			return !reflect.DeepEqual(oldSecret.Data, newSecret.Data)
+		},
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/vmware-tanzu/velero/internal/credentials"
+	"github.com/vmware-tanzu/velero/pkg/builder"
+	velerotest "github.com/vmware-tanzu/velero/pkg/test"
+)
+
+type recordingConfigUpdater struct {
+	configs []map[string]string
+}
+
+func (u *recordingConfigUpdater) UpdateConfig(ctx context.Context, config map[string]string) error {
+	u.configs = append(u.configs, config)
+	return nil
+}
+
+func TestCredentialsRotationReconcile(t *testing.T) {
+	rotator := credentials.NewRotator(velerotest.NewFakeCredentialsFileStore("/credentials/velero/cloud-credentials-cloud", nil))
+	updater := &recordingConfigUpdater{}
+	defer rotator.Register("velero", builder.ForSecretKeySelector("cloud-credentials", "cloud").Result(), updater, map[string]string{"bucket": "velero"})()
+
+	r := NewCredentialsRotationReconciler("velero", velerotest.NewLogger(), rotator)
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "velero", Name: "cloud-credentials"}})
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"bucket": "velero", "credentialsFile": "/credentials/velero/cloud-credentials-cloud"}}, updater.configs)
+}
+
+func TestSecretDataChangedPredicate(t *testing.T) {
+	p := secretDataChangedPredicate()
+	secret := builder.ForSecret("velero", "cloud-credentials").Data(map[string][]byte{"cloud": []byte("key")}).Result()
+	rotated := builder.ForSecret("velero", "cloud-credentials").Data(map[string][]byte{"cloud": []byte("rotated-key")}).Result()
+
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: rotated}))
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: secret, ObjectNew: secret.DeepCopy()}))
+	assert.False(t, p.Create(event.CreateEvent{Object: secret}))
+	assert.False(t, p.Delete(event.DeleteEvent{Object: secret}))
+}
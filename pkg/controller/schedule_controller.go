@@ -66,7 +66,11 @@ func NewScheduleReconciler(
 }
 
 func (c *scheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	s := kube.NewPeriodicalEnqueueSource(c.logger, mgr.GetClient(), &velerov1.ScheduleList{}, scheduleSyncPeriod)
+	s := kube.NewPeriodicalEnqueueSource(c.logger, mgr.GetClient(), &velerov1.ScheduleList{}, scheduleSyncPeriod).
+		OnCycle(func(cycle kube.EnqueueCycle) {
+			c.metrics.RegisterPeriodicalEnqueueCycle(cycle.Resource, cycle.Enqueued, cycle.Duration)
+		}).
+		WithMetrics(c.metrics)
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&velerov1.Schedule{}).
 		Watches(s, nil).
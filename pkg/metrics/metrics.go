@@ -57,6 +57,10 @@ const (
 	csiSnapshotAttemptTotal       = "csi_snapshot_attempt_total"
 	csiSnapshotSuccessTotal       = "csi_snapshot_success_total"
 	csiSnapshotFailureTotal       = "csi_snapshot_failure_total"
+	periodicalEnqueueItemsGauge   = "periodical_enqueue_items"
+	periodicalEnqueueSeconds      = "periodical_enqueue_cycle_duration_seconds"
+	periodicalEnqueuedTotal       = "periodical_enqueue_items_total"
+	periodicalEnqueueListErrors   = "periodical_enqueue_list_errors_total"
 
 	// Restic metrics
 	podVolumeBackupEnqueueTotal        = "pod_volume_backup_enqueue_count"
@@ -70,6 +74,7 @@ const (
 	pvbNameLabel         = "pod_volume_backup"
 	scheduleLabel        = "schedule"
 	backupNameLabel      = "backupName"
+	resourceLabel        = "resource"
 )
 
 // NewServerMetrics returns new ServerMetrics
@@ -182,6 +187,39 @@ func NewServerMetrics() *ServerMetrics {
 				},
 				[]string{scheduleLabel},
 			),
+			periodicalEnqueueItemsGauge: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace: metricNamespace,
+					Name:      periodicalEnqueueItemsGauge,
+					Help:      "Number of items enqueued by the last periodical enqueue cycle",
+				},
+				[]string{resourceLabel},
+			),
+			periodicalEnqueueSeconds: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace: metricNamespace,
+					Name:      periodicalEnqueueSeconds,
+					Help:      "Time taken to list and enqueue items in a periodical enqueue cycle, in seconds",
+					Buckets:   prometheus.DefBuckets,
+				},
+				[]string{resourceLabel},
+			),
+			periodicalEnqueuedTotal: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      periodicalEnqueuedTotal,
+					Help:      "Total number of items enqueued by periodical enqueue cycles",
+				},
+				[]string{resourceLabel},
+			),
+			periodicalEnqueueListErrors: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace: metricNamespace,
+					Name:      periodicalEnqueueListErrors,
+					Help:      "Total number of periodical enqueue cycles which failed to list items",
+				},
+				[]string{resourceLabel},
+			),
 			backupItemsTotalGauge: prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
 					Namespace: metricNamespace,
@@ -559,6 +597,33 @@ func (m *ServerMetrics) RegisterBackupItemsErrorsGauge(backupSchedule string, it
 	}
 }
 
+// RegisterPeriodicalEnqueueCycle records the number of items enqueued by a periodical enqueue
+// cycle for the given resource and how long the cycle took.
+func (m *ServerMetrics) RegisterPeriodicalEnqueueCycle(resource string, enqueued int, duration time.Duration) {
+	if g, ok := m.metrics[periodicalEnqueueItemsGauge].(*prometheus.GaugeVec); ok {
+		g.WithLabelValues(resource).Set(float64(enqueued))
+	}
+	if h, ok := m.metrics[periodicalEnqueueSeconds].(*prometheus.HistogramVec); ok {
+		h.WithLabelValues(resource).Observe(duration.Seconds())
+	}
+}
+
+// RegisterPeriodicalEnqueued adds the number of items enqueued by a periodical enqueue cycle
+// for the given resource to the total.
+func (m *ServerMetrics) RegisterPeriodicalEnqueued(resource string, enqueued int) {
+	if c, ok := m.metrics[periodicalEnqueuedTotal].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(resource).Add(float64(enqueued))
+	}
+}
+
+// RegisterPeriodicalEnqueueListError records a periodical enqueue cycle for the given
+// resource which failed to list the items.
+func (m *ServerMetrics) RegisterPeriodicalEnqueueListError(resource string) {
+	if c, ok := m.metrics[periodicalEnqueueListErrors].(*prometheus.CounterVec); ok {
+		c.WithLabelValues(resource).Inc()
+	}
+}
+
 // toSeconds translates a time.Duration value into a float64
 // representing the number of seconds in that duration.
 func toSeconds(d time.Duration) float64 {
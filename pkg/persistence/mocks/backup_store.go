@@ -17,6 +17,7 @@ limitations under the License.
 package mocks
 
 import (
+	context "context"
 	io "io"
 
 	mock "github.com/stretchr/testify/mock"
@@ -195,13 +196,13 @@ func (_m *BackupStore) GetPodVolumeBackups(name string) ([]*v1.PodVolumeBackup,
 	return r0, r1
 }
 
-// IsValid provides a mock function with given fields:
-func (_m *BackupStore) IsValid() error {
-	ret := _m.Called()
+// IsValid provides a mock function with given fields: ctx
+func (_m *BackupStore) IsValid(ctx context.Context) error {
+	ret := _m.Called(ctx)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func() error); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
 	} else {
 		r0 = ret.Error(0)
 	}
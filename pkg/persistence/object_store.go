@@ -18,6 +18,7 @@ package persistence
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
@@ -28,12 +29,15 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/vmware-tanzu/velero/internal/credentials"
 	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	"github.com/vmware-tanzu/velero/pkg/generated/clientset/versioned/scheme"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
 	"github.com/vmware-tanzu/velero/pkg/volume"
 )
 
@@ -54,7 +58,7 @@ type BackupInfo struct {
 // BackupStore defines operations for creating, retrieving, and deleting
 // Velero backup and restore data in/from a persistent backup store.
 type BackupStore interface {
-	IsValid() error
+	IsValid(ctx context.Context) error
 
 	ListBackups() ([]string, error)
 
@@ -103,12 +107,64 @@ type ObjectBackupStoreGetter interface {
 }
 
 type objectBackupStoreGetter struct {
-	credentialStore credentials.FileStore
+	credentialStore   credentials.FileStore
+	credentialRotator *credentials.Rotator
+	eventRecorder     record.EventRecorder
+}
+
+// ObjectBackupStoreGetterOption configures the ObjectBackupStoreGetter returned by NewObjectBackupStoreGetter.
+type ObjectBackupStoreGetterOption func(getter *objectBackupStoreGetter)
+
+// WithCredentialRotator makes the ObjectBackupStoreGetter register the object stores of backup storage locations
+// with a credential with rotator, so that they're reinitialized when the credential's secret changes.
+func WithCredentialRotator(rotator *credentials.Rotator) ObjectBackupStoreGetterOption {
+	return func(getter *objectBackupStoreGetter) {
+		getter.credentialRotator = rotator
+	}
+}
+
+// WithEventRecorder makes the ObjectBackupStoreGetter have object stores record events with recorder on their backup
+// storage location when their operations start failing persistently, and when they recover.
+func WithEventRecorder(recorder record.EventRecorder) ObjectBackupStoreGetterOption {
+	return func(getter *objectBackupStoreGetter) {
+		getter.eventRecorder = recorder
+	}
 }
 
 // NewObjectBackupStoreGetter returns a ObjectBackupStoreGetter that can get a velero.BackupStore.
-func NewObjectBackupStoreGetter(credentialStore credentials.FileStore) ObjectBackupStoreGetter {
-	return &objectBackupStoreGetter{credentialStore: credentialStore}
+func NewObjectBackupStoreGetter(credentialStore credentials.FileStore, options ...ObjectBackupStoreGetterOption) ObjectBackupStoreGetter {
+	getter := &objectBackupStoreGetter{credentialStore: credentialStore}
+	for _, option := range options {
+		option(getter)
+	}
+	return getter
+}
+
+// closeNotifier is implemented by object stores which can tell when they're no longer in use.
+type closeNotifier interface {
+	OnClose(func())
+}
+
+// eventRecorderSetter is implemented by object stores which can record events about their failures.
+type eventRecorderSetter interface {
+	SetEventRecorder(recorder record.EventRecorder, object runtime.Object)
+}
+
+// healthChecker is implemented by object stores which can probe whether their plugin responds in time.
+type healthChecker interface {
+	Healthy(ctx context.Context) error
+}
+
+// operationLabeler is implemented by object stores which can count the calls made on behalf of a backup or restore
+// separately in their metrics, such as clientmgmt's restartable object store.
+type operationLabeler interface {
+	ForOperation(label string) velero.ObjectStore
+}
+
+// configProviderSetter is implemented by object stores which can get a fresh config to reinitialize their plugin
+// with when its process restarts, such as clientmgmt's restartable object store.
+type configProviderSetter interface {
+	SetConfigProvider(func(context.Context) (map[string]string, error))
 }
 
 func (b *objectBackupStoreGetter) Get(location *velerov1api.BackupStorageLocation, objectStoreGetter ObjectStoreGetter, logger logrus.FieldLogger) (BackupStore, error) {
@@ -166,6 +222,45 @@ func (b *objectBackupStoreGetter) Get(location *velerov1api.BackupStorageLocatio
 		return nil, err
 	}
 
+	if setter, ok := objectStore.(eventRecorderSetter); ok && b.eventRecorder != nil {
+		setter.SetEventRecorder(b.eventRecorder, location)
+	}
+
+	if location.Spec.Credential != nil {
+		config := make(map[string]string, len(location.Spec.Config))
+		for k, v := range location.Spec.Config {
+			config[k] = v
+		}
+
+		// if the plugin's process restarts, reinitialize it with the credentials currently in the secret, which
+		// may have been rotated since
+		if setter, ok := objectStore.(configProviderSetter); ok {
+			credentialStore, selector := b.credentialStore, location.Spec.Credential
+			setter.SetConfigProvider(func(context.Context) (map[string]string, error) {
+				credsFile, err := credentialStore.Path(selector)
+				if err != nil {
+					return nil, errors.Wrap(err, "unable to get credentials")
+				}
+
+				fresh := make(map[string]string, len(config))
+				for k, v := range config {
+					fresh[k] = v
+				}
+				fresh["credentialsFile"] = credsFile
+				return fresh, nil
+			})
+		}
+
+		if b.credentialRotator != nil {
+			updater, isUpdater := objectStore.(credentials.ConfigUpdater)
+			notifier, isNotifier := objectStore.(closeNotifier)
+			// without knowing when the object store is no longer in use, it would stay registered forever
+			if isUpdater && isNotifier {
+				notifier.OnClose(b.credentialRotator.Register(location.Namespace, location.Spec.Credential, updater, config))
+			}
+		}
+	}
+
 	log := logger.WithFields(logrus.Fields(map[string]interface{}{
 		"bucket": bucket,
 		"prefix": prefix,
@@ -179,7 +274,15 @@ func (b *objectBackupStoreGetter) Get(location *velerov1api.BackupStorageLocatio
 	}, nil
 }
 
-func (s *objectBackupStore) IsValid() error {
+func (s *objectBackupStore) IsValid(ctx context.Context) error {
+	// an object store whose plugin hangs, e.g. because its credentials expired, would otherwise leave the
+	// backup storage location's validation hanging with it
+	if checker, ok := s.objectStore.(healthChecker); ok {
+		if err := checker.Healthy(ctx); err != nil {
+			return err
+		}
+	}
+
 	dirs, err := s.objectStore.ListCommonPrefixes(s.bucket, s.layout.rootPrefix, "/")
 	if err != nil {
 		return errors.WithStack(err)
@@ -228,20 +331,30 @@ func (s *objectBackupStore) ListBackups() ([]string, error) {
 	return output, nil
 }
 
+// objectStoreFor returns the object store to make calls on behalf of the backup or restore named name with.
+func (s *objectBackupStore) objectStoreFor(name string) velero.ObjectStore {
+	if labeler, ok := s.objectStore.(operationLabeler); ok {
+		return labeler.ForOperation(name)
+	}
+	return s.objectStore
+}
+
 func (s *objectBackupStore) PutBackup(info BackupInfo) error {
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getBackupLogKey(info.Name), info.Log); err != nil {
+	objectStore := s.objectStoreFor(info.Name)
+
+	if err := seekAndPutObject(objectStore, s.bucket, s.layout.getBackupLogKey(info.Name), info.Log); err != nil {
 		// Uploading the log file is best-effort; if it fails, we log the error but it doesn't impact the
 		// backup's status.
 		s.logger.WithError(err).WithField("backup", info.Name).Error("Error uploading log file")
 	}
 
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getBackupMetadataKey(info.Name), info.Metadata); err != nil {
+	if err := seekAndPutObject(objectStore, s.bucket, s.layout.getBackupMetadataKey(info.Name), info.Metadata); err != nil {
 		// failure to upload metadata file is a hard-stop
 		return err
 	}
 
-	if err := seekAndPutObject(s.objectStore, s.bucket, s.layout.getBackupContentsKey(info.Name), info.Contents); err != nil {
-		deleteErr := s.objectStore.DeleteObject(s.bucket, s.layout.getBackupMetadataKey(info.Name))
+	if err := seekAndPutObject(objectStore, s.bucket, s.layout.getBackupContentsKey(info.Name), info.Contents); err != nil {
+		deleteErr := objectStore.DeleteObject(s.bucket, s.layout.getBackupMetadataKey(info.Name))
 		return kerrors.NewAggregate([]error{err, deleteErr})
 	}
 
@@ -258,14 +371,14 @@ func (s *objectBackupStore) PutBackup(info BackupInfo) error {
 	}
 
 	for key, reader := range backupObjs {
-		if err := seekAndPutObject(s.objectStore, s.bucket, key, reader); err != nil {
+		if err := seekAndPutObject(objectStore, s.bucket, key, reader); err != nil {
 			errs := []error{err}
 
 			// attempt to clean up the backup contents and metadata if we fail to upload and of the extra files.
-			deleteErr := s.objectStore.DeleteObject(s.bucket, s.layout.getBackupContentsKey(info.Name))
+			deleteErr := objectStore.DeleteObject(s.bucket, s.layout.getBackupContentsKey(info.Name))
 			errs = append(errs, deleteErr)
 
-			deleteErr = s.objectStore.DeleteObject(s.bucket, s.layout.getBackupMetadataKey(info.Name))
+			deleteErr = objectStore.DeleteObject(s.bucket, s.layout.getBackupMetadataKey(info.Name))
 			errs = append(errs, deleteErr)
 			return kerrors.NewAggregate(errs)
 		}
@@ -277,7 +390,7 @@ func (s *objectBackupStore) PutBackup(info BackupInfo) error {
 func (s *objectBackupStore) GetBackupMetadata(name string) (*velerov1api.Backup, error) {
 	metadataKey := s.layout.getBackupMetadataKey(name)
 
-	res, err := s.objectStore.GetObject(s.bucket, metadataKey)
+	res, err := s.objectStoreFor(name).GetObject(s.bucket, metadataKey)
 	if err != nil {
 		return nil, err
 	}
@@ -306,7 +419,7 @@ func (s *objectBackupStore) GetBackupVolumeSnapshots(name string) ([]*volume.Sna
 	// if the volumesnapshots file doesn't exist, we don't want to return an error, since
 	// a legacy backup or a backup with no snapshots would not have this file, so check for
 	// its existence before attempting to get its contents.
-	res, err := tryGet(s.objectStore, s.bucket, s.layout.getBackupVolumeSnapshotsKey(name))
+	res, err := tryGet(s.objectStoreFor(name), s.bucket, s.layout.getBackupVolumeSnapshotsKey(name))
 	if err != nil {
 		return nil, err
 	}
@@ -327,7 +440,7 @@ func (s *objectBackupStore) GetItemSnapshots(name string) ([]*volume.ItemSnapsho
 	// if the itemsnapshots file doesn't exist, we don't want to return an error, since
 	// a legacy backup or a backup with no snapshots would not have this file, so check for
 	// its existence before attempting to get its contents.
-	res, err := tryGet(s.objectStore, s.bucket, s.layout.getItemSnapshotsKey(name))
+	res, err := tryGet(s.objectStoreFor(name), s.bucket, s.layout.getItemSnapshotsKey(name))
 	if err != nil {
 		return nil, err
 	}
@@ -375,7 +488,7 @@ func decode(jsongzReader io.Reader, into interface{}) error {
 }
 
 func (s *objectBackupStore) GetCSIVolumeSnapshotClasses(name string) ([]*snapshotv1api.VolumeSnapshotClass, error) {
-	res, err := tryGet(s.objectStore, s.bucket, s.layout.getCSIVolumeSnapshotClassesKey(name))
+	res, err := tryGet(s.objectStoreFor(name), s.bucket, s.layout.getCSIVolumeSnapshotClassesKey(name))
 	if err != nil {
 		return nil, err
 	}
@@ -394,7 +507,7 @@ func (s *objectBackupStore) GetCSIVolumeSnapshotClasses(name string) ([]*snapsho
 }
 
 func (s *objectBackupStore) GetCSIVolumeSnapshots(name string) ([]*snapshotv1api.VolumeSnapshot, error) {
-	res, err := tryGet(s.objectStore, s.bucket, s.layout.getCSIVolumeSnapshotKey(name))
+	res, err := tryGet(s.objectStoreFor(name), s.bucket, s.layout.getCSIVolumeSnapshotKey(name))
 	if err != nil {
 		return nil, err
 	}
@@ -412,7 +525,7 @@ func (s *objectBackupStore) GetCSIVolumeSnapshots(name string) ([]*snapshotv1api
 }
 
 func (s *objectBackupStore) GetCSIVolumeSnapshotContents(name string) ([]*snapshotv1api.VolumeSnapshotContent, error) {
-	res, err := tryGet(s.objectStore, s.bucket, s.layout.getCSIVolumeSnapshotContentsKey(name))
+	res, err := tryGet(s.objectStoreFor(name), s.bucket, s.layout.getCSIVolumeSnapshotContentsKey(name))
 	if err != nil {
 		return nil, err
 	}
@@ -433,7 +546,7 @@ func (s *objectBackupStore) GetPodVolumeBackups(name string) ([]*velerov1api.Pod
 	// if the podvolumebackups file doesn't exist, we don't want to return an error, since
 	// a legacy backup or a backup with no pod volume backups would not have this file, so
 	// check for its existence before attempting to get its contents.
-	res, err := tryGet(s.objectStore, s.bucket, s.layout.getPodVolumeBackupsKey(name))
+	res, err := tryGet(s.objectStoreFor(name), s.bucket, s.layout.getPodVolumeBackupsKey(name))
 	if err != nil {
 		return nil, err
 	}
@@ -451,30 +564,20 @@ func (s *objectBackupStore) GetPodVolumeBackups(name string) ([]*velerov1api.Pod
 }
 
 func (s *objectBackupStore) GetBackupContents(name string) (io.ReadCloser, error) {
-	return s.objectStore.GetObject(s.bucket, s.layout.getBackupContentsKey(name))
+	return s.objectStoreFor(name).GetObject(s.bucket, s.layout.getBackupContentsKey(name))
 }
 
 func (s *objectBackupStore) BackupExists(bucket, backupName string) (bool, error) {
-	return s.objectStore.ObjectExists(bucket, s.layout.getBackupMetadataKey(backupName))
+	return s.objectStoreFor(backupName).ObjectExists(bucket, s.layout.getBackupMetadataKey(backupName))
 }
 
 func (s *objectBackupStore) DeleteBackup(name string) error {
-	objects, err := s.objectStore.ListObjects(s.bucket, s.layout.getBackupDir(name))
+	objects, err := s.objectStore.ListObjects(s.bucket, s.layout.GetBackupDir(name))
 	if err != nil {
 		return err
 	}
 
-	var errs []error
-	for _, key := range objects {
-		s.logger.WithFields(logrus.Fields{
-			"key": key,
-		}).Debug("Trying to delete object")
-		if err := s.objectStore.DeleteObject(s.bucket, key); err != nil {
-			errs = append(errs, err)
-		}
-	}
-
-	return errors.WithStack(kerrors.NewAggregate(errs))
+	return s.deleteObjects(objects)
 }
 
 func (s *objectBackupStore) DeleteRestore(name string) error {
@@ -483,8 +586,32 @@ func (s *objectBackupStore) DeleteRestore(name string) error {
 		return err
 	}
 
+	return s.deleteObjects(objects)
+}
+
+// deleteObjects deletes the objects with the given keys, in bulk if the object store supports it, and returns the
+// errors for the ones that couldn't be deleted.
+func (s *objectBackupStore) deleteObjects(keys []string) error {
+	if objectStore, ok := s.objectStore.(osv2.ObjectStore); ok {
+		s.logger.Debugf("Trying to delete %d objects", len(keys))
+		for _, key := range keys {
+			s.logger.WithFields(logrus.Fields{
+				"key": key,
+			}).Debug("Trying to delete object")
+		}
+		deleteErrs, err := objectStore.DeleteObjectsV2(context.Background(), s.bucket, keys)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		errs := make([]error, 0, len(deleteErrs))
+		for _, deleteErr := range deleteErrs {
+			errs = append(errs, deleteErr)
+		}
+		return errors.WithStack(kerrors.NewAggregate(errs))
+	}
+
 	var errs []error
-	for _, key := range objects {
+	for _, key := range keys {
 		s.logger.WithFields(logrus.Fields{
 			"key": key,
 		}).Debug("Trying to delete object")
@@ -497,11 +624,11 @@ func (s *objectBackupStore) DeleteRestore(name string) error {
 }
 
 func (s *objectBackupStore) PutRestoreLog(backup string, restore string, log io.Reader) error {
-	return s.objectStore.PutObject(s.bucket, s.layout.getRestoreLogKey(restore), log)
+	return s.objectStoreFor(restore).PutObject(s.bucket, s.layout.getRestoreLogKey(restore), log)
 }
 
 func (s *objectBackupStore) PutRestoreResults(backup string, restore string, results io.Reader) error {
-	return s.objectStore.PutObject(s.bucket, s.layout.getRestoreResultsKey(restore), results)
+	return s.objectStoreFor(restore).PutObject(s.bucket, s.layout.GetRestoreResultsKey(restore), results)
 }
 
 func (s *objectBackupStore) GetDownloadURL(target velerov1api.DownloadTarget) (string, error) {
@@ -519,7 +646,7 @@ func (s *objectBackupStore) GetDownloadURL(target velerov1api.DownloadTarget) (s
 	case velerov1api.DownloadTargetKindRestoreLog:
 		return s.objectStore.CreateSignedURL(s.bucket, s.layout.getRestoreLogKey(target.Name), DownloadURLTTL)
 	case velerov1api.DownloadTargetKindRestoreResults:
-		return s.objectStore.CreateSignedURL(s.bucket, s.layout.getRestoreResultsKey(target.Name), DownloadURLTTL)
+		return s.objectStore.CreateSignedURL(s.bucket, s.layout.GetRestoreResultsKey(target.Name), DownloadURLTTL)
 	default:
 		return "", errors.Errorf("unsupported download target kind %q", target.Kind)
 	}
@@ -60,7 +60,9 @@ func (l *ObjectStoreLayout) isValidSubdir(name string) bool {
 	return ok
 }
 
-func (l *ObjectStoreLayout) getBackupDir(backup string) string {
+// GetBackupDir returns the prefix of the keys of the objects stored
+// for a backup.
+func (l *ObjectStoreLayout) GetBackupDir(backup string) string {
 	return path.Join(l.subdirs["backups"], backup) + "/"
 }
 
@@ -100,7 +102,9 @@ func (l *ObjectStoreLayout) getRestoreLogKey(restore string) string {
 	return path.Join(l.subdirs["restores"], restore, fmt.Sprintf("restore-%s-logs.gz", restore))
 }
 
-func (l *ObjectStoreLayout) getRestoreResultsKey(restore string) string {
+// GetRestoreResultsKey returns the key of the object holding the
+// warnings and errors of a restore.
+func (l *ObjectStoreLayout) GetRestoreResultsKey(restore string) string {
 	return path.Join(l.subdirs["restores"], restore, fmt.Sprintf("restore-%s-results.gz", restore))
 }
 
@@ -19,6 +19,7 @@ package persistence
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -28,16 +29,23 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	corev1api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/vmware-tanzu/velero/internal/credentials"
 	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	"github.com/vmware-tanzu/velero/pkg/builder"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
 	providermocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/mocks"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
 	velerotest "github.com/vmware-tanzu/velero/pkg/test"
 	"github.com/vmware-tanzu/velero/pkg/util/encode"
 	"github.com/vmware-tanzu/velero/pkg/volume"
@@ -157,7 +165,7 @@ func TestIsValid(t *testing.T) {
 				require.NoError(t, harness.objectStore.PutObject(harness.bucket, key, bytes.NewReader(obj)))
 			}
 
-			err := harness.IsValid()
+			err := harness.IsValid(context.Background())
 			if tc.expectErr {
 				assert.Error(t, err)
 			} else {
@@ -167,6 +175,34 @@ func TestIsValid(t *testing.T) {
 	}
 }
 
+// unhealthyObjectStore is an inMemoryObjectStore whose plugin fails its health check.
+type unhealthyObjectStore struct {
+	*inMemoryObjectStore
+
+	err error
+	ctx context.Context
+}
+
+func (s *unhealthyObjectStore) Healthy(ctx context.Context) error {
+	s.ctx = ctx
+	return s.err
+}
+
+type contextKey struct{}
+
+func TestIsValidHealthCheck(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+	objectStore := &unhealthyObjectStore{inMemoryObjectStore: harness.objectStore}
+	harness.objectBackupStore.objectStore = objectStore
+	assert.NoError(t, harness.IsValid(context.Background()))
+
+	// the plugin is probed on behalf of the caller
+	ctx := context.WithValue(context.Background(), contextKey{}, "validation")
+	objectStore.err = errors.New("object store plugin aws did not respond within 5s")
+	assert.EqualError(t, harness.IsValid(ctx), "object store plugin aws did not respond within 5s")
+	assert.Equal(t, ctx, objectStore.ctx)
+}
+
 func TestListBackups(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -355,6 +391,39 @@ func TestPutBackup(t *testing.T) {
 	}
 }
 
+// labelingObjectStore is an inMemoryObjectStore which records the operations calls are made on behalf of.
+type labelingObjectStore struct {
+	*inMemoryObjectStore
+
+	labels []string
+}
+
+func (s *labelingObjectStore) ForOperation(label string) velero.ObjectStore {
+	s.labels = append(s.labels, label)
+	return s.inMemoryObjectStore
+}
+
+func TestOperationLabels(t *testing.T) {
+	harness := newObjectBackupStoreTestHarness("foo", "")
+	objectStore := &labelingObjectStore{inMemoryObjectStore: harness.objectStore}
+	harness.objectBackupStore.objectStore = objectStore
+
+	require.NoError(t, harness.PutBackup(BackupInfo{
+		Name:     "backup-1",
+		Metadata: newStringReadSeeker("foo"),
+		Contents: newStringReadSeeker("bar"),
+	}))
+	contents, err := harness.GetBackupContents("backup-1")
+	require.NoError(t, err)
+	contents.Close()
+	require.NoError(t, harness.PutRestoreLog("backup-1", "restore-1", strings.NewReader("log")))
+	assert.Equal(t, []string{"backup-1", "backup-1", "restore-1"}, objectStore.labels)
+
+	// deleting isn't done on behalf of a backup or restore
+	require.NoError(t, harness.DeleteBackup("backup-1"))
+	assert.Len(t, objectStore.labels, 3)
+}
+
 func TestGetBackupMetadata(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -550,6 +619,37 @@ func TestDeleteBackup(t *testing.T) {
 	}
 }
 
+func TestDeleteBackupInBulk(t *testing.T) {
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	logs := new(bytes.Buffer)
+	logger := logrus.New()
+	logger.Out = logs
+	logger.Level = logrus.DebugLevel
+	backupStore := &objectBackupStore{
+		objectStore: objectStore,
+		bucket:      "test-bucket",
+		layout:      NewObjectStoreLayout(""),
+		logger:      logger,
+	}
+
+	objects := []string{"backups/bak/velero-backup.json", "backups/bak/bak.tar.gz", "backups/bak/bak.log.gz"}
+	objectStore.On("ListObjects", "test-bucket", "backups/bak/").Return(objects, nil)
+	objectStore.On("DeleteObjectsV2", mock.Anything, "test-bucket", objects).Return([]osv2.DeleteError{
+		{Key: "backups/bak/bak.tar.gz", Err: errors.New("access denied")},
+	}, nil)
+
+	err := backupStore.DeleteBackup("bak")
+
+	velerotest.AssertErrorMatches(t, "error deleting object backups/bak/bak.tar.gz: access denied", err)
+	// the keys being deleted are logged just like when they're deleted one by one
+	assert.Contains(t, logs.String(), "Trying to delete 3 objects")
+	for _, obj := range objects {
+		assert.Contains(t, logs.String(), "key="+obj)
+	}
+}
+
 func TestGetDownloadURL(t *testing.T) {
 	tests := []struct {
 		name              string
@@ -826,6 +926,127 @@ func TestNewObjectBackupStoreGetterConfig(t *testing.T) {
 	}
 }
 
+// rotatableObjectStore is an inMemoryObjectStore which can be reinitialized and tells when it's closed, like the
+// object stores dispensed by the plugin manager.
+type rotatableObjectStore struct {
+	*inMemoryObjectStore
+
+	closeFuncs     []func()
+	configProvider func(context.Context) (map[string]string, error)
+}
+
+func (s *rotatableObjectStore) SetConfigProvider(provider func(context.Context) (map[string]string, error)) {
+	s.configProvider = provider
+}
+
+func (s *rotatableObjectStore) UpdateConfig(ctx context.Context, config map[string]string) error {
+	return s.Init(config)
+}
+
+func (s *rotatableObjectStore) OnClose(f func()) {
+	s.closeFuncs = append(s.closeFuncs, f)
+}
+
+func (s *rotatableObjectStore) close() {
+	for _, f := range s.closeFuncs {
+		f()
+	}
+}
+
+func TestNewObjectBackupStoreGetterCredentialRotator(t *testing.T) {
+	credFileStore := &mutableCredentialsFileStore{path: "/tmp/credentials/secret-file"}
+	rotator := credentials.NewRotator(credFileStore)
+	getter := NewObjectBackupStoreGetter(credFileStore, WithCredentialRotator(rotator))
+
+	location := builder.ForBackupStorageLocation("velero", "default").Provider("provider").Bucket("bucket").Credential(
+		builder.ForSecretKeySelector("cloud-credentials", "cloud").Result(),
+	).Result()
+	objStore := &rotatableObjectStore{inMemoryObjectStore: newInMemoryObjectStore("bucket")}
+
+	_, err := getter.Get(location, objectStoreGetter{"provider": objStore}, velerotest.NewLogger())
+	require.NoError(t, err)
+	require.Len(t, objStore.closeFuncs, 1)
+
+	secret := types.NamespacedName{Namespace: "velero", Name: "cloud-credentials"}
+
+	// the object store is reinitialized with the rotated credentials
+	credFileStore.path = "/tmp/credentials/rotated"
+	require.NoError(t, rotator.Rotate(context.Background(), secret))
+	assert.Equal(t, map[string]string{
+		"bucket":          "bucket",
+		"prefix":          "",
+		"credentialsFile": "/tmp/credentials/rotated",
+	}, objStore.Config)
+
+	// if the plugin's process restarts, it's reinitialized with the current credentials
+	require.NotNil(t, objStore.configProvider)
+	config, err := objStore.configProvider(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"bucket":          "bucket",
+		"prefix":          "",
+		"credentialsFile": "/tmp/credentials/rotated",
+	}, config)
+
+	// once it's closed it's no longer reinitialized
+	objStore.close()
+	credFileStore.path = "/tmp/credentials/rotated-again"
+	require.NoError(t, rotator.Rotate(context.Background(), secret))
+	assert.Equal(t, "/tmp/credentials/rotated", objStore.Config["credentialsFile"])
+
+	// object stores of locations without a credential aren't registered
+	objStore = &rotatableObjectStore{inMemoryObjectStore: newInMemoryObjectStore("bucket")}
+	_, err = getter.Get(
+		builder.ForBackupStorageLocation("velero", "default").Provider("provider").Bucket("bucket").Result(),
+		objectStoreGetter{"provider": objStore},
+		velerotest.NewLogger(),
+	)
+	require.NoError(t, err)
+	assert.Empty(t, objStore.closeFuncs)
+	assert.Nil(t, objStore.configProvider)
+}
+
+// eventRecordingObjectStore is an inMemoryObjectStore which can record events about its failures.
+type eventRecordingObjectStore struct {
+	*inMemoryObjectStore
+
+	recorder record.EventRecorder
+	object   runtime.Object
+}
+
+func (s *eventRecordingObjectStore) SetEventRecorder(recorder record.EventRecorder, object runtime.Object) {
+	s.recorder = recorder
+	s.object = object
+}
+
+func TestNewObjectBackupStoreGetterEventRecorder(t *testing.T) {
+	location := builder.ForBackupStorageLocation("velero", "default").Provider("provider").Bucket("bucket").Result()
+	recorder := record.NewFakeRecorder(1)
+
+	// without a recorder, none is set
+	objStore := &eventRecordingObjectStore{inMemoryObjectStore: newInMemoryObjectStore("bucket")}
+	_, err := NewObjectBackupStoreGetter(velerotest.NewFakeCredentialsFileStore("", nil)).
+		Get(location, objectStoreGetter{"provider": objStore}, velerotest.NewLogger())
+	require.NoError(t, err)
+	assert.Nil(t, objStore.recorder)
+
+	// events are recorded on the backup storage location
+	_, err = NewObjectBackupStoreGetter(velerotest.NewFakeCredentialsFileStore("", nil), WithEventRecorder(recorder)).
+		Get(location, objectStoreGetter{"provider": objStore}, velerotest.NewLogger())
+	require.NoError(t, err)
+	assert.Equal(t, recorder, objStore.recorder)
+	assert.Equal(t, location, objStore.object)
+}
+
+// mutableCredentialsFileStore is a credentials.FileStore which returns whatever path is currently set.
+type mutableCredentialsFileStore struct {
+	path string
+}
+
+func (s *mutableCredentialsFileStore) Path(*corev1api.SecretKeySelector) (string, error) {
+	return s.path, nil
+}
+
 func encodeToBytes(obj runtime.Object) []byte {
 	res, err := encode.Encode(obj, "json")
 	if err != nil {
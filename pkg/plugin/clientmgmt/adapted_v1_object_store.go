@@ -0,0 +1,314 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+// adaptedV1ObjectStore adapts a v1 ObjectStore plugin to the v2 ObjectStore interface. The context-aware
+// methods call through to their v1 equivalents without the context, but stop waiting for them once it's
+// done, and methods which have no v1 equivalent return osv2.ErrUnsupported.
+type adaptedV1ObjectStore struct {
+	velero.ObjectStore
+	logger logrus.FieldLogger
+}
+
+// newAdaptedV1ObjectStore returns a v2 ObjectStore backed by the v1 objectStore.
+func newAdaptedV1ObjectStore(objectStore velero.ObjectStore, logger logrus.FieldLogger) *adaptedV1ObjectStore {
+	return &adaptedV1ObjectStore{
+		ObjectStore: objectStore,
+		logger:      logger,
+	}
+}
+
+func (a *adaptedV1ObjectStore) InitV2(ctx context.Context, config map[string]string) error {
+	return a.Init(config)
+}
+
+func (a *adaptedV1ObjectStore) PutObjectV2(ctx context.Context, bucket, key string, body io.Reader) error {
+	_, err := callV1(ctx, func() (interface{}, error) {
+		return nil, a.PutObject(bucket, key, body)
+	}, nil)
+	return err
+}
+
+func (a *adaptedV1ObjectStore) ObjectExistsV2(ctx context.Context, bucket, key string) (bool, error) {
+	exists, err := callV1(ctx, func() (interface{}, error) {
+		return a.ObjectExists(bucket, key)
+	}, nil)
+	found, _ := exists.(bool)
+	return found, err
+}
+
+func (a *adaptedV1ObjectStore) GetObjectV2(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	body, err := callV1(ctx, func() (interface{}, error) {
+		return a.GetObject(bucket, key)
+	}, closeReadCloser)
+	rc, _ := body.(io.ReadCloser)
+	return rc, err
+}
+
+func (a *adaptedV1ObjectStore) ListCommonPrefixesV2(ctx context.Context, bucket, prefix, delimiter string) ([]string, error) {
+	prefixes, err := callV1(ctx, func() (interface{}, error) {
+		return a.ListCommonPrefixes(bucket, prefix, delimiter)
+	}, nil)
+	res, _ := prefixes.([]string)
+	return res, err
+}
+
+func (a *adaptedV1ObjectStore) ListObjectsV2(ctx context.Context, bucket, prefix string) ([]string, error) {
+	keys, err := callV1(ctx, func() (interface{}, error) {
+		return a.ListObjects(bucket, prefix)
+	}, nil)
+	res, _ := keys.([]string)
+	return res, err
+}
+
+func (a *adaptedV1ObjectStore) DeleteObjectV2(ctx context.Context, bucket, key string) error {
+	_, err := callV1(ctx, func() (interface{}, error) {
+		return nil, a.DeleteObject(bucket, key)
+	}, nil)
+	return err
+}
+
+// CreateSignedURLV2 creates the URL through the v1 API, which only creates GET URLs and has no way to carry
+// response header overrides, so any overrides in opts are ignored.
+func (a *adaptedV1ObjectStore) CreateSignedURLV2(ctx context.Context, bucket, key string, ttl time.Duration, opts osv2.SignedURLOptions) (string, error) {
+	if method := opts.HTTPMethod(); method != osv2.SignedURLMethodGet {
+		return "", errors.Wrapf(osv2.ErrUnsupported, "v1 object store plugins can't create signed URLs for %s", method)
+	}
+	a.ignoreResponseOverrides(bucket, key, opts)
+
+	url, err := callV1(ctx, func() (interface{}, error) {
+		return a.CreateSignedURL(bucket, key, ttl)
+	}, nil)
+	res, _ := url.(string)
+	return res, err
+}
+
+// ignoreResponseOverrides logs that the response header overrides in opts, if any, are ignored.
+func (a *adaptedV1ObjectStore) ignoreResponseOverrides(bucket, key string, opts osv2.SignedURLOptions) {
+	if len(opts.ResponseHeaderOverrides()) > 0 && a.logger != nil {
+		a.logger.WithFields(logrus.Fields{
+			"bucket": bucket,
+			"key":    key,
+		}).Debug("Object store plugin does not support response header overrides for signed URLs, ignoring them")
+	}
+}
+
+// ObjectsExist has no v1 equivalent, so each key is checked with a separate ObjectExists call.
+func (a *adaptedV1ObjectStore) ObjectsExist(ctx context.Context, bucket string, keys []string) (map[string]bool, error) {
+	exists := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := a.ObjectExists(bucket, key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error checking if object %s exists", key)
+		}
+		exists[key] = found
+	}
+	return exists, nil
+}
+
+// signedURLsParallelism is the number of CreateSignedURL calls CreateSignedURLs makes on a v1 plugin at once.
+const signedURLsParallelism = 8
+
+// CreateSignedURLs has no v1 equivalent, so the URLs are created one key at a time, with up to
+// signedURLsParallelism calls in flight. No more calls are made once ctx is done.
+func (a *adaptedV1ObjectStore) CreateSignedURLs(ctx context.Context, bucket string, keys []string, ttl time.Duration) (map[string]string, error) {
+	type result struct {
+		key string
+		url string
+		err error
+	}
+
+	keyCh := make(chan string)
+	results := make(chan result, len(keys))
+
+	var wg sync.WaitGroup
+	for i := 0; i < signedURLsParallelism && i < len(keys); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keyCh {
+				if err := ctx.Err(); err != nil {
+					results <- result{key: key, err: err}
+					continue
+				}
+				url, err := a.CreateSignedURL(bucket, key, ttl)
+				results <- result{key: key, url: url, err: err}
+			}
+		}()
+	}
+	for _, key := range keys {
+		keyCh <- key
+	}
+	close(keyCh)
+	wg.Wait()
+	close(results)
+
+	urls := make(map[string]string, len(keys))
+	for res := range results {
+		if res.err != nil {
+			return nil, errors.Wrapf(res.err, "error creating signed URL for key %s", res.key)
+		}
+		urls[res.key] = res.url
+	}
+	return urls, nil
+}
+
+// GetReplicationStatus is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) GetReplicationStatus(ctx context.Context, bucket, key string) (osv2.ReplicationStatus, error) {
+	return "", osv2.ErrUnsupported
+}
+
+// RestoreArchivedObject is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) RestoreArchivedObject(ctx context.Context, bucket, key, tier string) error {
+	return osv2.ErrUnsupported
+}
+
+// AppendObject is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) AppendObject(ctx context.Context, bucket, key string, body io.Reader) error {
+	return osv2.ErrUnsupported
+}
+
+// GetBucketVersioning is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) GetBucketVersioning(ctx context.Context, bucket string) (bool, error) {
+	return false, osv2.ErrUnsupported
+}
+
+// ListObjectVersions is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) ListObjectVersions(ctx context.Context, bucket, prefix string) ([]osv2.ObjectVersion, error) {
+	return nil, osv2.ErrUnsupported
+}
+
+// DeleteObjectVersion is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) DeleteObjectVersion(ctx context.Context, bucket, key, versionID string) error {
+	return osv2.ErrUnsupported
+}
+
+// GetObjectIfModifiedSince can't make the read conditional for a v1 plugin, which doesn't
+// report modification times, so it always retrieves the object.
+func (a *adaptedV1ObjectStore) GetObjectIfModifiedSince(ctx context.Context, bucket, key string, since time.Time) (io.ReadCloser, bool, error) {
+	body, err := a.GetObjectV2(ctx, bucket, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return body, true, nil
+}
+
+// ListObjectsByTag is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) ListObjectsByTag(ctx context.Context, bucket string, tags map[string]string) ([]string, error) {
+	return nil, osv2.ErrUnsupported
+}
+
+// PutObjectWithMetadata is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) PutObjectWithMetadata(ctx context.Context, bucket, key string, body io.Reader, metadata map[string]string) error {
+	return osv2.ErrUnsupported
+}
+
+// GetObjectChecksum is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) GetObjectChecksum(ctx context.Context, bucket, key string) (string, error) {
+	return "", osv2.ErrUnsupported
+}
+
+// ListObjectsInfo is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) ListObjectsInfo(ctx context.Context, bucket, prefix string) (map[string]osv2.ObjectInfo, error) {
+	return nil, osv2.ErrUnsupported
+}
+
+// MoveObject is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) MoveObject(ctx context.Context, bucket, srcKey, dstKey string) error {
+	return osv2.ErrUnsupported
+}
+
+// CreateMultipartUpload is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	return "", osv2.ErrUnsupported
+}
+
+// UploadPart is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, body io.Reader) (string, error) {
+	return "", osv2.ErrUnsupported
+}
+
+// CompleteMultipartUpload is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []osv2.CompletedPart) error {
+	return osv2.ErrUnsupported
+}
+
+// AbortMultipartUpload is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return osv2.ErrUnsupported
+}
+
+// DeleteObjectsV2 is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) DeleteObjectsV2(ctx context.Context, bucket string, keys []string) ([]osv2.DeleteError, error) {
+	return nil, osv2.ErrUnsupported
+}
+
+// GetObjectInfoV2 is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) GetObjectInfoV2(ctx context.Context, bucket, key string) (osv2.ObjectInfo, error) {
+	return osv2.ObjectInfo{}, osv2.ErrUnsupported
+}
+
+// GetObjectRangeV2 can't ask a v1 plugin for part of an object, so it doesn't read the object at all, and
+// leaves it to callers to decide whether to retrieve the whole object, e.g. with osv2.ReadRange.
+func (a *adaptedV1ObjectStore) GetObjectRangeV2(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	return nil, osv2.ErrUnsupported
+}
+
+// CopyObjectV2 can't copy objects without them passing through Velero for a v1 plugin, so it doesn't copy them
+// at all, and leaves it to callers to decide whether to stream them.
+func (a *adaptedV1ObjectStore) CopyObjectV2(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return osv2.ErrCopyNotSupported
+}
+
+// ListObjectsPaged can't ask a v1 plugin for a page of a listing, so every key is listed up front and
+// returned a page at a time.
+func (a *adaptedV1ObjectStore) ListObjectsPaged(ctx context.Context, bucket, prefix string, pageSize int) (osv2.ObjectIterator, error) {
+	keys, err := a.ListObjectsV2(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return osv2.NewSliceObjectIterator(keys, pageSize), nil
+}
+
+// PutObjectWithChecksumV2 can't send the checksum to a v1 plugin, so the checksum is only computed as the body
+// is uploaded with PutObjectV2.
+func (a *adaptedV1ObjectStore) PutObjectWithChecksumV2(ctx context.Context, bucket, key string, body io.Reader, algorithm osv2.ChecksumAlgorithm) (string, error) {
+	return putWithChecksum(body, algorithm, func(body io.Reader) error {
+		return a.PutObjectV2(ctx, bucket, key, body)
+	})
+}
+
+// GetStorageUsageV2 is not part of the v1 API, so there is no way to ask a v1 plugin for it.
+func (a *adaptedV1ObjectStore) GetStorageUsageV2(ctx context.Context, bucket, prefix string) (osv2.StorageUsage, error) {
+	return osv2.StorageUsage{}, osv2.ErrUnsupported
+}
@@ -0,0 +1,189 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	providermocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/mocks"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestAdaptedV1ObjectStore(t *testing.T) {
+	ctx := context.Background()
+
+	objectStore := new(providermocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+
+	a := newAdaptedV1ObjectStore(objectStore, test.NewLogger())
+
+	config := map[string]string{"color": "blue"}
+	objectStore.On("Init", config).Return(nil)
+	require.NoError(t, a.InitV2(ctx, config))
+
+	body := strings.NewReader("body")
+	objectStore.On("PutObject", "bucket", "key", body).Return(errors.New("put error"))
+	assert.EqualError(t, a.PutObjectV2(ctx, "bucket", "key", body), "put error")
+
+	objectStore.On("ObjectExists", "bucket", "key").Return(true, nil)
+	exists, err := a.ObjectExistsV2(ctx, "bucket", "key")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	objectStore.On("ListCommonPrefixes", "bucket", "prefix", "/").Return([]string{"prefix/a/"}, nil)
+	prefixes, err := a.ListCommonPrefixesV2(ctx, "bucket", "prefix", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prefix/a/"}, prefixes)
+
+	objectStore.On("DeleteObject", "bucket", "key").Return(nil)
+	assert.NoError(t, a.DeleteObjectV2(ctx, "bucket", "key"))
+
+	objectStore.On("CreateSignedURL", "bucket", "key", time.Minute).Return("url", nil)
+	url, err := a.CreateSignedURLV2(ctx, "bucket", "key", time.Minute, osv2.SignedURLOptions{
+		ResponseHeaders: map[string]string{"Content-Disposition": `attachment; filename="backup.tar.gz"`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "url", url)
+
+	// v1 plugins only create GET URLs
+	_, err = a.CreateSignedURLV2(ctx, "bucket", "key", time.Minute, osv2.SignedURLOptions{Method: osv2.SignedURLMethodPut})
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	// response header overrides can't be passed to a v1 plugin, so they're dropped
+	url, err = a.CreateSignedURLV2(ctx, "bucket", "key", time.Minute, osv2.SignedURLOptions{
+		ResponseContentDisposition: `attachment; filename="backup-logs.gz"`,
+		ResponseContentType:        "application/gzip",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "url", url)
+
+	objectStore.On("ObjectExists", "bucket", "key-1").Return(true, nil)
+	objectStore.On("ObjectExists", "bucket", "key-2").Return(false, nil)
+	existing, err := a.ObjectsExist(ctx, "bucket", []string{"key-1", "key-2"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"key-1": true, "key-2": false}, existing)
+
+	// batched signed URLs are created one key at a time
+	var keys []string
+	expectedURLs := map[string]string{}
+	for i := 0; i < 2*signedURLsParallelism; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		keys = append(keys, key)
+		expectedURLs[key] = "url-" + key
+		objectStore.On("CreateSignedURL", "bucket", key, time.Minute).Return("url-"+key, nil)
+	}
+	urls, err := a.CreateSignedURLs(ctx, "bucket", keys, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, expectedURLs, urls)
+
+	objectStore.On("CreateSignedURL", "bucket", "bad-key", time.Minute).Return("", errors.New("signing error"))
+	_, err = a.CreateSignedURLs(ctx, "bucket", []string{"key-0", "bad-key"}, time.Minute)
+	assert.EqualError(t, err, "error creating signed URL for key bad-key: signing error")
+
+	// methods added in v2 can't be served by a v1 plugin
+	_, err = a.GetReplicationStatus(ctx, "bucket", "key")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	err = a.RestoreArchivedObject(ctx, "bucket", "key", "Bulk")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	err = a.AppendObject(ctx, "bucket", "key", strings.NewReader("more"))
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.GetBucketVersioning(ctx, "bucket")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.ListObjectVersions(ctx, "bucket", "prefix")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	err = a.DeleteObjectVersion(ctx, "bucket", "key", "v1")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	// a v1 plugin can't tell whether the object was modified, so it's always retrieved
+	objectStore.On("GetObject", "bucket", "manifest").Return(ioutil.NopCloser(strings.NewReader("manifest")), nil)
+	rc, modified, err := a.GetObjectIfModifiedSince(ctx, "bucket", "manifest", time.Now())
+	require.NoError(t, err)
+	assert.True(t, modified)
+	assert.NotNil(t, rc)
+
+	_, err = a.ListObjectsByTag(ctx, "bucket", map[string]string{"retention-class": "expired"})
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	err = a.PutObjectWithMetadata(ctx, "bucket", "key", strings.NewReader("body"), map[string]string{osv2.ChecksumMetadataKey: "digest"})
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.GetObjectChecksum(ctx, "bucket", "key")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.ListObjectsInfo(ctx, "bucket", "backups/")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	err = a.MoveObject(ctx, "bucket", "staging/b1/b1.tar.gz", "backups/b1/b1.tar.gz")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.CreateMultipartUpload(ctx, "bucket", "key")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.DeleteObjectsV2(context.Background(), "bucket", []string{"key1", "key2"})
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.GetObjectInfoV2(context.Background(), "bucket", "key")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	_, err = a.GetObjectRangeV2(context.Background(), "bucket", "backups/b1/b1.tar.gz", 7, 3)
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	err = a.CopyObjectV2(context.Background(), "src-bucket", "backups/b1/b1.tar.gz", "dst-bucket", "backups/b1/b1.tar.gz")
+	assert.True(t, errors.Is(err, osv2.ErrCopyNotSupported))
+
+	objectStore.On("ListObjects", "bucket", "backups/").Return([]string{"backups/b1", "backups/b2", "backups/b3"}, nil)
+	it, err := a.ListObjectsPaged(context.Background(), "bucket", "backups/", 2)
+	require.NoError(t, err)
+	page, err := it.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b1", "backups/b2"}, page)
+	page, err = it.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b3"}, page)
+	_, err = it.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+
+	objectStore.On("PutObject", "bucket", "backups/b1/b1.tar.gz", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		_, err := ioutil.ReadAll(args.Get(2).(io.Reader))
+		require.NoError(t, err)
+	})
+	checksum, err := a.PutObjectWithChecksumV2(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup"), osv2.ChecksumCRC32C)
+	require.NoError(t, err)
+	assert.Equal(t, "6aa81f75", checksum)
+
+	// the storage usage isn't computed by listing the objects with the v1 API
+	_, err = a.GetStorageUsageV2(context.Background(), "bucket", "backups/b1/")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+}
@@ -0,0 +1,191 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/backup"
+	"github.com/vmware-tanzu/velero/pkg/builder"
+	"github.com/vmware-tanzu/velero/pkg/client"
+	"github.com/vmware-tanzu/velero/pkg/discovery"
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// backupItemActionHarness runs backups of fake API resources with backup item actions dispensed in-process by a
+// fakeRestartableProcess, and uploads them through a restartableObjectStore to a FakeObjectStore, so that tests
+// can check what actions did to the items which end up in object storage without running plugin processes.
+type backupItemActionHarness struct {
+	*test.APIServer
+	discoveryHelper discovery.Helper
+	backupper       backup.Backupper
+	process         *fakeRestartableProcess
+	objectStore     *test.FakeObjectStore
+	log             logrus.FieldLogger
+}
+
+func newBackupItemActionHarness(t *testing.T) *backupItemActionHarness {
+	t.Helper()
+
+	apiServer := test.NewAPIServer(t)
+	log := test.NewLogger()
+
+	discoveryHelper, err := discovery.NewHelper(apiServer.DiscoveryClient, log)
+	require.NoError(t, err)
+
+	backupper, err := backup.NewKubernetesBackupper(apiServer.VeleroClient.VeleroV1(), discoveryHelper,
+		client.NewDynamicFactory(apiServer.DynamicClient), nil, nil, 0, false, 0)
+	require.NoError(t, err)
+
+	objectStore := test.NewFakeObjectStore("bucket")
+	return &backupItemActionHarness{
+		APIServer:       apiServer,
+		discoveryHelper: discoveryHelper,
+		backupper:       backupper,
+		process:         newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore),
+		objectStore:     objectStore,
+		log:             log,
+	}
+}
+
+// addItems makes the items of resource available to be backed up.
+func (h *backupItemActionHarness) addItems(t *testing.T, resource *test.APIResource) {
+	t.Helper()
+
+	h.DiscoveryClient.WithAPIResource(resource)
+	require.NoError(t, h.discoveryHelper.Refresh())
+
+	for _, item := range resource.Items {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(item)
+		require.NoError(t, err)
+
+		resourceClient := h.DynamicClient.Resource(resource.GVR())
+		if resource.Namespaced {
+			_, err = resourceClient.Namespace(item.GetNamespace()).Create(context.TODO(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{})
+		} else {
+			_, err = resourceClient.Create(context.TODO(), &unstructured.Unstructured{Object: obj}, metav1.CreateOptions{})
+		}
+		require.NoError(t, err)
+	}
+}
+
+// registerAction dispenses action as the backup item action with the given name, returning the restartable
+// action that backups are run with.
+func (h *backupItemActionHarness) registerAction(name string, action velero.BackupItemAction) velero.BackupItemAction {
+	h.process.dispense(framework.PluginKindBackupItemAction, name, action)
+	return newRestartableBackupItemAction(name, h.process)
+}
+
+// backup runs backup with actions, uploads its contents to the object store and returns the items that were
+// stored, keyed by their path in the backup tarball, e.g. "resources/pods/namespaces/ns-1/pod-1.json".
+func (h *backupItemActionHarness) backup(t *testing.T, backupObj *velerov1api.Backup, actions ...velero.BackupItemAction) map[string]*unstructured.Unstructured {
+	t.Helper()
+
+	backupFile := new(bytes.Buffer)
+	require.NoError(t, h.backupper.Backup(h.log, &backup.Request{Backup: backupObj}, backupFile, actions, nil))
+
+	objectStore := newRestartableObjectStore("fake", h.process, h.log)
+	require.NoError(t, objectStore.Init(map[string]string{}))
+	key := path.Join("backups", backupObj.Name, backupObj.Name+".tar.gz")
+	require.NoError(t, objectStore.PutObject("bucket", key, backupFile))
+
+	rc, err := h.objectStore.GetObject("bucket", key)
+	require.NoError(t, err)
+	defer rc.Close()
+	gzr, err := gzip.NewReader(rc)
+	require.NoError(t, err)
+	defer gzr.Close()
+
+	items := make(map[string]*unstructured.Unstructured)
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag != tar.TypeReg || !strings.HasPrefix(header.Name, "resources/") {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		item := new(unstructured.Unstructured)
+		require.NoError(t, json.Unmarshal(data, &item.Object))
+		items[header.Name] = item
+	}
+	return items
+}
+
+// labelingAction labels the items it is executed for.
+type labelingAction struct {
+	selector velero.ResourceSelector
+	labels   map[string]string
+}
+
+func (a *labelingAction) AppliesTo() (velero.ResourceSelector, error) {
+	return a.selector, nil
+}
+
+func (a *labelingAction) Execute(item runtime.Unstructured, backup *velerov1api.Backup) (runtime.Unstructured, []velero.ResourceIdentifier, error) {
+	obj := &unstructured.Unstructured{Object: item.UnstructuredContent()}
+	res := obj.DeepCopy()
+	res.SetLabels(a.labels)
+	return res, nil, nil
+}
+
+func TestBackupItemActionHarness(t *testing.T) {
+	h := newBackupItemActionHarness(t)
+	h.addItems(t, test.Pods(
+		builder.ForPod("ns-1", "pod-1").Result(),
+	))
+	h.addItems(t, test.Secrets(
+		builder.ForSecret("ns-1", "secret-1").Result(),
+	))
+
+	action := h.registerAction("velero.io/label-pods", &labelingAction{
+		selector: velero.ResourceSelector{IncludedResources: []string{"pods"}},
+		labels:   map[string]string{"backed-up": "true"},
+	})
+	items := h.backup(t, builder.ForBackup(velerov1api.DefaultNamespace, "backup-1").Result(), action)
+
+	require.Contains(t, items, "resources/pods/namespaces/ns-1/pod-1.json")
+	assert.Equal(t, map[string]string{"backed-up": "true"}, items["resources/pods/namespaces/ns-1/pod-1.json"].GetLabels())
+
+	// items the action doesn't apply to are stored unchanged
+	require.Contains(t, items, "resources/secrets/namespaces/ns-1/secret-1.json")
+	assert.Empty(t, items["resources/secrets/namespaces/ns-1/secret-1.json"].GetLabels())
+}
@@ -0,0 +1,111 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+// putObjectWithChecksum writes body to the object with the given key like PutObjectV2, storing the SHA-256
+// digest of body in the object's metadata under osv2.ChecksumMetadataKey. As the digest must be known before
+// the upload starts, body is spooled to a temporary file while it is hashed. If the plugin doesn't support
+// custom metadata the object is written without its digest.
+func (r *restartableObjectStore) putObjectWithChecksum(ctx context.Context, delegate osv2.ObjectStore, bucket, key string, body io.Reader) error {
+	file, err := ioutil.TempFile("", "velero-object-")
+	if err != nil {
+		return errors.Wrap(err, "error creating temp file to checksum object")
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), body); err != nil {
+		return errors.Wrap(err, "error checksumming object")
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	metadata := map[string]string{osv2.ChecksumMetadataKey: checksum}
+	err = delegate.PutObjectWithMetadata(ctx, bucket, r.storedKey(key), defaultObjectStoreMetrics.countUploaded(ctx, file), metadata)
+	if !errors.Is(err, osv2.ErrUnsupported) {
+		return err
+	}
+
+	if r.logger != nil {
+		r.logger.WithField("key", key).Debug("Object store plugin doesn't support custom metadata, writing object without its checksum")
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return errors.WithStack(err)
+	}
+	return delegate.PutObjectV2(ctx, bucket, r.storedKey(key), defaultObjectStoreMetrics.countUploaded(ctx, file))
+}
+
+// readTracker records whether anything was read from Reader.
+type readTracker struct {
+	io.Reader
+	read bool
+}
+
+func (t *readTracker) Read(p []byte) (int, error) {
+	n, err := t.Reader.Read(p)
+	if n > 0 {
+		t.read = true
+	}
+	return n, err
+}
+
+// rewinder returns a func which seeks body back to its current offset, or nil if body can't seek.
+func rewinder(body io.Reader) func() error {
+	seeker, ok := body.(io.Seeker)
+	if !ok {
+		return nil
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+	return func() error {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return errors.Wrap(err, "error rewinding the body")
+	}
+}
+
+// putWithChecksum calls put with body, computing the checksum of body with algorithm as put reads it, for plugins
+// which can't compute checksums themselves.
+func putWithChecksum(body io.Reader, algorithm osv2.ChecksumAlgorithm, put func(io.Reader) error) (string, error) {
+	hash, err := algorithm.NewHash()
+	if err != nil {
+		return "", err
+	}
+	if err := put(io.TeeReader(body, hash)); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
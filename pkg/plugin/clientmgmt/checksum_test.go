@@ -0,0 +1,176 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// sha256 of "backup"
+const backupChecksum = "54d00d867758cef816bc4685f58e327b949712b07ebd17c3485f3ffc9e9f5133"
+
+func TestRestartableObjectStoreVerifyChecksums(t *testing.T) {
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "aws", objectStore)
+	r := newRestartableObjectStore("aws", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{verifyChecksumsConfigKey: "true"}))
+	assert.True(t, r.currentConfig().verifyChecksums)
+
+	var written string
+	objectStore.On("PutObjectWithMetadata", mock.Anything, "bucket", "key", mock.Anything, map[string]string{osv2.ChecksumMetadataKey: backupChecksum}).
+		Run(func(args mock.Arguments) {
+			data, err := ioutil.ReadAll(args.Get(3).(io.Reader))
+			require.NoError(t, err)
+			written = string(data)
+		}).Return(nil)
+	require.NoError(t, r.PutObject("bucket", "key", strings.NewReader("backup")))
+	assert.Equal(t, "backup", written)
+
+	objectStore.On("GetObjectChecksum", mock.Anything, "bucket", "key").Return(backupChecksum, nil)
+	checksum, err := r.GetObjectChecksum(context.Background(), "bucket", "key")
+	require.NoError(t, err)
+	assert.Equal(t, backupChecksum, checksum)
+}
+
+func TestRestartableObjectStoreVerifyChecksumsWithoutMetadataSupport(t *testing.T) {
+	// a v1 plugin has no custom metadata, so objects are written without their checksum
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{verifyChecksumsConfigKey: "true"}))
+
+	require.NoError(t, r.PutObject("bucket", "key", strings.NewReader("backup")))
+
+	rc, err := objectStore.GetObject("bucket", "key")
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(data))
+
+	_, err = r.GetObjectChecksum(context.Background(), "bucket", "key")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+}
+
+func TestRestartableObjectStorePutObjectWithChecksumV2(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	checksum, err := r.PutObjectWithChecksumV2(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup"), osv2.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, backupChecksum, checksum)
+
+	rc, err := r.GetObject("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	verified, err := osv2.NewVerifyingReader(rc, osv2.ChecksumSHA256, checksum)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadAll(verified)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(contents))
+	require.NoError(t, verified.Close())
+
+	// an object truncated in transit doesn't match its checksum
+	require.NoError(t, r.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader("back")))
+	rc, err = r.GetObject("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	verified, err = osv2.NewVerifyingReader(rc, osv2.ChecksumSHA256, checksum)
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(verified)
+	var mismatch *osv2.ChecksumMismatchError
+	require.True(t, errors.As(err, &mismatch), "unexpected error %v", err)
+	assert.Equal(t, backupChecksum, mismatch.Expected)
+
+	_, err = r.PutObjectWithChecksumV2(context.Background(), "bucket", "key", strings.NewReader("backup"), "MD5")
+	assert.EqualError(t, err, `PutObjectWithChecksum bucket/key: unsupported checksum algorithm "MD5"`)
+}
+
+func TestRestartableObjectStorePutObjectWithChecksumV2Unsupported(t *testing.T) {
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	objectStore.On("PutObjectWithChecksumV2", mock.Anything, "bucket", "key", mock.Anything, osv2.ChecksumSHA256).Return("", osv2.ErrUnsupported)
+	var written string
+	objectStore.On("PutObjectV2", mock.Anything, "bucket", "key", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		contents, err := ioutil.ReadAll(args.Get(3).(io.Reader))
+		require.NoError(t, err)
+		written = string(contents)
+	})
+
+	checksum, err := r.PutObjectWithChecksumV2(context.Background(), "bucket", "key", strings.NewReader("backup"), osv2.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, backupChecksum, checksum)
+	assert.Equal(t, "backup", written)
+}
+
+func TestRestartableObjectStorePutObjectWithChecksumV2UnsupportedAfterReading(t *testing.T) {
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	// the plugin reads part of the body before finding out it can't compute the checksum
+	objectStore.On("PutObjectWithChecksumV2", mock.Anything, "bucket", "key", mock.Anything, osv2.ChecksumSHA256).Return("", osv2.ErrUnsupported).Run(func(args mock.Arguments) {
+		_, err := io.ReadFull(args.Get(3).(io.Reader), make([]byte, 3))
+		require.NoError(t, err)
+	})
+	var written string
+	objectStore.On("PutObjectV2", mock.Anything, "bucket", "key", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		contents, err := ioutil.ReadAll(args.Get(3).(io.Reader))
+		require.NoError(t, err)
+		written = string(contents)
+	}).Once()
+
+	// a body which can be rewound is uploaded whole
+	checksum, err := r.PutObjectWithChecksumV2(context.Background(), "bucket", "key", strings.NewReader("backup"), osv2.ChecksumSHA256)
+	require.NoError(t, err)
+	assert.Equal(t, backupChecksum, checksum)
+	assert.Equal(t, "backup", written)
+
+	// one which can't isn't uploaded truncated
+	_, err = r.PutObjectWithChecksumV2(context.Background(), "bucket", "key", ioutil.NopCloser(strings.NewReader("backup")), osv2.ChecksumSHA256)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+}
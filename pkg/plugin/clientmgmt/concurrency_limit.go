@@ -0,0 +1,60 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// maxConcurrentCallsConfigKey is the config key used to set how many operations may be in flight on the plugin at
+// once. Zero, the default, doesn't limit them.
+const maxConcurrentCallsConfigKey = "maxConcurrentCalls"
+
+// acquireCallSlot waits until fewer than maxConcurrentCalls operations are in flight on the plugin, or until ctx is
+// done, and returns a func to call once the operation is done with the plugin. Operations aren't limited unless
+// maxConcurrentCalls is set.
+func (r *restartableObjectStore) acquireCallSlot(ctx context.Context) (func(), error) {
+	slots := r.currentConfig().callSlots
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, errors.Wrap(ctx.Err(), "gave up waiting for a free object store plugin call slot")
+	}
+}
+
+// tryAcquireCallSlot acquires a call slot like acquireCallSlot if one is free right away, and otherwise returns false
+// rather than wait for one.
+func (r *restartableObjectStore) tryAcquireCallSlot() (func(), bool) {
+	slots := r.currentConfig().callSlots
+	if slots == nil {
+		return func() {}, true
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
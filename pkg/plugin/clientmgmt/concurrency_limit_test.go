@@ -0,0 +1,250 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestRestartableObjectStoreMaxConcurrentCalls(t *testing.T) {
+	const limit = 3
+
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "3"}))
+
+	var inFlight, maxInFlight int32
+	objectStore.On("GetObjectV2", mock.Anything, "bucket", "key").Return(ioutil.NopCloser(strings.NewReader("data")), nil).Run(func(mock.Arguments) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, limit+5)
+	for i := 0; i < limit+5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := r.GetObject("bucket", "key")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	objectStore.AssertNumberOfCalls(t, "GetObjectV2", limit+5)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(limit))
+}
+
+func TestRestartableObjectStoreMaxConcurrentCallsCancelled(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "1"}))
+
+	// a call waiting for a free slot gives up once its context is done
+	release, err := r.acquireCallSlot(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = r.GetObjectV2(ctx, "bucket", "key")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+
+	release()
+	objectStore.On("GetObjectV2", mock.Anything, "bucket", "key").Return(ioutil.NopCloser(strings.NewReader("data")), nil).Once()
+	_, err = r.GetObjectV2(context.Background(), "bucket", "key")
+	assert.NoError(t, err)
+	objectStore.AssertExpectations(t)
+}
+
+func TestRestartableObjectStoreMaxConcurrentCallsConfig(t *testing.T) {
+	for _, val := range []string{"-1", "many"} {
+		p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", test.NewFakeObjectStore("bucket"))
+		r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+		assert.EqualError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: val}),
+			`Init: invalid value for config key "maxConcurrentCalls": "`+val+`"`)
+	}
+
+	// zero doesn't limit the calls
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", test.NewFakeObjectStore("bucket"))
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "0"}))
+	assert.Nil(t, r.currentConfig().callSlots)
+}
+
+func TestRestartableObjectStoreMaxConcurrentCallsDedupReads(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "1", dedupReadsConfigKey: "true"}))
+
+	// callers sharing a read in flight don't take a slot, so they get its result rather than queueing behind it
+	// to make calls of their own
+	release := make(chan time.Time)
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "key").Return(osv2.ObjectInfo{Size: 4}, nil).WaitUntil(release).Once()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exists, err := r.ObjectExistsV2(context.Background(), "bucket", "key")
+			assert.NoError(t, err)
+			assert.True(t, exists)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// and the slot is free again once the read is done
+	releaseSlot, err := r.acquireCallSlot(context.Background())
+	require.NoError(t, err)
+	releaseSlot()
+}
+
+func TestRestartableObjectStoreMaxConcurrentCallsUpdateConfig(t *testing.T) {
+	ctx := context.Background()
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", test.NewFakeObjectStore("bucket"))
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "1"}))
+
+	release, err := r.acquireCallSlot(ctx)
+	require.NoError(t, err)
+
+	// the calls in flight keep counting towards an unchanged limit
+	require.NoError(t, r.UpdateConfig(ctx, map[string]string{maxConcurrentCallsConfigKey: "1", sortListingsConfigKey: "true"}))
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = r.acquireCallSlot(timeoutCtx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "unexpected error %v", err)
+	release()
+
+	// operations see a consistent config while it's being updated
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, err := r.ListObjectsV2(ctx, "bucket", "")
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	for _, limit := range []string{"2", "0", "1"} {
+		require.NoError(t, r.UpdateConfig(ctx, map[string]string{maxConcurrentCallsConfigKey: limit}))
+	}
+	wg.Wait()
+	assert.Equal(t, 1, cap(r.currentConfig().callSlots))
+}
+
+func TestRestartableObjectStoreMaxConcurrentCallsReadRetries(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "1", readRetriesConfigKey: "1", readRetryBackoffConfigKey: "1s"}))
+
+	// the slot is released while backing off, so that other calls can use it in the meantime
+	failed := make(chan struct{})
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "prefix").Return(nil, statusCodeError(503)).Run(func(mock.Arguments) {
+		close(failed)
+	}).Once()
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "prefix").Return([]string{"key"}, nil).Once()
+	listed := make(chan error)
+	go func() {
+		_, err := r.ListObjectsV2(context.Background(), "bucket", "prefix")
+		listed <- err
+	}()
+
+	<-failed
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	release, err := r.acquireCallSlot(ctx)
+	require.NoError(t, err)
+	release()
+	assert.NoError(t, <-listed)
+}
+
+func TestRestartableObjectStoreMaxConcurrentCallsHedging(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{maxConcurrentCallsConfigKey: "1", hedgeDelayConfigKey: "10ms"}))
+
+	// the hedged attempt needs a slot of its own, so it isn't made while the first attempt holds the only one
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "prefix").Return([]string{"key"}, nil).After(50 * time.Millisecond).Once()
+	keys, err := r.ListObjectsV2(context.Background(), "bucket", "prefix")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+
+	// and the slot is free again once the call is done
+	releaseSlot, err := r.acquireCallSlot(context.Background())
+	require.NoError(t, err)
+	releaseSlot()
+}
@@ -0,0 +1,69 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+const (
+	// waitForConsistencyConfigKey is the config key used to make writes wait until the written object is visible,
+	// for object stores without read-after-write consistency.
+	waitForConsistencyConfigKey = "waitForConsistency"
+	// consistencyTimeoutConfigKey is the config key used to set how long writes wait for the written object to
+	// become visible, as a duration such as "30s".
+	consistencyTimeoutConfigKey = "consistencyTimeout"
+
+	defaultConsistencyTimeout = 30 * time.Second
+
+	consistencyPollInitialBackoff = 100 * time.Millisecond
+	consistencyPollMaxBackoff     = 2 * time.Second
+)
+
+// waitForObject polls the object store until the object with the given key is visible in bucket, backing off
+// exponentially in between, and fails if it isn't visible within the consistencyTimeout or ctx is done first.
+func (r *restartableObjectStore) waitForObject(ctx context.Context, delegate osv2.ObjectStore, bucket, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.currentConfig().consistencyTimeout)
+	defer cancel()
+
+	backoff := consistencyPollInitialBackoff
+	for {
+		exists, err := objectExists(ctx, delegate, bucket, r.storedKey(key))
+		if err != nil {
+			return errors.Wrapf(err, "error checking whether object %s is visible", key)
+		}
+		if exists {
+			return nil
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Wrapf(ctx.Err(), "object %s did not become visible after being written", key)
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > consistencyPollMaxBackoff {
+			backoff = consistencyPollMaxBackoff
+		}
+	}
+}
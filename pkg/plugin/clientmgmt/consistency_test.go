@@ -0,0 +1,104 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestRestartableObjectStoreWaitForConsistency(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]string
+		visibleAt   int
+		expectedErr string
+	}{
+		{
+			name:   "writes don't wait by default",
+			config: map[string]string{},
+		},
+		{
+			name:      "writes wait until the object is visible",
+			config:    map[string]string{waitForConsistencyConfigKey: "true"},
+			visibleAt: 3,
+		},
+		{
+			name:        "writes fail if the object isn't visible in time",
+			config:      map[string]string{waitForConsistencyConfigKey: "true", consistencyTimeoutConfigKey: "250ms"},
+			visibleAt:   10,
+			expectedErr: "PutObject bucket/backups/b1/b1.tar.gz: object backups/b1/b1.tar.gz did not become visible after being written: context deadline exceeded",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newFakeRestartableProcess()
+			objectStore := new(osv2mocks.ObjectStore)
+			objectStore.Test(t)
+			p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+			r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+			objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+			require.NoError(t, r.Init(tc.config))
+
+			objectStore.On("PutObjectV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz", mock.Anything).Return(nil)
+			for i := 1; i < tc.visibleAt; i++ {
+				objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz").Return(osv2.ObjectInfo{}, osv2.ErrObjectNotFound).Once()
+			}
+			if tc.visibleAt > 0 {
+				objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz").Return(osv2.ObjectInfo{Size: 6}, nil).Once()
+			}
+
+			err := r.PutObjectV2(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup"))
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			objectStore.AssertNumberOfCalls(t, "GetObjectInfoV2", tc.visibleAt)
+		})
+	}
+}
+
+func TestRestartableObjectStoreWaitForConsistencyError(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{waitForConsistencyConfigKey: "true"}))
+
+	objectStore.On("PutObjectV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz", mock.Anything).Return(nil)
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz").Return(osv2.ObjectInfo{}, errors.New("access denied"))
+
+	err := r.PutObjectV2(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup"))
+	assert.EqualError(t, err, "PutObject bucket/backups/b1/b1.tar.gz: error checking whether object backups/b1/b1.tar.gz is visible: access denied")
+}
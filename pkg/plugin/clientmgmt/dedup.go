@@ -0,0 +1,213 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dedupMaxObjectSize is the size of the largest object whose content is shared between concurrent GetObject
+// calls. Callers of larger objects each read their own copy.
+const dedupMaxObjectSize = 1 << 20
+
+// inflightRead is a read whose result is shared by every caller that requested it while it was in flight.
+type inflightRead struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+
+	// waiters is the number of callers waiting for the read, and cancel cancels the read's context once
+	// they've all given up.
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// readDeduplicator collapses concurrent identical reads into a single call whose result is shared, in the
+// manner of golang.org/x/sync/singleflight. The zero value is ready to use.
+type readDeduplicator struct {
+	lock  sync.Mutex
+	reads map[string]*inflightRead
+}
+
+// do calls read and returns its result, unless a read with the same key is already in flight, in which case it
+// waits for that read to finish and returns its result instead. The read gets a context with the values of the
+// ctx of the caller that started it, but which is only cancelled once every caller waiting for it has stopped
+// waiting, so one caller giving up doesn't fail the read for the others. A caller that stops waiting because
+// its ctx is done gets ctx's error. If every caller gives up and the read still returns an io.Closer, it's
+// closed.
+func (d *readDeduplicator) do(ctx context.Context, key string, read func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	d.lock.Lock()
+	call, ok := d.reads[key]
+	if ok {
+		call.waiters++
+	} else {
+		if d.reads == nil {
+			d.reads = make(map[string]*inflightRead)
+		}
+		// the read's context is never done unless cancelled, so it holds nothing once the read is over
+		readCtx, cancel := context.WithCancel(detachedContext{ctx})
+		call = &inflightRead{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		d.reads[key] = call
+		go d.run(readCtx, key, call, read)
+	}
+	d.lock.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	select {
+	case <-call.done:
+		// the read finished as this caller gave up
+		return call.value, call.err
+	default:
+	}
+	call.waiters--
+	if call.waiters == 0 {
+		call.cancel()
+		if d.reads[key] == call {
+			delete(d.reads, key)
+		}
+	}
+	return nil, ctx.Err()
+}
+
+// run calls read with ctx and shares its result with the callers waiting for call.
+func (d *readDeduplicator) run(ctx context.Context, key string, call *inflightRead, read func(ctx context.Context) (interface{}, error)) {
+	value, err := read(ctx)
+
+	d.lock.Lock()
+	call.value, call.err = value, err
+	if d.reads[key] == call {
+		delete(d.reads, key)
+	}
+	close(call.done)
+	abandoned := call.waiters == 0
+	d.lock.Unlock()
+
+	if closer, ok := value.(io.Closer); ok && abandoned {
+		closer.Close()
+	}
+}
+
+// detachedContext has the values of the context it wraps, but neither its deadline nor its cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// dedupObjectExists calls read, sharing its result with concurrent calls for the same object if
+// dedupReads is set.
+func (r *restartableObjectStore) dedupObjectExists(ctx context.Context, bucket, key string, read func(ctx context.Context) (bool, error)) (bool, error) {
+	if !r.currentConfig().dedupReads {
+		return read(ctx)
+	}
+
+	exists, err := r.reads.do(ctx, "ObjectExists\x00"+bucket+"\x00"+key, func(ctx context.Context) (interface{}, error) {
+		return read(ctx)
+	})
+	found, _ := exists.(bool)
+	return found, err
+}
+
+// streamedObject is an object too large to share. Only one of the callers sharing the read that returned it gets
+// the rest of it as a stream; every other caller reads it again.
+type streamedObject struct {
+	rc      io.ReadCloser
+	claimed int32
+}
+
+func (s *streamedObject) Close() error {
+	return s.rc.Close()
+}
+
+// dedupGetObject calls read, sharing the object it returns with concurrent calls for the same object if
+// dedupReads is set. Shared objects are buffered in memory, so objects larger than dedupMaxObjectSize aren't
+// shared: one caller gets the rest of the object as a stream, and every other caller reads it again.
+func (r *restartableObjectStore) dedupGetObject(ctx context.Context, bucket, key string, read func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	if !r.currentConfig().dedupReads {
+		return read(ctx)
+	}
+
+	data, err := r.reads.do(ctx, "GetObject\x00"+bucket+"\x00"+key, func(ctx context.Context) (interface{}, error) {
+		rc, err := read(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if rc == nil {
+			return []byte{}, nil
+		}
+
+		buffered, err := io.ReadAll(io.LimitReader(rc, dedupMaxObjectSize+1))
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+		if len(buffered) > dedupMaxObjectSize {
+			return &streamedObject{rc: &multiReadCloser{Reader: io.MultiReader(bytes.NewReader(buffered), rc), closer: rc}}, nil
+		}
+		return buffered, rc.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch obj := data.(type) {
+	case []byte:
+		return io.NopCloser(bytes.NewReader(obj)), nil
+	case *streamedObject:
+		if atomic.CompareAndSwapInt32(&obj.claimed, 0, 1) {
+			return obj.rc, nil
+		}
+	}
+	// the object is too large to share, and another caller is reading the rest of it
+	return read(ctx)
+}
+
+// multiReadCloser reads from Reader and closes closer.
+type multiReadCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (m *multiReadCloser) Close() error {
+	return m.closer.Close()
+}
@@ -0,0 +1,209 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestReadDeduplicator(t *testing.T) {
+	var d readDeduplicator
+	var calls int32
+	release := make(chan struct{})
+	read := func(context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "value", nil
+	}
+
+	// concurrent reads of the same key share a single call
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := d.do(context.Background(), "key", read)
+			assert.NoError(t, err)
+			assert.Equal(t, "value", value)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// once it's done, the next read makes a new call
+	_, err := d.do(context.Background(), "key", read)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// a caller waiting for a read in flight can give up
+	blocked := make(chan struct{})
+	go d.do(context.Background(), "blocked", func(context.Context) (interface{}, error) {
+		<-blocked
+		return nil, nil
+	})
+	defer close(blocked)
+	time.Sleep(10 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.do(ctx, "blocked", read)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestReadDeduplicatorDetachedContext(t *testing.T) {
+	var d readDeduplicator
+	type ctxKey struct{}
+
+	// the read keeps going for the other callers when the caller that started it gives up
+	release := make(chan struct{})
+	started := make(chan struct{})
+	leaderCtx, cancelLeader := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "leader"))
+	leaderErr := make(chan error)
+	go func() {
+		_, err := d.do(leaderCtx, "key", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			select {
+			case <-release:
+				return ctx.Value(ctxKey{}), ctx.Err()
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+		leaderErr <- err
+	}()
+	<-started
+
+	followerValue := make(chan interface{})
+	go func() {
+		// the read in flight is shared, so this caller's read is never called
+		value, err := d.do(context.Background(), "key", nil)
+		assert.NoError(t, err)
+		followerValue <- value
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelLeader()
+	assert.Equal(t, context.Canceled, <-leaderErr)
+	close(release)
+	assert.Equal(t, "leader", <-followerValue)
+
+	// the read is cancelled once every caller has given up
+	cancelled := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := d.do(ctx, "key", func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, context.Canceled, err)
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the read wasn't cancelled")
+	}
+}
+
+func TestRestartableObjectStoreDedupReads(t *testing.T) {
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "aws", objectStore)
+	r := newRestartableObjectStore("aws", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{dedupReadsConfigKey: "true"}))
+	assert.True(t, r.currentConfig().dedupReads)
+
+	// runConcurrently calls fn n times concurrently, letting the plugin return once all calls have started.
+	runConcurrently := func(n int, release chan time.Time, fn func()) {
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				fn()
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+	}
+
+	release := make(chan time.Time)
+	objectStore.On("GetObjectV2", mock.Anything, "bucket", "metadata").
+		Return(io.NopCloser(strings.NewReader("metadata")), nil).
+		WaitUntil(release).Once()
+	runConcurrently(5, release, func() {
+		rc, err := r.GetObject("bucket", "metadata")
+		if !assert.NoError(t, err) {
+			return
+		}
+		data, err := io.ReadAll(rc)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "metadata", string(data))
+		assert.NoError(t, rc.Close())
+	})
+
+	release = make(chan time.Time)
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "metadata").Return(osv2.ObjectInfo{Size: 8}, nil).WaitUntil(release).Once()
+	runConcurrently(5, release, func() {
+		exists, err := r.ObjectExists("bucket", "metadata")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, exists)
+	})
+
+	// objects too large to buffer are read separately by each caller
+	large := strings.Repeat("x", dedupMaxObjectSize+1)
+	release = make(chan time.Time)
+	objectStore.On("GetObjectV2", mock.Anything, "bucket", "large").
+		Return(func(context.Context, string, string) io.ReadCloser { return io.NopCloser(strings.NewReader(large)) }, nil).
+		WaitUntil(release).Twice()
+	runConcurrently(2, release, func() {
+		rc, err := r.GetObject("bucket", "large")
+		if !assert.NoError(t, err) {
+			return
+		}
+		data, err := io.ReadAll(rc)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, len(large), len(data))
+		assert.NoError(t, rc.Close())
+	})
+}
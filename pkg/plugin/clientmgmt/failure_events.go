@@ -0,0 +1,104 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+	corev1api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+const (
+	// failureEventThresholdConfigKey is the config key used to set after how many consecutive failed operations
+	// a Warning event is recorded.
+	failureEventThresholdConfigKey = "failureEventThreshold"
+
+	defaultFailureEventThreshold = 5
+
+	// Reasons of the events recorded about sustained failures.
+	objectStoreFailingReason   = "ObjectStoreFailing"
+	objectStoreRecoveredReason = "ObjectStoreRecovered"
+)
+
+// failureEvents tracks the consecutive failures of an object store's operations, to record events when they
+// start failing persistently and when they recover.
+type failureEvents struct {
+	lock     sync.Mutex
+	recorder record.EventRecorder
+	// object is the object events are recorded on, typically the backup storage location the object store
+	// serves.
+	object runtime.Object
+	// consecutive is how many operations have failed in a row.
+	consecutive int
+	// failing indicates whether a Warning event has been recorded and no operation has succeeded since.
+	failing bool
+}
+
+// SetEventRecorder makes r record a Warning event on object with recorder once failureEventThreshold operations
+// in a row have failed, and a Normal event when an operation succeeds again. A nil recorder stops recording
+// events.
+func (r *restartableObjectStore) SetEventRecorder(recorder record.EventRecorder, object runtime.Object) {
+	r.failureEvents.lock.Lock()
+	defer r.failureEvents.lock.Unlock()
+
+	r.failureEvents.recorder = recorder
+	r.failureEvents.object = object
+}
+
+// recordOutcome counts the outcome of an operation towards the consecutive failures, recording an event if
+// the operations just started failing persistently or just recovered.
+func (r *restartableObjectStore) recordOutcome(err error) {
+	if err != nil && !countsAsFailure(err) {
+		return
+	}
+
+	r.failureEvents.lock.Lock()
+	defer r.failureEvents.lock.Unlock()
+
+	if err == nil {
+		if r.failureEvents.failing && r.failureEvents.recorder != nil {
+			r.failureEvents.recorder.Eventf(r.failureEvents.object, corev1api.EventTypeNormal, objectStoreRecoveredReason,
+				"Object store %s recovered after %d consecutive failed operations", r.key.name, r.failureEvents.consecutive)
+		}
+		r.failureEvents.consecutive = 0
+		r.failureEvents.failing = false
+		return
+	}
+
+	r.failureEvents.consecutive++
+	if r.failureEvents.failing || r.failureEvents.consecutive < r.currentConfig().failureEventThreshold || r.failureEvents.recorder == nil {
+		return
+	}
+	r.failureEvents.recorder.Eventf(r.failureEvents.object, corev1api.EventTypeWarning, objectStoreFailingReason,
+		"Object store %s failed %d consecutive operations, the last with: %v", r.key.name, r.failureEvents.consecutive, err)
+	r.failureEvents.failing = true
+}
+
+// countsAsFailure returns whether the non-nil err indicates that the object store is failing, rather than that the caller
+// asked for something that doesn't exist or isn't supported, or gave up waiting.
+func countsAsFailure(err error) bool {
+	return !errors.Is(err, osv2.ErrObjectNotFound) &&
+		!errors.Is(err, osv2.ErrRangeNotSatisfiable) &&
+		!errors.Is(err, osv2.ErrUnsupported) &&
+		!errors.Is(err, context.Canceled)
+}
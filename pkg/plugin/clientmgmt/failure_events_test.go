@@ -0,0 +1,87 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/vmware-tanzu/velero/pkg/builder"
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestRestartableObjectStoreFailureEvents(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{failureEventThresholdConfigKey: "3"}))
+	recorder := record.NewFakeRecorder(10)
+	r.SetEventRecorder(recorder, builder.ForBackupStorageLocation("velero", "default").Result())
+
+	unavailable := errors.New("service unavailable")
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(unavailable).Times(2)
+	// objects which don't exist don't indicate that the object store is failing
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(osv2.ErrObjectNotFound).Once()
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(unavailable).Times(2)
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(nil).Once()
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(unavailable).Once()
+
+	for i := 0; i < 3; i++ {
+		assert.Error(t, r.DeleteObjectV2(context.Background(), "bucket", "key"))
+	}
+	assert.Empty(t, recorder.Events)
+
+	assert.Error(t, r.DeleteObjectV2(context.Background(), "bucket", "key"))
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning ObjectStoreFailing Object store fake failed 3 consecutive operations, the last with: service unavailable", <-recorder.Events)
+
+	// the Warning event is only recorded once while the operations keep failing
+	assert.Error(t, r.DeleteObjectV2(context.Background(), "bucket", "key"))
+	assert.Empty(t, recorder.Events)
+
+	assert.NoError(t, r.DeleteObjectV2(context.Background(), "bucket", "key"))
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Normal ObjectStoreRecovered Object store fake recovered after 4 consecutive failed operations", <-recorder.Events)
+
+	assert.Error(t, r.DeleteObjectV2(context.Background(), "bucket", "key"))
+	assert.Empty(t, recorder.Events)
+	objectStore.AssertExpectations(t)
+}
+
+func TestRestartableObjectStoreFailureEventThreshold(t *testing.T) {
+	for _, val := range []string{"0", "-1", "many"} {
+		p := newFakeRestartableProcess()
+		p.dispense(framework.PluginKindObjectStore, "fake", test.NewFakeObjectStore("bucket"))
+		r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+		assert.EqualError(t, r.Init(map[string]string{failureEventThresholdConfigKey: val}),
+			`Init: invalid value for config key "failureEventThreshold": "`+val+`"`)
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// healthCheckConfigKey is the config key used to enable the probe made by Healthy. It's off by default as
+	// the probe lists the top of the bucket every time the backup storage location is validated.
+	healthCheckConfigKey = "healthCheck"
+	// healthCheckTimeoutConfigKey is the config key used to set how long the plugin may take to answer the probe
+	// made by Healthy, as a duration such as "10s".
+	healthCheckTimeoutConfigKey = "healthCheckTimeout"
+
+	defaultHealthCheckTimeout = 5 * time.Second
+
+	// bucketConfigKey is the config key the bucket of the backup storage location is passed to the plugin in.
+	bucketConfigKey = "bucket"
+)
+
+// UnresponsiveError is returned by Healthy when the object store plugin doesn't answer the probe in time, e.g.
+// because its credentials expired and every call to the object store hangs.
+type UnresponsiveError struct {
+	// Plugin is the name of the object store plugin.
+	Plugin string
+	// Timeout is how long the probe waited for the plugin.
+	Timeout time.Duration
+	// Err is the error the probe ended with.
+	Err error
+}
+
+func (e *UnresponsiveError) Error() string {
+	return fmt.Sprintf("object store plugin %s did not respond within %v: %v", e.Plugin, e.Timeout, e.Err)
+}
+
+func (e *UnresponsiveError) Unwrap() error {
+	return e.Err
+}
+
+// Healthy probes the plugin by listing the top-level prefixes of the bucket it was initialized with, restarting its
+// process first if needed. It returns an UnresponsiveError if the plugin doesn't answer within healthCheckTimeout,
+// and the probe's error if it fails. Unless the probe is enabled with healthCheckConfigKey, Healthy reports the
+// plugin healthy without calling it.
+func (r *restartableObjectStore) Healthy(ctx context.Context) error {
+	config := r.currentConfig()
+	if config.raw == nil {
+		return errors.New("not initialized")
+	}
+	if !config.healthCheck {
+		return nil
+	}
+	bucket := config.raw[bucketConfigKey]
+	if bucket == "" {
+		return errors.Errorf("no %q in the object store config to probe", bucketConfigKey)
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, config.healthCheckTimeout)
+	defer cancel()
+	// the probe is abandoned once it times out even if the plugin ignores the context, as a wedged one may never
+	// return
+	_, err := callV1(probeCtx, func() (interface{}, error) {
+		delegate, err := r.getDelegateV2(probeCtx)
+		if err != nil {
+			return nil, err
+		}
+		return delegate.ListCommonPrefixesV2(probeCtx, bucket, "", "/")
+	}, nil)
+	if err == nil {
+		return nil
+	}
+	if probeCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return &UnresponsiveError{Plugin: r.key.name, Timeout: config.healthCheckTimeout, Err: err}
+	}
+	return errors.Wrapf(err, "object store plugin %s failed its health check", r.key.name)
+}
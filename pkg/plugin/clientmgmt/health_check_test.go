@@ -0,0 +1,107 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestRestartableObjectStoreHealthy(t *testing.T) {
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", test.NewFakeObjectStore("bucket"))
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	assert.EqualError(t, r.Healthy(context.Background()), "not initialized")
+
+	require.NoError(t, r.Init(map[string]string{bucketConfigKey: "bucket", healthCheckConfigKey: "true"}))
+	assert.NoError(t, r.Healthy(context.Background()))
+}
+
+func TestRestartableObjectStoreHealthyDisabled(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	// the plugin isn't probed unless the health check is enabled
+	objectStore.On("InitV2", mock.Anything, map[string]string{bucketConfigKey: "bucket"}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{bucketConfigKey: "bucket"}))
+	assert.NoError(t, r.Healthy(context.Background()))
+
+	assert.EqualError(t, r.UpdateConfig(context.Background(), map[string]string{bucketConfigKey: "bucket", healthCheckConfigKey: "maybe"}),
+		`UpdateConfig: invalid value for config key "healthCheck": strconv.ParseBool: parsing "maybe": invalid syntax`)
+}
+
+func TestRestartableObjectStoreHealthyUnresponsive(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	config := map[string]string{bucketConfigKey: "bucket"}
+	objectStore.On("InitV2", mock.Anything, config).Return(nil)
+	require.NoError(t, r.Init(map[string]string{bucketConfigKey: "bucket", healthCheckConfigKey: "true", healthCheckTimeoutConfigKey: "50ms"}))
+
+	// the plugin ignores the context, as a wedged one would
+	unblock := make(chan time.Time)
+	defer close(unblock)
+	objectStore.On("ListCommonPrefixesV2", mock.Anything, "bucket", "", "/").Return([]string{}, nil).WaitUntil(unblock).Once()
+
+	start := time.Now()
+	err := r.Healthy(context.Background())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	var unresponsive *UnresponsiveError
+	require.True(t, errors.As(err, &unresponsive))
+	assert.Equal(t, "fake", unresponsive.Plugin)
+	assert.Equal(t, 50*time.Millisecond, unresponsive.Timeout)
+	assert.EqualError(t, err, "object store plugin fake did not respond within 50ms: gave up waiting for object store plugin: context deadline exceeded")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	objectStore.On("ListCommonPrefixesV2", mock.Anything, "bucket", "", "/").Return(nil, errors.New("access denied")).Once()
+	assert.EqualError(t, r.Healthy(context.Background()), "object store plugin fake failed its health check: access denied")
+}
+
+func TestRestartableObjectStoreHealthyRestartsPlugin(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "fake"}
+	p.On("addReinitializer", key, mock.Anything)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	p.On("getByKindAndName", key).Return(test.NewFakeObjectStore("bucket"), nil)
+	require.NoError(t, r.Init(map[string]string{bucketConfigKey: "bucket", healthCheckConfigKey: "true"}))
+
+	// a dead plugin process is restarted before it's probed
+	p.On("resetIfNeeded", mock.Anything).Return(nil).Once()
+	assert.NoError(t, r.Healthy(context.Background()))
+
+	p.On("resetIfNeeded", mock.Anything).Return(errors.New("unable to restart plugin process")).Once()
+	assert.EqualError(t, r.Healthy(context.Background()), "object store plugin fake failed its health check: unable to restart plugin process")
+}
@@ -0,0 +1,112 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// hedgedResult is the outcome of a single attempt of a hedged call.
+type hedgedResult struct {
+	attempt int
+	value   interface{}
+	err     error
+}
+
+// hedge calls attempt and, if it has not returned within delay, starts a second concurrent attempt. The result of
+// whichever attempt returns first is used and the context of the other one is cancelled. At most one extra attempt
+// is ever in flight. If the losing attempt still returns a value, it is passed to release so that any resources it
+// holds can be freed. A delay of zero disables hedging.
+//
+// If acquireExtra is set, the extra attempt is only started if acquireExtra returns true, and the func it returns
+// is called once the extra attempt returns. This is used to make the extra attempt take a call slot of its own,
+// without waiting for one, as there's no point in hedging a call to a plugin which is busy already.
+//
+// Only idempotent operations may be hedged.
+func hedge(ctx context.Context, delay time.Duration, acquireExtra func() (func(), bool), attempt func(context.Context) (interface{}, error), release func(interface{})) (interface{}, error) {
+	if delay <= 0 {
+		return attempt(ctx)
+	}
+
+	results := make(chan hedgedResult, 2)
+	var cancels []context.CancelFunc
+	start := func(done func()) {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		n := len(cancels) - 1
+		go func() {
+			value, err := attempt(attemptCtx)
+			done()
+			results <- hedgedResult{attempt: n, value: value, err: err}
+		}()
+	}
+
+	start(func() {})
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var winner hedgedResult
+	select {
+	case winner = <-results:
+	case <-timer.C:
+		if acquireExtra == nil {
+			start(func() {})
+		} else if done, ok := acquireExtra(); ok {
+			start(done)
+		}
+		winner = <-results
+	}
+
+	if len(cancels) > 1 {
+		loser := 1 - winner.attempt
+		cancels[loser]()
+		go func() {
+			res := <-results
+			if res.err == nil && release != nil {
+				release(res.value)
+			}
+		}()
+	}
+
+	// The winner's context can't be cancelled yet if its value is a stream which may still be bound to it.
+	if rc, ok := winner.value.(io.ReadCloser); ok && rc != nil {
+		return &cancelOnCloseReadCloser{ReadCloser: rc, cancel: cancels[winner.attempt]}, winner.err
+	}
+	cancels[winner.attempt]()
+	return winner.value, winner.err
+}
+
+// cancelOnCloseReadCloser cancels the context of the call which returned the ReadCloser when it is closed.
+type cancelOnCloseReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnCloseReadCloser) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+// closeReadCloser is a release func for hedged calls returning an io.ReadCloser.
+func closeReadCloser(value interface{}) {
+	if rc, ok := value.(io.ReadCloser); ok && rc != nil {
+		rc.Close()
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type trackedReadCloser struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (t *trackedReadCloser) Close() error {
+	close(t.closed)
+	return nil
+}
+
+func TestHedge(t *testing.T) {
+	t.Run("hedging disabled makes a single attempt", func(t *testing.T) {
+		var attempts int32
+		value, err := hedge(context.Background(), 0, nil, func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&attempts, 1)
+			time.Sleep(10 * time.Millisecond)
+			return "value", nil
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("fast first attempt is not hedged", func(t *testing.T) {
+		var attempts int32
+		value, err := hedge(context.Background(), time.Second, nil, func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&attempts, 1)
+			return "value", nil
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&attempts))
+	})
+
+	t.Run("slow first attempt is hedged and cancelled", func(t *testing.T) {
+		var attempts int32
+		firstCancelled := make(chan struct{})
+		value, err := hedge(context.Background(), 10*time.Millisecond, nil, func(ctx context.Context) (interface{}, error) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				<-ctx.Done()
+				close(firstCancelled)
+				return nil, ctx.Err()
+			}
+			return "second", nil
+		}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "second", value)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&attempts))
+
+		select {
+		case <-firstCancelled:
+		case <-time.After(time.Second):
+			t.Fatal("losing attempt was not cancelled")
+		}
+	})
+
+	t.Run("losing stream is released and winning stream is readable", func(t *testing.T) {
+		var attempts int32
+		loser := &trackedReadCloser{Reader: strings.NewReader("loser"), closed: make(chan struct{})}
+		secondDone := make(chan struct{})
+		value, err := hedge(context.Background(), 10*time.Millisecond, nil, func(ctx context.Context) (interface{}, error) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				// the first attempt is slow but still succeeds after the second one has won
+				<-secondDone
+				return loser, nil
+			}
+			defer close(secondDone)
+			return ioutil.NopCloser(strings.NewReader("winner")), nil
+		}, closeReadCloser)
+		require.NoError(t, err)
+
+		rc := value.(io.ReadCloser)
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, "winner", string(data))
+		assert.NoError(t, rc.Close())
+
+		select {
+		case <-loser.closed:
+		case <-time.After(time.Second):
+			t.Fatal("losing stream was not closed")
+		}
+	})
+}
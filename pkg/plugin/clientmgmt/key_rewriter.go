@@ -0,0 +1,170 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// KeyRewriter maps the object keys used by Velero to the keys objects are stored under, e.g. to isolate tenants
+// under their own prefix. The mapping must be reversible, and must map a prefix of a key to a prefix of the
+// rewritten key, so that listings can be translated.
+type KeyRewriter interface {
+	// Rewrite returns the key key is stored under.
+	Rewrite(key string) string
+	// Restore returns the key that was rewritten to storedKey, and false if storedKey isn't the result of
+	// rewriting any key.
+	Restore(storedKey string) (string, bool)
+}
+
+// KeyRewriterFactory creates a KeyRewriter from the config of the object store it is used with.
+type KeyRewriterFactory func(config map[string]string) (KeyRewriter, error)
+
+const (
+	// keyRewriterConfigKey is the config key used to select a registered KeyRewriter by name.
+	keyRewriterConfigKey = "keyRewriter"
+	// keyPrefixConfigKey is the config key holding the prefix used by the "prefix" KeyRewriter.
+	keyPrefixConfigKey = "keyPrefix"
+	// encodeKeysConfigKey is the config key used to enable URL-encoding keys before any other rewriting, for
+	// object stores which mishandle keys containing spaces, non-ASCII or reserved characters.
+	encodeKeysConfigKey = "encodeKeys"
+)
+
+var (
+	keyRewriterFactoriesLock sync.RWMutex
+	keyRewriterFactories     = map[string]KeyRewriterFactory{
+		"prefix": newPrefixKeyRewriter,
+	}
+)
+
+// RegisterKeyRewriter makes a KeyRewriter available to object stores whose config sets keyRewriter to name.
+func RegisterKeyRewriter(name string, factory KeyRewriterFactory) {
+	keyRewriterFactoriesLock.Lock()
+	defer keyRewriterFactoriesLock.Unlock()
+
+	keyRewriterFactories[name] = factory
+}
+
+// newKeyRewriter returns the KeyRewriter selected by config, encoding keys first if config enables it, or nil if
+// keys aren't rewritten.
+func newKeyRewriter(config map[string]string) (KeyRewriter, error) {
+	var rewriters chainedKeyRewriter
+
+	if val, ok := config[encodeKeysConfigKey]; ok {
+		encodeKeys, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", encodeKeysConfigKey)
+		}
+		if encodeKeys {
+			rewriters = append(rewriters, encodingKeyRewriter{})
+		}
+	}
+
+	if name, ok := config[keyRewriterConfigKey]; ok {
+		keyRewriterFactoriesLock.RLock()
+		factory, ok := keyRewriterFactories[name]
+		keyRewriterFactoriesLock.RUnlock()
+		if !ok {
+			return nil, errors.Errorf("invalid value for config key %q: unknown key rewriter %q", keyRewriterConfigKey, name)
+		}
+
+		rewriter, err := factory(config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating key rewriter %q", name)
+		}
+		rewriters = append(rewriters, rewriter)
+	}
+
+	switch len(rewriters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return rewriters[0], nil
+	default:
+		return rewriters, nil
+	}
+}
+
+// chainedKeyRewriter rewrites keys with each of its KeyRewriters in turn.
+type chainedKeyRewriter []KeyRewriter
+
+func (c chainedKeyRewriter) Rewrite(key string) string {
+	for _, rewriter := range c {
+		key = rewriter.Rewrite(key)
+	}
+	return key
+}
+
+func (c chainedKeyRewriter) Restore(storedKey string) (string, bool) {
+	for i := len(c) - 1; i >= 0; i-- {
+		var ok bool
+		if storedKey, ok = c[i].Restore(storedKey); !ok {
+			return "", false
+		}
+	}
+	return storedKey, true
+}
+
+// encodingKeyRewriter URL-encodes each segment of keys, leaving the slashes between them as they are so that
+// listings by prefix and delimiter keep working.
+type encodingKeyRewriter struct{}
+
+func (encodingKeyRewriter) Rewrite(key string) string {
+	segments := strings.Split(key, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	return strings.Join(segments, "/")
+}
+
+func (e encodingKeyRewriter) Restore(storedKey string) (string, bool) {
+	key, err := url.PathUnescape(storedKey)
+	// keys which don't encode back to storedKey weren't written through the rewriter
+	if err != nil || e.Rewrite(key) != storedKey {
+		return "", false
+	}
+	return key, true
+}
+
+// prefixKeyRewriter stores all keys under a fixed prefix.
+type prefixKeyRewriter struct {
+	prefix string
+}
+
+func newPrefixKeyRewriter(config map[string]string) (KeyRewriter, error) {
+	prefix := config[keyPrefixConfigKey]
+	if prefix == "" {
+		return nil, errors.Errorf("config key %q must be set", keyPrefixConfigKey)
+	}
+	return &prefixKeyRewriter{prefix: prefix}, nil
+}
+
+func (p *prefixKeyRewriter) Rewrite(key string) string {
+	return p.prefix + key
+}
+
+func (p *prefixKeyRewriter) Restore(storedKey string) (string, bool) {
+	if !strings.HasPrefix(storedKey, p.prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(storedKey, p.prefix), true
+}
@@ -0,0 +1,216 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestNewKeyRewriter(t *testing.T) {
+	RegisterKeyRewriter("test.io/upper", func(config map[string]string) (KeyRewriter, error) {
+		return upperKeyRewriter{}, nil
+	})
+
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expected    KeyRewriter
+		expectedErr string
+	}{
+		{name: "no key rewriter", config: map[string]string{"bucket": "velero"}},
+		{name: "prefix", config: map[string]string{keyRewriterConfigKey: "prefix", keyPrefixConfigKey: "tenant-a/"}, expected: &prefixKeyRewriter{prefix: "tenant-a/"}},
+		{name: "prefix without keyPrefix", config: map[string]string{keyRewriterConfigKey: "prefix"}, expectedErr: `error creating key rewriter "prefix": config key "keyPrefix" must be set`},
+		{name: "registered", config: map[string]string{keyRewriterConfigKey: "test.io/upper"}, expected: upperKeyRewriter{}},
+		{name: "unknown", config: map[string]string{keyRewriterConfigKey: "unknown"}, expectedErr: `invalid value for config key "keyRewriter": unknown key rewriter "unknown"`},
+		{name: "encoded", config: map[string]string{encodeKeysConfigKey: "true"}, expected: encodingKeyRewriter{}},
+		{name: "not encoded", config: map[string]string{encodeKeysConfigKey: "false"}},
+		{name: "encoded and prefixed", config: map[string]string{encodeKeysConfigKey: "true", keyRewriterConfigKey: "prefix", keyPrefixConfigKey: "tenant a/"}, expected: chainedKeyRewriter{encodingKeyRewriter{}, &prefixKeyRewriter{prefix: "tenant a/"}}},
+		{name: "invalid encodeKeys", config: map[string]string{encodeKeysConfigKey: "sometimes"}, expectedErr: `invalid value for config key "encodeKeys": strconv.ParseBool: parsing "sometimes": invalid syntax`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rewriter, err := newKeyRewriter(tc.config)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, rewriter)
+		})
+	}
+}
+
+func TestPrefixKeyRewriter(t *testing.T) {
+	rewriter := &prefixKeyRewriter{prefix: "tenant-a/"}
+
+	assert.Equal(t, "tenant-a/backups/b1/velero-backup.json", rewriter.Rewrite("backups/b1/velero-backup.json"))
+
+	key, ok := rewriter.Restore("tenant-a/backups/b1/velero-backup.json")
+	assert.True(t, ok)
+	assert.Equal(t, "backups/b1/velero-backup.json", key)
+
+	_, ok = rewriter.Restore("tenant-b/backups/b1/velero-backup.json")
+	assert.False(t, ok)
+}
+
+func TestEncodingKeyRewriter(t *testing.T) {
+	rewriter := encodingKeyRewriter{}
+
+	storedKey := rewriter.Rewrite("backups/my backup/ünïcode+#?.tar.gz")
+	assert.Equal(t, "backups/my%20backup/%C3%BCn%C3%AFcode+%23%3F.tar.gz", storedKey)
+
+	key, ok := rewriter.Restore(storedKey)
+	assert.True(t, ok)
+	assert.Equal(t, "backups/my backup/ünïcode+#?.tar.gz", key)
+
+	// keys which weren't encoded aren't restored
+	_, ok = rewriter.Restore("backups/my backup/velero-backup.json")
+	assert.False(t, ok)
+	_, ok = rewriter.Restore("backups/%zz")
+	assert.False(t, ok)
+
+	// keys are encoded before the prefix is added
+	chained := chainedKeyRewriter{rewriter, &prefixKeyRewriter{prefix: "tenant a/"}}
+	assert.Equal(t, "tenant a/backups/my%20backup", chained.Rewrite("backups/my backup"))
+	key, ok = chained.Restore("tenant a/backups/my%20backup")
+	assert.True(t, ok)
+	assert.Equal(t, "backups/my backup", key)
+	_, ok = chained.Restore("tenant b/backups/my%20backup")
+	assert.False(t, ok)
+}
+
+func TestRestartableObjectStoreEncodeKeys(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{encodeKeysConfigKey: "true"}))
+	assert.Equal(t, map[string]string{}, objectStore.Config)
+
+	key := "backups/nightly backup/nightly backup.tar.gz"
+	require.NoError(t, r.PutObject("bucket", key, strings.NewReader("contents")))
+
+	storedKeys, err := objectStore.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/nightly%20backup/nightly%20backup.tar.gz"}, storedKeys)
+
+	keys, err := r.ListObjects("bucket", "backups/nightly backup/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{key}, keys)
+
+	prefixes, err := r.ListCommonPrefixes("bucket", "backups/", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/nightly backup/"}, prefixes)
+
+	exists, err := r.ObjectExists("bucket", key)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	rc, err := r.GetObject("bucket", key)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "contents", string(data))
+	require.NoError(t, rc.Close())
+
+	require.NoError(t, r.DeleteObject("bucket", key))
+	exists, err = r.ObjectExists("bucket", key)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// upperKeyRewriter stores keys in upper case, and restores keys which are all upper case.
+type upperKeyRewriter struct{}
+
+func (upperKeyRewriter) Rewrite(key string) string { return strings.ToUpper(key) }
+
+func (upperKeyRewriter) Restore(storedKey string) (string, bool) {
+	if storedKey != strings.ToUpper(storedKey) {
+		return "", false
+	}
+	return strings.ToLower(storedKey), true
+}
+
+func TestRestartableObjectStoreKeyRewriter(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.On("getByKindAndName", key).Return(objectStore, nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{"bucket": "velero"}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{"bucket": "velero", keyRewriterConfigKey: "prefix", keyPrefixConfigKey: "tenant-a/"}))
+
+	body := strings.NewReader("body")
+	objectStore.On("PutObjectV2", mock.Anything, "bucket", "tenant-a/backups/b1/velero-backup.json", body).Return(nil)
+	assert.NoError(t, r.PutObject("bucket", "backups/b1/velero-backup.json", body))
+
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "tenant-a/backups/b1/velero-backup.json").Return(osv2.ObjectInfo{Size: 4}, nil)
+	exists, err := r.ObjectExists("bucket", "backups/b1/velero-backup.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "tenant-a/backups/b1/velero-backup.json").Return(nil)
+	assert.NoError(t, r.DeleteObject("bucket", "backups/b1/velero-backup.json"))
+
+	// listings are translated back, dropping keys that weren't written through the rewriter
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "tenant-a/backups/").
+		Return([]string{"tenant-a/backups/b1/velero-backup.json", "tenant-b/backups/b2/velero-backup.json"}, nil)
+	keys, err := r.ListObjects("bucket", "backups/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b1/velero-backup.json"}, keys)
+
+	objectStore.On("ListCommonPrefixesV2", mock.Anything, "bucket", "tenant-a/backups/", "/").
+		Return([]string{"tenant-a/backups/b1/"}, nil)
+	prefixes, err := r.ListCommonPrefixes("bucket", "backups/", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b1/"}, prefixes)
+
+	objectStore.On("ObjectsExist", mock.Anything, "bucket", []string{"tenant-a/a", "tenant-a/b"}).
+		Return(map[string]bool{"tenant-a/a": true, "tenant-a/b": false}, nil)
+	found, err := r.ObjectsExist(context.Background(), "bucket", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"a": true, "b": false}, found)
+
+	objectStore.On("ListObjectVersions", mock.Anything, "bucket", "tenant-a/a").
+		Return([]osv2.ObjectVersion{{Key: "tenant-a/a", VersionID: "1"}}, nil)
+	versions, err := r.ListObjectVersions(context.Background(), "bucket", "a")
+	require.NoError(t, err)
+	assert.Equal(t, []osv2.ObjectVersion{{Key: "a", VersionID: "1"}}, versions)
+}
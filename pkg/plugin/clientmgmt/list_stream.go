@@ -0,0 +1,104 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+// listStreamPageSize is the number of keys ListObjectsStream asks the plugin for at a time.
+const listStreamPageSize = 1000
+
+// ListObjectsStream lists the keys in bucket with the given prefix like ListObjectsV2, but sends them on the
+// returned channel as the caller receives them instead of returning a slice, so that callers can process large
+// listings incrementally. The keys are fetched from the plugin a page at a time with ListObjectsPaged, so the
+// next page is only requested once the caller has received the keys of the previous one. The keys channel is
+// closed when the listing is complete or ctx is done; the error, if any, is then sent on the error channel, which
+// is closed after it.
+func (r *restartableObjectStore) ListObjectsStream(ctx context.Context, bucket, prefix string) (<-chan string, <-chan error) {
+	keys := make(chan string)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(keys)
+
+		it, err := r.ListObjectsPaged(ctx, bucket, prefix, listStreamPageSize)
+		if err != nil {
+			errs <- err
+			return
+		}
+		for {
+			page, err := it.Next(ctx)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, key := range page {
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+		}
+	}()
+
+	return keys, errs
+}
+
+// restartableObjectIterator returns the pages of a plugin's ObjectIterator with their keys mapped back to the keys
+// callers use. A plugin's iterators don't survive the restart of its process, so once the plugin has been restarted
+// Next returns an osv2.RestartListingError, and the caller has to start the listing over.
+type restartableObjectIterator struct {
+	r        *restartableObjectStore
+	delegate osv2.ObjectIterator
+	prefix   string
+	// restarts is the restart count of the plugin when the listing was started.
+	restarts uint32
+}
+
+func (it *restartableObjectIterator) Next(ctx context.Context) ([]string, error) {
+	if it.r.restartCount() != it.restarts {
+		return nil, &osv2.RestartListingError{Err: errors.New("object store plugin was restarted during the listing")}
+	}
+
+	keys, err := it.delegate.Next(ctx)
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		// the plugin's process may have exited mid-listing, in which case it's restarted here so that the caller
+		// can start the listing over right away
+		if _, resetErr := it.r.getDelegate(ctx); resetErr == nil && it.r.restartCount() != it.restarts {
+			return nil, &osv2.RestartListingError{Err: err}
+		}
+		return nil, err
+	}
+
+	keys, err = it.r.restoreKeys(keys, nil)
+	return withoutTrash(it.r.currentConfig().softDelete, it.prefix, keys, err)
+}
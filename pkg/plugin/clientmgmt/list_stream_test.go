@@ -0,0 +1,170 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestRestartableObjectStoreListObjectsStream(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	for _, key := range []string{"backups/b1/b1.tar.gz", "backups/b2/b2.tar.gz", "restores/r1/r1-logs.gz"} {
+		require.NoError(t, r.PutObject("bucket", key, strings.NewReader("data")))
+	}
+
+	keys, errs := r.ListObjectsStream(context.Background(), "bucket", "backups/")
+	var streamed []string
+	for key := range keys {
+		streamed = append(streamed, key)
+	}
+	assert.NoError(t, <-errs)
+	assert.Equal(t, []string{"backups/b1/b1.tar.gz", "backups/b2/b2.tar.gz"}, streamed)
+
+	// the listing stops when the context is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	keys, errs = r.ListObjectsStream(ctx, "bucket", "")
+	assert.Equal(t, "backups/b1/b1.tar.gz", <-keys)
+	cancel()
+	for range keys {
+	}
+	assert.Equal(t, context.Canceled, <-errs)
+}
+
+func TestRestartableObjectStoreListObjectsStreamError(t *testing.T) {
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	objectStore.On("ListObjectsPaged", mock.Anything, "bucket", "backups/", listStreamPageSize).
+		Return(func(context.Context, string, string, int) osv2.ObjectIterator { return nil }, errors.New("listing failed"))
+	keys, errs := r.ListObjectsStream(context.Background(), "bucket", "backups/")
+	_, ok := <-keys
+	assert.False(t, ok)
+	assert.EqualError(t, <-errs, "ListObjectsPaged bucket: listing failed")
+
+	// an error listing a page is reported after the keys of the pages before it
+	it := &failingObjectIterator{pages: [][]string{{"restores/r1/r1-logs.gz"}}, err: errors.New("listing failed")}
+	objectStore.On("ListObjectsPaged", mock.Anything, "bucket", "restores/", listStreamPageSize).Return(it, nil)
+	keys, errs = r.ListObjectsStream(context.Background(), "bucket", "restores/")
+	assert.Equal(t, "restores/r1/r1-logs.gz", <-keys)
+	_, ok = <-keys
+	assert.False(t, ok)
+	assert.EqualError(t, <-errs, "listing failed")
+}
+
+// failingObjectIterator returns its pages, then fails with err.
+type failingObjectIterator struct {
+	pages [][]string
+	err   error
+}
+
+func (it *failingObjectIterator) Next(ctx context.Context) ([]string, error) {
+	if len(it.pages) == 0 {
+		return nil, it.err
+	}
+	page := it.pages[0]
+	it.pages = it.pages[1:]
+	return page, nil
+}
+
+// listPages returns the pages it returns until it returns an error, and the error unless it's io.EOF.
+func listPages(it osv2.ObjectIterator) ([][]string, error) {
+	var pages [][]string
+	for {
+		page, err := it.Next(context.Background())
+		if err == io.EOF {
+			return pages, nil
+		}
+		if err != nil {
+			return pages, err
+		}
+		pages = append(pages, page)
+	}
+}
+
+func TestRestartableObjectStoreListObjectsPaged(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	for _, key := range []string{"backups/b1", "backups/b2", "backups/b3", "backups/b4", "backups/b5", "restores/r1"} {
+		require.NoError(t, r.PutObject("bucket", key, strings.NewReader("data")))
+	}
+
+	it, err := r.ListObjectsPaged(context.Background(), "bucket", "backups/", 2)
+	require.NoError(t, err)
+	pages, err := listPages(it)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"backups/b1", "backups/b2"}, {"backups/b3", "backups/b4"}, {"backups/b5"}}, pages)
+
+	_, err = r.ListObjectsPaged(context.Background(), "bucket", "backups/", 0)
+	assert.EqualError(t, err, "ListObjectsPaged bucket: invalid page size 0")
+}
+
+func TestRestartableObjectStoreListObjectsPagedRestart(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	for _, key := range []string{"backups/b1", "backups/b2", "backups/b3"} {
+		require.NoError(t, r.PutObject("bucket", key, strings.NewReader("data")))
+	}
+
+	it, err := r.ListObjectsPaged(context.Background(), "bucket", "backups/", 2)
+	require.NoError(t, err)
+	page, err := it.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b1", "backups/b2"}, page)
+
+	// the plugin's iterators don't survive its restart, so the caller is told to start the listing over
+	require.NoError(t, p.reset(context.Background()))
+	_, err = it.Next(context.Background())
+	var restartErr *osv2.RestartListingError
+	require.True(t, errors.As(err, &restartErr), "unexpected error %v", err)
+	_, err = it.Next(context.Background())
+	assert.True(t, errors.As(err, &restartErr), "unexpected error %v", err)
+
+	it, err = r.ListObjectsPaged(context.Background(), "bucket", "backups/", 2)
+	require.NoError(t, err)
+	pages, err := listPages(it)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"backups/b1", "backups/b2"}, {"backups/b3"}}, pages)
+}
@@ -72,9 +72,10 @@ type manager struct {
 
 	restartableProcessFactory RestartableProcessFactory
 
-	// lock guards restartableProcesses
+	// lock guards restartableProcesses and objectStores
 	lock                 sync.Mutex
 	restartableProcesses map[string]RestartableProcess
+	objectStores         []*restartableObjectStore
 }
 
 // NewManager constructs a manager for getting plugins.
@@ -96,8 +97,14 @@ func (m *manager) CleanupClients() {
 	for _, restartableProcess := range m.restartableProcesses {
 		restartableProcess.stop()
 	}
+	objectStores := m.objectStores
+	m.objectStores = nil
 
 	m.lock.Unlock()
+
+	for _, objectStore := range objectStores {
+		objectStore.close()
+	}
 }
 
 // getRestartableProcess returns a restartableProcess for a plugin identified by kind and name, creating a
@@ -146,7 +153,11 @@ func (m *manager) GetObjectStore(name string) (velero.ObjectStore, error) {
 		return nil, err
 	}
 
-	r := newRestartableObjectStore(name, restartableProcess)
+	r := newRestartableObjectStore(name, restartableProcess, m.logger)
+
+	m.lock.Lock()
+	m.objectStores = append(m.objectStores, r)
+	m.lock.Unlock()
 
 	return r, nil
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -89,13 +90,13 @@ func (rp *mockRestartableProcess) addReinitializer(key kindAndName, r reinitiali
 	rp.Called(key, r)
 }
 
-func (rp *mockRestartableProcess) reset() error {
-	args := rp.Called()
+func (rp *mockRestartableProcess) reset(ctx context.Context) error {
+	args := rp.Called(ctx)
 	return args.Error(0)
 }
 
-func (rp *mockRestartableProcess) resetIfNeeded() error {
-	args := rp.Called()
+func (rp *mockRestartableProcess) resetIfNeeded(ctx context.Context) error {
+	args := rp.Called(ctx)
 	return args.Error(0)
 }
 
@@ -108,6 +109,54 @@ func (rp *mockRestartableProcess) stop() {
 	rp.Called()
 }
 
+// fakeRestartableProcess is a RestartableProcess which dispenses the plugins it is configured with, so that tests
+// can exercise restartable plugins against real implementations, e.g. ones supporting only a particular API version.
+type fakeRestartableProcess struct {
+	plugins        map[kindAndName]interface{}
+	reinitializers map[kindAndName]reinitializer
+}
+
+func newFakeRestartableProcess() *fakeRestartableProcess {
+	return &fakeRestartableProcess{
+		plugins:        make(map[kindAndName]interface{}),
+		reinitializers: make(map[kindAndName]reinitializer),
+	}
+}
+
+// dispense configures rp to dispense plugin for the given kind and name.
+func (rp *fakeRestartableProcess) dispense(kind framework.PluginKind, name string, plugin interface{}) *fakeRestartableProcess {
+	rp.plugins[kindAndName{kind: kind, name: name}] = plugin
+	return rp
+}
+
+func (rp *fakeRestartableProcess) addReinitializer(key kindAndName, r reinitializer) {
+	rp.reinitializers[key] = r
+}
+
+// reset reinitializes every plugin with a reinitializer, as a restart of the plugin process would.
+func (rp *fakeRestartableProcess) reset(ctx context.Context) error {
+	for key, r := range rp.reinitializers {
+		if err := r.reinitialize(ctx, rp.plugins[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rp *fakeRestartableProcess) resetIfNeeded(ctx context.Context) error {
+	return nil
+}
+
+func (rp *fakeRestartableProcess) getByKindAndName(key kindAndName) (interface{}, error) {
+	plugin, ok := rp.plugins[key]
+	if !ok {
+		return nil, errors.Errorf("%s plugin %s is not dispensed", key.kind.String(), key.name)
+	}
+	return plugin, nil
+}
+
+func (rp *fakeRestartableProcess) stop() {}
+
 func TestGetRestartableProcess(t *testing.T) {
 	logger := test.NewLogger()
 	logLevel := logrus.InfoLevel
@@ -170,7 +219,20 @@ func TestCleanupClients(t *testing.T) {
 		m.restartableProcesses[fmt.Sprintf("rp%d", i)] = rp
 	}
 
+	closed := 0
+	for i := 0; i < 3; i++ {
+		r := &restartableObjectStore{}
+		r.OnClose(func() { closed++ })
+		m.objectStores = append(m.objectStores, r)
+	}
+
+	m.CleanupClients()
+	assert.Equal(t, 3, closed)
+	assert.Empty(t, m.objectStores)
+
+	// the close funcs only run once
 	m.CleanupClients()
+	assert.Equal(t, 3, closed)
 }
 
 func TestGetObjectStore(t *testing.T) {
@@ -180,10 +242,15 @@ func TestGetObjectStore(t *testing.T) {
 		func(m Manager, name string) (interface{}, error) {
 			return m.GetObjectStore(name)
 		},
-		func(name string, sharedPluginProcess RestartableProcess) interface{} {
+		func(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) interface{} {
 			return &restartableObjectStore{
 				key:                 kindAndName{kind: framework.PluginKindObjectStore, name: name},
 				sharedPluginProcess: sharedPluginProcess,
+				config:              newObjectStoreConfig(),
+				restartRetries:      defaultRestartRetries,
+				restartRetryBackoff: defaultRestartRetryBackoff,
+				operationMetrics:    defaultObjectStoreMetrics,
+				logger:              logger,
 			}
 		},
 		true,
@@ -197,7 +264,7 @@ func TestGetVolumeSnapshotter(t *testing.T) {
 		func(m Manager, name string) (interface{}, error) {
 			return m.GetVolumeSnapshotter(name)
 		},
-		func(name string, sharedPluginProcess RestartableProcess) interface{} {
+		func(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) interface{} {
 			return &restartableVolumeSnapshotter{
 				key:                 kindAndName{kind: framework.PluginKindVolumeSnapshotter, name: name},
 				sharedPluginProcess: sharedPluginProcess,
@@ -214,7 +281,7 @@ func TestGetBackupItemAction(t *testing.T) {
 		func(m Manager, name string) (interface{}, error) {
 			return m.GetBackupItemAction(name)
 		},
-		func(name string, sharedPluginProcess RestartableProcess) interface{} {
+		func(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) interface{} {
 			return &restartableBackupItemAction{
 				key:                 kindAndName{kind: framework.PluginKindBackupItemAction, name: name},
 				sharedPluginProcess: sharedPluginProcess,
@@ -231,7 +298,7 @@ func TestGetRestoreItemAction(t *testing.T) {
 		func(m Manager, name string) (interface{}, error) {
 			return m.GetRestoreItemAction(name)
 		},
-		func(name string, sharedPluginProcess RestartableProcess) interface{} {
+		func(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) interface{} {
 			return &restartableRestoreItemAction{
 				key:                 kindAndName{kind: framework.PluginKindRestoreItemAction, name: name},
 				sharedPluginProcess: sharedPluginProcess,
@@ -246,7 +313,7 @@ func getPluginTest(
 	kind framework.PluginKind,
 	name string,
 	getPluginFunc func(m Manager, name string) (interface{}, error),
-	expectedResultFunc func(name string, sharedPluginProcess RestartableProcess) interface{},
+	expectedResultFunc func(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) interface{},
 	reinitializable bool,
 ) {
 	logger := test.NewLogger()
@@ -281,7 +348,7 @@ func getPluginTest(
 	// Test 2: happy path
 	factory.On("newRestartableProcess", pluginID.Command, logger, logLevel).Return(restartableProcess, nil).Once()
 
-	expected := expectedResultFunc(name, restartableProcess)
+	expected := expectedResultFunc(name, restartableProcess, logger)
 	if reinitializable {
 		key := kindAndName{kind: pluginID.Kind, name: pluginID.Name}
 		restartableProcess.On("addReinitializer", key, expected)
@@ -483,7 +550,7 @@ func TestGetDeleteItemAction(t *testing.T) {
 		func(m Manager, name string) (interface{}, error) {
 			return m.GetDeleteItemAction(name)
 		},
-		func(name string, sharedPluginProcess RestartableProcess) interface{} {
+		func(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) interface{} {
 			return &restartableDeleteItemAction{
 				key:                 kindAndName{kind: framework.PluginKindDeleteItemAction, name: name},
 				sharedPluginProcess: sharedPluginProcess,
@@ -0,0 +1,166 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+const (
+	// multipartPartSizeConfigKey is the config key used to set the size of the parts PutObjectMultipart splits
+	// objects into, as a quantity such as "64Mi".
+	multipartPartSizeConfigKey = "multipartPartSize"
+	// multipartParallelismConfigKey is the config key used to set how many parts PutObjectMultipart uploads
+	// concurrently.
+	multipartParallelismConfigKey = "multipartParallelism"
+
+	defaultMultipartPartSize    = 64 * 1024 * 1024
+	defaultMultipartParallelism = 4
+)
+
+// PutObjectMultipart creates a new object like PutObjectV2, but splits body into parts of multipartPartSize bytes
+// and uploads up to multipartParallelism of them concurrently. Each part in flight holds its own buffer, so no
+// more than multipartParallelism × multipartPartSize bytes of body are held in memory at once: body isn't read
+// any further until an upload finishes and frees a buffer. Each part is uploaded as a separate operation, which
+// takes its own call slot and is subject to operationTimeout. If any part fails, or ctx is done, the parts still
+// in flight are cancelled, the upload is aborted and no object is created. Plugins without multipart uploads are
+// given the whole body with PutObjectV2.
+func (r *restartableObjectStore) PutObjectMultipart(ctx context.Context, bucket, key string, body io.Reader) (err error) {
+	uploadID, err := r.CreateMultipartUpload(ctx, bucket, key)
+	if errors.Is(err, osv2.ErrUnsupported) {
+		return r.PutObjectV2(ctx, bucket, key, body)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "error starting multipart upload of %s", key)
+	}
+	completed := false
+	defer func() {
+		if err == nil || completed {
+			return
+		}
+		// ctx may be done already, which mustn't stop the upload's parts from being discarded
+		if abortErr := r.AbortMultipartUpload(context.Background(), bucket, key, uploadID); abortErr != nil {
+			r.logger.WithError(abortErr).WithField("key", key).WithField("uploadID", uploadID).Error("Error aborting multipart upload")
+		}
+	}()
+
+	parts, err := r.uploadParts(ctx, bucket, key, uploadID, emptyBodyIfNil(body))
+	if err != nil {
+		return err
+	}
+	if err := r.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts); err != nil {
+		return errors.Wrapf(err, "error completing multipart upload of %s", key)
+	}
+	completed = true
+
+	if !r.currentConfig().waitForConsistency {
+		return nil
+	}
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	return r.waitForObject(ctx, delegate, bucket, key)
+}
+
+// uploadParts reads body into parts and uploads them concurrently as parts of the multipart upload uploadID,
+// returning the uploaded parts sorted by part number. An empty body is uploaded as a single empty part.
+func (r *restartableObjectStore) uploadParts(ctx context.Context, bucket, key, uploadID string, body io.Reader) ([]osv2.CompletedPart, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffers holds the part buffers not in use by an upload, which are allocated the first time they're taken
+	config := r.currentConfig()
+	buffers := make(chan []byte, config.multipartParallelism)
+	for i := 0; i < config.multipartParallelism; i++ {
+		buffers <- nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		parts    []osv2.CompletedPart
+		firstErr error
+	)
+	fail := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for partNumber := 1; ; partNumber++ {
+		var buf []byte
+		select {
+		case buf = <-buffers:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			fail(ctx.Err())
+			break
+		}
+		if buf == nil {
+			buf = make([]byte, config.multipartPartSize)
+		}
+
+		n, err := io.ReadFull(body, buf)
+		if err == io.EOF && partNumber > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			fail(errors.Wrapf(err, "error reading part %d of %s", partNumber, key))
+			break
+		}
+		last := err != nil
+
+		wg.Add(1)
+		go func(partNumber int, buf []byte) {
+			defer wg.Done()
+			defer func() { buffers <- buf[:cap(buf)] }()
+
+			etag, err := r.UploadPart(ctx, bucket, key, uploadID, partNumber, bytes.NewReader(buf))
+			if err != nil {
+				fail(errors.Wrapf(err, "error uploading part %d of %s", partNumber, key))
+				return
+			}
+			lock.Lock()
+			parts = append(parts, osv2.CompletedPart{PartNumber: partNumber, ETag: etag})
+			lock.Unlock()
+		}(partNumber, buf[:n])
+
+		if last {
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+	return parts, nil
+}
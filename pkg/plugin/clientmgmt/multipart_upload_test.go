@@ -0,0 +1,211 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// fakeMultipartObjectStore records the parts uploaded to it, failing the upload of failPart if it's set. If
+// blockParts is set, the uploads of the other parts don't finish until they're cancelled.
+type fakeMultipartObjectStore struct {
+	*osv2mocks.ObjectStore
+
+	failPart   int
+	blockParts bool
+
+	lock        sync.Mutex
+	parts       map[int]string
+	inFlight    int
+	maxInFlight int
+	cancelled   int
+	completed   []osv2.CompletedPart
+	aborted     bool
+}
+
+func (f *fakeMultipartObjectStore) CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error) {
+	return "upload-1", nil
+}
+
+func (f *fakeMultipartObjectStore) UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, body io.Reader) (string, error) {
+	f.lock.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.lock.Unlock()
+	defer func() {
+		f.lock.Lock()
+		f.inFlight--
+		f.lock.Unlock()
+	}()
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	// give the other parts a chance to be uploaded concurrently
+	time.Sleep(10 * time.Millisecond)
+	if partNumber == f.failPart {
+		return "", errors.New("connection reset")
+	}
+	if f.blockParts {
+		<-ctx.Done()
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		f.cancelled++
+		return "", ctx.Err()
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.parts[partNumber] = string(data)
+	return fmt.Sprintf("etag-%d", partNumber), nil
+}
+
+func (f *fakeMultipartObjectStore) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []osv2.CompletedPart) error {
+	f.completed = parts
+	return nil
+}
+
+func (f *fakeMultipartObjectStore) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	f.aborted = true
+	return nil
+}
+
+func newMultipartTestObjectStore(t *testing.T, objectStore *fakeMultipartObjectStore, config map[string]string) *restartableObjectStore {
+	objectStore.ObjectStore = new(osv2mocks.ObjectStore)
+	objectStore.parts = map[int]string{}
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	initConfig := map[string]string{multipartPartSizeConfigKey: "3", multipartParallelismConfigKey: "2"}
+	for k, v := range config {
+		initConfig[k] = v
+	}
+	require.NoError(t, r.Init(initConfig))
+	return r
+}
+
+func TestRestartableObjectStorePutObjectMultipart(t *testing.T) {
+	objectStore := &fakeMultipartObjectStore{}
+	r := newMultipartTestObjectStore(t, objectStore, nil)
+
+	require.NoError(t, r.PutObjectMultipart(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("abcdefghij")))
+
+	assert.Equal(t, map[int]string{1: "abc", 2: "def", 3: "ghi", 4: "j"}, objectStore.parts)
+	assert.Equal(t, []osv2.CompletedPart{
+		{PartNumber: 1, ETag: "etag-1"},
+		{PartNumber: 2, ETag: "etag-2"},
+		{PartNumber: 3, ETag: "etag-3"},
+		{PartNumber: 4, ETag: "etag-4"},
+	}, objectStore.completed)
+	assert.Equal(t, 2, objectStore.maxInFlight)
+	assert.False(t, objectStore.aborted)
+}
+
+func TestRestartableObjectStorePutObjectMultipartEmptyBody(t *testing.T) {
+	objectStore := &fakeMultipartObjectStore{}
+	r := newMultipartTestObjectStore(t, objectStore, nil)
+
+	require.NoError(t, r.PutObjectMultipart(context.Background(), "bucket", "backups/b1/b1.tar.gz", nil))
+
+	assert.Equal(t, map[int]string{1: ""}, objectStore.parts)
+	assert.Equal(t, []osv2.CompletedPart{{PartNumber: 1, ETag: "etag-1"}}, objectStore.completed)
+}
+
+func TestRestartableObjectStorePutObjectMultipartAbortsOnFailure(t *testing.T) {
+	objectStore := &fakeMultipartObjectStore{failPart: 2}
+	r := newMultipartTestObjectStore(t, objectStore, nil)
+
+	err := r.PutObjectMultipart(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("abcdefghijklmnopqrstuvwxyz"))
+	assert.EqualError(t, err, "error uploading part 2 of backups/b1/b1.tar.gz: UploadPart bucket/backups/b1/b1.tar.gz: connection reset")
+	assert.True(t, objectStore.aborted)
+	assert.Nil(t, objectStore.completed)
+	// the remaining parts aren't read once a part has failed
+	assert.Less(t, len(objectStore.parts), 8)
+}
+
+func TestRestartableObjectStorePutObjectMultipartCancelsPartsInFlight(t *testing.T) {
+	objectStore := &fakeMultipartObjectStore{failPart: 2, blockParts: true}
+	r := newMultipartTestObjectStore(t, objectStore, nil)
+
+	err := r.PutObjectMultipart(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("abcdefghijklmnopqrstuvwxyz"))
+	assert.EqualError(t, err, "error uploading part 2 of backups/b1/b1.tar.gz: UploadPart bucket/backups/b1/b1.tar.gz: connection reset")
+	assert.Equal(t, 1, objectStore.cancelled)
+	assert.True(t, objectStore.aborted)
+}
+
+func TestRestartableObjectStorePutObjectMultipartCallSlots(t *testing.T) {
+	objectStore := &fakeMultipartObjectStore{}
+	r := newMultipartTestObjectStore(t, objectStore, map[string]string{maxConcurrentCallsConfigKey: "1"})
+
+	require.NoError(t, r.PutObjectMultipart(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("abcdefghij")))
+	assert.Equal(t, map[int]string{1: "abc", 2: "def", 3: "ghi", 4: "j"}, objectStore.parts)
+	// each part takes a call slot, so only one is uploaded at a time despite the parallelism
+	assert.Equal(t, 1, objectStore.maxInFlight)
+}
+
+func TestRestartableObjectStorePutObjectMultipartV1Plugin(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	require.NoError(t, r.PutObjectMultipart(context.Background(), "bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup")))
+
+	rc, err := r.GetObject("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	defer rc.Close()
+	contents, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(contents))
+}
+
+func TestRestartableObjectStoreMultipartConfig(t *testing.T) {
+	r := newRestartableObjectStore("fake", newFakeRestartableProcess(), test.NewLogger())
+	assert.Equal(t, defaultMultipartPartSize, r.currentConfig().multipartPartSize)
+	assert.Equal(t, defaultMultipartParallelism, r.currentConfig().multipartParallelism)
+
+	config, err := parseConfig(map[string]string{multipartPartSizeConfigKey: "8Mi", multipartParallelismConfigKey: "16"})
+	require.NoError(t, err)
+	assert.Equal(t, 8*1024*1024, config.multipartPartSize)
+	assert.Equal(t, 16, config.multipartParallelism)
+
+	_, err = parseConfig(map[string]string{multipartPartSizeConfigKey: "0"})
+	assert.EqualError(t, err, `invalid value for config key "multipartPartSize": "0"`)
+	_, err = parseConfig(map[string]string{multipartParallelismConfigKey: "0"})
+	assert.EqualError(t, err, `invalid value for config key "multipartParallelism": "0"`)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+// errorKinds are the errors an object store error may be classified as.
+var errorKinds = []error{osv2.ErrObjectNotFound, osv2.ErrBucketNotFound, osv2.ErrTransient, osv2.ErrAccessDenied}
+
+// wrapError wraps err, returned by the method of r's plugin called on the object with the given key in bucket, in an
+// *osv2.OperationError, so that callers can tell what kind of error it is with errors.Is even if the plugin returned
+// an opaque error. Errors which are already wrapped are returned as they are.
+func (r *restartableObjectStore) wrapError(method, bucket, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var opErr *osv2.OperationError
+	if errors.As(err, &opErr) {
+		return err
+	}
+	return &osv2.OperationError{Op: method, Bucket: bucket, Key: key, Kind: r.errorKind(err), Err: err}
+}
+
+// errorKind returns the kind of error err is for errors which aren't one of the errorKinds already: restarts of the
+// plugin's process, timeouts, throttling and the errors which reads are retried for are transient, and errors
+// reporting a 401 or 403 status code mean that access was denied. nil is returned if err can't be classified.
+func (r *restartableObjectStore) errorKind(err error) error {
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return nil
+		}
+	}
+
+	if isRestartInProgress(err) || isRetryableReadError(r.key.name, err) {
+		return osv2.ErrTransient
+	}
+
+	switch code, _, _ := osv2.ProviderErrorDetails(err); code {
+	case http.StatusTooManyRequests:
+		return osv2.ErrTransient
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return osv2.ErrAccessDenied
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+)
+
+func TestRestartableObjectStoreObjectNotFound(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
+	p.On("getByKindAndName", key).Return(objectStore, nil)
+
+	ctx := context.Background()
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz").Return(osv2.ObjectInfo{}, osv2.ErrObjectNotFound)
+	_, err := r.GetObjectInfoV2(ctx, "bucket", "backups/b1/b1.tar.gz")
+	assert.True(t, osv2.IsObjectNotFound(err))
+	assert.EqualError(t, err, "GetObjectInfo bucket/backups/b1/b1.tar.gz: object not found")
+
+	var opErr *osv2.OperationError
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "GetObjectInfo", opErr.Op)
+	assert.Equal(t, "bucket", opErr.Bucket)
+	assert.Equal(t, "backups/b1/b1.tar.gz", opErr.Key)
+
+	// methods which aren't traced as operations wrap the errors too
+	objectStore.On("GetObjectChecksum", mock.Anything, "bucket", "backups/b2/b2.tar.gz").Return("", errors.Wrap(osv2.ErrObjectNotFound, "HEAD failed"))
+	_, err = r.GetObjectChecksum(ctx, "bucket", "backups/b2/b2.tar.gz")
+	assert.True(t, osv2.IsObjectNotFound(err))
+	require.True(t, errors.As(err, &opErr))
+	assert.Equal(t, "GetObjectChecksum", opErr.Op)
+	assert.Equal(t, "backups/b2/b2.tar.gz", opErr.Key)
+
+	// and so does the v1 API
+	objectStore.On("GetObjectV2", mock.Anything, "bucket", "backups/b3/b3.tar.gz").Return(nil, osv2.ErrObjectNotFound)
+	_, err = r.GetObject("bucket", "backups/b3/b3.tar.gz")
+	assert.True(t, osv2.IsObjectNotFound(err))
+}
+
+func TestRestartableObjectStoreErrorKinds(t *testing.T) {
+	restarting := &restartInProgressError{err: errors.New("plugin process not ready")}
+
+	tests := []struct {
+		name         string
+		resetErr     error
+		delegateErr  error
+		expectedKind error
+	}{
+		{
+			name:         "restarts of the plugin's process are transient",
+			resetErr:     restarting,
+			expectedKind: osv2.ErrTransient,
+		},
+		{
+			name:         "server errors are transient",
+			delegateErr:  errors.Wrap(statusCodeError(503), "get object"),
+			expectedKind: osv2.ErrTransient,
+		},
+		{
+			name:         "throttling is transient",
+			delegateErr:  statusCodeError(429),
+			expectedKind: osv2.ErrTransient,
+		},
+		{
+			name:         "forbidden requests are denied access",
+			delegateErr:  statusCodeError(403),
+			expectedKind: osv2.ErrAccessDenied,
+		},
+		{
+			name:         "errors the plugin classified keep their kind",
+			delegateErr:  osv2.ErrBucketNotFound,
+			expectedKind: osv2.ErrBucketNotFound,
+		},
+		{
+			name:        "other errors aren't classified",
+			delegateErr: errors.New("invalid object key"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := new(mockRestartableProcess)
+			p.Test(t)
+			defer p.AssertExpectations(t)
+
+			key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+			r := &restartableObjectStore{
+				key:                 key,
+				sharedPluginProcess: p,
+			}
+
+			expectedErr := tc.resetErr
+			p.On("resetIfNeeded", mock.Anything).Return(tc.resetErr)
+			if tc.resetErr == nil {
+				expectedErr = tc.delegateErr
+				objectStore := new(osv2mocks.ObjectStore)
+				objectStore.Test(t)
+				defer objectStore.AssertExpectations(t)
+				p.On("getByKindAndName", key).Return(objectStore, nil)
+				objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "key").Return(osv2.ObjectInfo{}, tc.delegateErr)
+			}
+
+			_, err := r.GetObjectInfoV2(context.Background(), "bucket", "key")
+			assert.EqualError(t, err, "GetObjectInfo bucket/key: "+expectedErr.Error())
+			assert.True(t, errors.Is(err, expectedErr))
+			for _, kind := range errorKinds {
+				assert.Equal(t, kind == tc.expectedKind, errors.Is(err, kind), "kind %v", kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+const (
+	// DefaultObjectStoreMetricsMaxLabels is the default number of distinct operation labels that object
+	// store metrics are broken down by.
+	DefaultObjectStoreMetricsMaxLabels = 100
+
+	operationMetricLabel = "operation"
+	methodMetricLabel    = "method"
+	directionMetricLabel = "direction"
+	pluginMetricLabel    = "plugin"
+)
+
+type operationLabelKey struct{}
+
+// WithOperationLabel returns a copy of ctx which identifies the backup or restore on whose behalf object
+// store calls are made. Calls to the restartableObjectStore's context-aware methods with such a context are
+// counted in the object store request and byte metrics under label.
+func WithOperationLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, operationLabelKey{}, label)
+}
+
+// ForOperation returns r with the calls made through its v1 methods counted under label, which identifies the
+// backup or restore they are made on behalf of, in the object store request and byte metrics.
+func (r *restartableObjectStore) ForOperation(label string) velero.ObjectStore {
+	return &operationObjectStore{restartableObjectStore: r, ctx: WithOperationLabel(context.Background(), label)}
+}
+
+// operationObjectStore is a restartableObjectStore whose v1 methods make their calls with ctx.
+type operationObjectStore struct {
+	*restartableObjectStore
+	ctx context.Context
+}
+
+func (o *operationObjectStore) PutObject(bucket string, key string, body io.Reader) error {
+	return o.PutObjectV2(o.ctx, bucket, key, body)
+}
+
+func (o *operationObjectStore) ObjectExists(bucket, key string) (bool, error) {
+	return o.ObjectExistsV2(o.ctx, bucket, key)
+}
+
+func (o *operationObjectStore) GetObject(bucket string, key string) (io.ReadCloser, error) {
+	return o.GetObjectV2(o.ctx, bucket, key)
+}
+
+func (o *operationObjectStore) ListCommonPrefixes(bucket string, prefix string, delimiter string) ([]string, error) {
+	return o.ListCommonPrefixesV2(o.ctx, bucket, prefix, delimiter)
+}
+
+func (o *operationObjectStore) ListObjects(bucket string, prefix string) ([]string, error) {
+	return o.ListObjectsV2(o.ctx, bucket, prefix)
+}
+
+func (o *operationObjectStore) DeleteObject(bucket string, key string) error {
+	return o.DeleteObjectV2(o.ctx, bucket, key)
+}
+
+func (o *operationObjectStore) CreateSignedURL(bucket string, key string, ttl time.Duration) (string, error) {
+	return o.CreateSignedURLV2(o.ctx, bucket, key, ttl, osv2.SignedURLOptions{Method: osv2.SignedURLMethodGet})
+}
+
+// operationLabelFrom returns the label set on ctx by WithOperationLabel, if any.
+func operationLabelFrom(ctx context.Context) (string, bool) {
+	label, ok := ctx.Value(operationLabelKey{}).(string)
+	return label, ok && label != ""
+}
+
+// objectStoreMetrics counts object store requests and bytes transferred per operation label. The number of
+// distinct labels is capped to bound the metrics' cardinality; once the cap is reached, the series of the least
+// recently used label are deleted to make room for a new one. It also records the latency and failures of the calls made on each object store plugin,
+// and the plugins' restarts.
+type objectStoreMetrics struct {
+	requests *prometheus.CounterVec
+	bytes    *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	failures *prometheus.CounterVec
+	restarts *prometheus.CounterVec
+
+	// lock guards the fields below. labels holds the *labelSeries of each tracked label, and order has them
+	// from the most to the least recently used.
+	lock      sync.Mutex
+	maxLabels int
+	labels    map[string]*list.Element
+	order     *list.List
+}
+
+// labelSeries are the series of the request and byte metrics recorded under label.
+type labelSeries struct {
+	label      string
+	methods    map[string]struct{}
+	directions map[string]struct{}
+}
+
+func newObjectStoreMetrics(maxLabels int) *objectStoreMetrics {
+	return &objectStoreMetrics{
+		requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "velero",
+				Name:      "object_store_requests_total",
+				Help:      "Total number of object store requests made on behalf of a backup or restore",
+			},
+			[]string{operationMetricLabel, methodMetricLabel},
+		),
+		bytes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "velero",
+				Name:      "object_store_bytes_total",
+				Help:      "Total number of bytes uploaded to or downloaded from the object store on behalf of a backup or restore",
+			},
+			[]string{operationMetricLabel, directionMetricLabel},
+		),
+		latency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: "velero",
+				Name:      "object_store_plugin_operation_duration_seconds",
+				Help:      "Time taken by operations made on object store plugins, in seconds",
+				Buckets:   prometheus.ExponentialBuckets(0.005, 4, 8),
+			},
+			[]string{pluginMetricLabel, methodMetricLabel},
+		),
+		failures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "velero",
+				Name:      "object_store_plugin_operation_failures_total",
+				Help:      "Total number of operations made on object store plugins which failed",
+			},
+			[]string{pluginMetricLabel, methodMetricLabel},
+		),
+		restarts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "velero",
+				Name:      "object_store_plugin_restarts_total",
+				Help:      "Total number of times object store plugins were reinitialized after their process was restarted",
+			},
+			[]string{pluginMetricLabel},
+		),
+		maxLabels: maxLabels,
+		labels:    make(map[string]*list.Element),
+		order:     list.New(),
+	}
+}
+
+// defaultObjectStoreMetrics are the metrics recorded by every restartableObjectStore.
+var defaultObjectStoreMetrics = newObjectStoreMetrics(DefaultObjectStoreMetricsMaxLabels)
+
+// RegisterObjectStoreMetrics registers the object store metrics with prometheus, limiting the request and byte
+// metrics to maxLabels distinct operation labels.
+func RegisterObjectStoreMetrics(maxLabels int) {
+	defaultObjectStoreMetrics.setMaxLabels(maxLabels)
+	prometheus.MustRegister(
+		defaultObjectStoreMetrics.requests,
+		defaultObjectStoreMetrics.bytes,
+		defaultObjectStoreMetrics.latency,
+		defaultObjectStoreMetrics.failures,
+		defaultObjectStoreMetrics.restarts,
+	)
+}
+
+func (m *objectStoreMetrics) setMaxLabels(maxLabels int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.maxLabels = maxLabels
+}
+
+// seriesLH returns the series of label, tracking label as the most recently used, and evicting the least recently
+// used label if there are too many.
+//
+// Callers of seriesLH *must* hold m.lock.
+func (m *objectStoreMetrics) seriesLH(label string) *labelSeries {
+	if elem, ok := m.labels[label]; ok {
+		m.order.MoveToFront(elem)
+		return elem.Value.(*labelSeries)
+	}
+
+	for m.order.Len() > 0 && m.order.Len() >= m.maxLabels {
+		m.evictLH(m.order.Back())
+	}
+	series := &labelSeries{label: label, methods: make(map[string]struct{}), directions: make(map[string]struct{})}
+	m.labels[label] = m.order.PushFront(series)
+	return series
+}
+
+// evictLH stops tracking the label of elem and deletes its series.
+//
+// Callers of evictLH *must* hold m.lock.
+func (m *objectStoreMetrics) evictLH(elem *list.Element) {
+	series := m.order.Remove(elem).(*labelSeries)
+	delete(m.labels, series.label)
+	for method := range series.methods {
+		m.requests.DeleteLabelValues(series.label, method)
+	}
+	for direction := range series.directions {
+		m.bytes.DeleteLabelValues(series.label, direction)
+	}
+}
+
+// requestCounter returns the counter of the requests made to method under label.
+func (m *objectStoreMetrics) requestCounter(label, method string) prometheus.Counter {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.seriesLH(label).methods[method] = struct{}{}
+	return m.requests.WithLabelValues(label, method)
+}
+
+// byteCounter returns the counter of the bytes transferred in direction under label.
+func (m *objectStoreMetrics) byteCounter(label, direction string) prometheus.Counter {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.seriesLH(label).directions[direction] = struct{}{}
+	return m.bytes.WithLabelValues(label, direction)
+}
+
+// observeRequest counts a call to method if ctx carries an operation label.
+func (m *objectStoreMetrics) observeRequest(ctx context.Context, method string) {
+	if label, ok := operationLabelFrom(ctx); ok {
+		m.requestCounter(label, method).Inc()
+	}
+}
+
+func (m *objectStoreMetrics) observeOperation(plugin, method string, latency time.Duration, failed bool) {
+	m.latency.WithLabelValues(plugin, method).Observe(latency.Seconds())
+	if failed {
+		m.failures.WithLabelValues(plugin, method).Inc()
+	}
+}
+
+func (m *objectStoreMetrics) observeRestart(plugin string) {
+	m.restarts.WithLabelValues(plugin).Inc()
+}
+
+// countUploaded returns body wrapped so that the bytes read from it are counted as uploaded, if ctx carries
+// an operation label.
+func (m *objectStoreMetrics) countUploaded(ctx context.Context, body io.Reader) io.Reader {
+	label, ok := operationLabelFrom(ctx)
+	if !ok || body == nil {
+		return body
+	}
+	return &countingReader{Reader: body, counter: m.byteCounter(label, "upload")}
+}
+
+// countDownloaded returns body wrapped so that the bytes read from it are counted as downloaded, if ctx
+// carries an operation label.
+func (m *objectStoreMetrics) countDownloaded(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	label, ok := operationLabelFrom(ctx)
+	if !ok || body == nil {
+		return body
+	}
+	return &countingReadCloser{
+		countingReader: countingReader{Reader: body, counter: m.byteCounter(label, "download")},
+		closer:         body,
+	}
+}
+
+// countingReader adds the number of bytes read through it to counter.
+type countingReader struct {
+	io.Reader
+	counter prometheus.Counter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.counter.Add(float64(n))
+	return n, err
+}
+
+type countingReadCloser struct {
+	countingReader
+	closer io.Closer
+}
+
+func (c *countingReadCloser) Close() error {
+	return c.closer.Close()
+}
@@ -0,0 +1,116 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestObjectStoreMetrics(t *testing.T) {
+	m := newObjectStoreMetrics(2)
+
+	// calls without an operation label aren't counted
+	ctx := context.Background()
+	m.observeRequest(ctx, "PutObject")
+	body := strings.NewReader("data")
+	assert.Equal(t, body, m.countUploaded(ctx, body))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.requests))
+
+	backup1 := WithOperationLabel(ctx, "backup-1")
+	m.observeRequest(backup1, "PutObject")
+	m.observeRequest(backup1, "PutObject")
+	_, err := ioutil.ReadAll(m.countUploaded(backup1, strings.NewReader("12345")))
+	require.NoError(t, err)
+	rc := m.countDownloaded(backup1, ioutil.NopCloser(strings.NewReader("123")))
+	_, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("backup-1", "PutObject")))
+	assert.Equal(t, float64(5), testutil.ToFloat64(m.bytes.WithLabelValues("backup-1", "upload")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(m.bytes.WithLabelValues("backup-1", "download")))
+
+	// beyond the limit, the series of the least recently used labels are deleted to make room for new ones
+	m.observeRequest(WithOperationLabel(ctx, "backup-2"), "GetObject")
+	m.observeRequest(backup1, "GetObject")
+	m.observeRequest(WithOperationLabel(ctx, "backup-3"), "GetObject")
+
+	assert.Equal(t, 3, testutil.CollectAndCount(m.requests))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.requests.WithLabelValues("backup-1", "PutObject")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("backup-1", "GetObject")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.requests.WithLabelValues("backup-3", "GetObject")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.bytes))
+
+	m.observeRequest(WithOperationLabel(ctx, "backup-4"), "GetObject")
+	assert.Equal(t, 2, testutil.CollectAndCount(m.requests))
+	assert.Equal(t, 0, testutil.CollectAndCount(m.bytes))
+}
+
+func TestRestartableObjectStoreForOperation(t *testing.T) {
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", test.NewFakeObjectStore("bucket"))
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	// the calls are counted under the label of the operation whatever method they're made through
+	objectStore := r.ForOperation("for-operation-backup")
+	require.NoError(t, objectStore.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader("12345")))
+	exists, err := objectStore.ObjectExists("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	rc, err := objectStore.GetObject("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	_, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+
+	requests := defaultObjectStoreMetrics.requests
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("for-operation-backup", "PutObject")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("for-operation-backup", "ObjectExists")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("for-operation-backup", "GetObject")))
+	bytes := defaultObjectStoreMetrics.bytes
+	assert.Equal(t, float64(5), testutil.ToFloat64(bytes.WithLabelValues("for-operation-backup", "upload")))
+	assert.Equal(t, float64(5), testutil.ToFloat64(bytes.WithLabelValues("for-operation-backup", "download")))
+
+	// the other calls aren't
+	require.NoError(t, r.PutObject("bucket", "backups/b2/b2.tar.gz", strings.NewReader("12345")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues("for-operation-backup", "PutObject")))
+}
+
+func TestObjectStoreMetricsOperations(t *testing.T) {
+	m := newObjectStoreMetrics(2)
+
+	m.observeOperation("aws", "PutObject", 10*time.Millisecond, false)
+	m.observeOperation("aws", "PutObject", 20*time.Millisecond, true)
+	m.observeOperation("gcp", "GetObject", time.Second, false)
+	m.observeRestart("aws")
+
+	assert.Equal(t, 2, testutil.CollectAndCount(m.latency))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.failures.WithLabelValues("aws", "PutObject")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(m.failures.WithLabelValues("gcp", "GetObject")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.restarts.WithLabelValues("aws")))
+}
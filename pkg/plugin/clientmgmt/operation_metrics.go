@@ -0,0 +1,66 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// operationObserver records the operations restartableObjectStores make on their plugins.
+type operationObserver interface {
+	// observeOperation records a call of method, e.g. "PutObject", on the object store plugin named plugin, which
+	// took latency and failed if failed is true.
+	observeOperation(plugin, method string, latency time.Duration, failed bool)
+	// observeRestart records that the object store plugin named plugin was reinitialized because its process was
+	// restarted.
+	observeRestart(plugin string)
+}
+
+// objectStoreOperation is an operation in progress on a restartableObjectStore.
+type objectStoreOperation struct {
+	method string
+	bucket string
+	key    string
+	start  time.Time
+	span   trace.Span
+}
+
+// startOperation starts the operation method, e.g. "PutObject", starting a span for it with startSpan and counting it
+// in the object store request metrics if ctx carries an operation label.
+func (r *restartableObjectStore) startOperation(ctx context.Context, method, bucket, key string) (context.Context, *objectStoreOperation) {
+	ctx, span := r.startSpan(ctx, method, bucket, key)
+	defaultObjectStoreMetrics.observeRequest(ctx, method)
+	return ctx, &objectStoreOperation{method: method, bucket: bucket, key: key, start: time.Now(), span: span}
+}
+
+// observeOperation records op, which ended with err, with r's operation metrics, if any. Only errors which count
+// towards the failure events are counted as failures.
+func (r *restartableObjectStore) observeOperation(op *objectStoreOperation, err error) {
+	if r.operationMetrics != nil {
+		r.operationMetrics.observeOperation(r.key.name, op.method, time.Since(op.start), err != nil && countsAsFailure(err))
+	}
+}
+
+// observeRestart records a restart of r's plugin with r's operation metrics, if any.
+func (r *restartableObjectStore) observeRestart() {
+	if r.operationMetrics != nil {
+		r.operationMetrics.observeRestart(r.key.name)
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+type observedOperation struct {
+	plugin, method string
+	failed         bool
+}
+
+// fakeOperationMetrics records the operations and restarts observed with it.
+type fakeOperationMetrics struct {
+	lock       sync.Mutex
+	operations []observedOperation
+	restarts   map[string]int
+}
+
+func (m *fakeOperationMetrics) observeOperation(plugin, method string, latency time.Duration, failed bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.operations = append(m.operations, observedOperation{plugin: plugin, method: method, failed: failed})
+}
+
+func (m *fakeOperationMetrics) observeRestart(plugin string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.restarts == nil {
+		m.restarts = make(map[string]int)
+	}
+	m.restarts[plugin]++
+}
+
+func TestRestartableObjectStoreOperationMetrics(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	assert.Equal(t, defaultObjectStoreMetrics, r.operationMetrics)
+
+	metrics := new(fakeOperationMetrics)
+	r.operationMetrics = metrics
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "").Return([]string{"key"}, nil).Once()
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(errors.New("service unavailable")).Once()
+	// objects which don't exist don't indicate that the object store is failing
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "missing").Return(osv2.ErrObjectNotFound).Once()
+
+	_, err := r.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Error(t, r.DeleteObjectV2(context.Background(), "bucket", "key"))
+	assert.Error(t, r.DeleteObjectV2(context.Background(), "bucket", "missing"))
+	require.NoError(t, p.reset(context.Background()))
+
+	assert.Equal(t, []observedOperation{
+		{plugin: "fake", method: "Init"},
+		{plugin: "fake", method: "ListObjects"},
+		{plugin: "fake", method: "DeleteObject", failed: true},
+		{plugin: "fake", method: "DeleteObject"},
+	}, metrics.operations)
+	assert.Equal(t, map[string]int{"fake": 1}, metrics.restarts)
+
+	objectStore.AssertExpectations(t)
+}
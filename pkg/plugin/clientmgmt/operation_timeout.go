@@ -0,0 +1,92 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// operationTimeoutConfigKey is the config key used to set how long each object store operation may take, as a
+// duration such as "5m".
+const operationTimeoutConfigKey = "operationTimeout"
+
+// withOperationTimeout returns a context which is done once the operationTimeout has passed, if one is set, and a
+// func to call with the operation's error when it's done. The func releases the context, and reports an operation
+// which failed because it ran out of time as a context.DeadlineExceeded error, whatever the plugin returned.
+func (r *restartableObjectStore) withOperationTimeout(ctx context.Context) (context.Context, func(error) error) {
+	timeout := r.currentConfig().operationTimeout
+	if timeout <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func(err error) error {
+		defer cancel()
+		if err == nil || ctx.Err() != context.DeadlineExceeded {
+			return err
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errors.Wrapf(err, "object store operation did not complete within %v", timeout)
+		}
+		return errors.Wrapf(context.DeadlineExceeded, "object store operation did not complete within %v (%v)", timeout, err)
+	}
+}
+
+// callV1 calls the v1 plugin method call, giving up waiting for it if ctx is done first, as v1 plugins can't be told
+// to give up themselves. If call still returns a value after that, it's passed to release so that any resources it
+// holds can be freed.
+func callV1(ctx context.Context, call func() (interface{}, error), release func(interface{})) (interface{}, error) {
+	if ctx.Done() == nil {
+		return call()
+	}
+
+	results := make(chan hedgedResult, 1)
+	go func() {
+		value, err := call()
+		results <- hedgedResult{value: value, err: err}
+	}()
+
+	select {
+	case res := <-results:
+		return res.value, res.err
+	case <-ctx.Done():
+		go func() {
+			res := <-results
+			if res.err == nil && release != nil {
+				release(res.value)
+			}
+		}()
+		return nil, errors.Wrap(ctx.Err(), "gave up waiting for object store plugin")
+	}
+}
+
+// releasingReadCloser calls release once it's closed, e.g. to release the context the object it reads was
+// retrieved with.
+type releasingReadCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releasingReadCloser) Close() error {
+	defer r.once.Do(r.release)
+	return r.ReadCloser.Close()
+}
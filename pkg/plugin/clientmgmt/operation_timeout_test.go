@@ -0,0 +1,120 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// hangingObjectStore is a v1 object store whose PutObject calls block until unblock is closed.
+type hangingObjectStore struct {
+	*test.FakeObjectStore
+	unblock chan struct{}
+}
+
+func (h *hangingObjectStore) PutObject(bucket, key string, body io.Reader) error {
+	<-h.unblock
+	return h.FakeObjectStore.PutObject(bucket, key, body)
+}
+
+func TestRestartableObjectStoreOperationTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      map[string]string
+		expectedErr string
+	}{
+		{
+			name:   "operations wait for the plugin by default",
+			config: map[string]string{},
+		},
+		{
+			name:        "operations give up on a hung v1 plugin after the timeout",
+			config:      map[string]string{operationTimeoutConfigKey: "50ms"},
+			expectedErr: "PutObject bucket/backups/b1/b1.tar.gz: object store operation did not complete within 50ms: gave up waiting for object store plugin: context deadline exceeded",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			objectStore := &hangingObjectStore{FakeObjectStore: test.NewFakeObjectStore("bucket"), unblock: make(chan struct{})}
+			p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+			r := newRestartableObjectStore("fake", p, test.NewLogger())
+			require.NoError(t, r.Init(tc.config))
+
+			time.AfterFunc(200*time.Millisecond, func() { close(objectStore.unblock) })
+			err := r.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup"))
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+			assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		})
+	}
+}
+
+func TestRestartableObjectStoreOperationTimeoutV2Plugin(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{operationTimeoutConfigKey: "50ms"}))
+
+	// a v2 plugin is given the deadline, and may fail with an error of its own when it passes
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(errors.New("rpc error: code = DeadlineExceeded")).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		})
+	err := r.DeleteObject("bucket", "key")
+	assert.EqualError(t, err, "DeleteObject bucket/key: object store operation did not complete within 50ms (rpc error: code = DeadlineExceeded): context deadline exceeded")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// and so are the methods without a v1 equivalent
+	objectStore.On("ListObjectVersions", mock.Anything, "bucket", "backups/").Return(nil, errors.New("rpc error: code = DeadlineExceeded")).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		})
+	_, err = r.ListObjectVersions(context.Background(), "bucket", "backups/")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// objects can still be read once GetObject has returned, until they're closed
+	objectStore.On("GetObjectV2", mock.Anything, "bucket", "key").Return(ioutil.NopCloser(strings.NewReader("backup")), nil)
+	rc, err := r.GetObject("bucket", "key")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(content))
+	assert.NoError(t, rc.Close())
+	objectStore.AssertExpectations(t)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryableErrorClassifier reports whether err, returned by an idempotent object store read, is transient and
+// worth retrying.
+type RetryableErrorClassifier func(err error) bool
+
+var (
+	retryableErrorClassifiersLock sync.RWMutex
+	retryableErrorClassifiers     = make(map[string]RetryableErrorClassifier)
+)
+
+// RegisterRetryableErrorClassifier registers classifier for the object store plugin with the given name, e.g.
+// "velero.io/aws", so that errors it recognizes are retried in addition to the ones retried by default.
+func RegisterRetryableErrorClassifier(name string, classifier RetryableErrorClassifier) {
+	retryableErrorClassifiersLock.Lock()
+	defer retryableErrorClassifiersLock.Unlock()
+
+	retryableErrorClassifiers[name] = classifier
+}
+
+// isRetryableReadError reports whether err, returned by a read from the object store plugin with the given
+// name, should be retried. Timeouts and errors reporting a 5xx status code are retried for all plugins.
+func isRetryableReadError(name string, err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	retryableErrorClassifiersLock.RLock()
+	classifier := retryableErrorClassifiers[name]
+	retryableErrorClassifiersLock.RUnlock()
+	if classifier != nil && classifier(err) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) && statusErr.StatusCode() >= 500 {
+		return true
+	}
+
+	return false
+}
+
+// retryRead calls read until it succeeds, fails with an error that isn't retryable, or readRetries retries
+// have been made, backing off exponentially from readRetryBackoff in between. It must only be used for
+// idempotent calls; it is independent of getDelegate, which restarts the plugin process if it has exited. Each
+// attempt takes a call slot, which is released before backing off so that other calls can use it in the meantime.
+func (r *restartableObjectStore) retryRead(ctx context.Context, read func() (interface{}, error)) (interface{}, error) {
+	config := r.currentConfig()
+	backoff := config.readRetryBackoff
+	for attempt := 0; ; attempt++ {
+		release, err := r.acquireCallSlot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		value, err := read()
+		release()
+		if err == nil || attempt >= config.readRetries || !isRetryableReadError(r.key.name, err) {
+			return value, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return value, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+)
+
+type statusCodeError int
+
+func (e statusCodeError) Error() string   { return "status code error" }
+func (e statusCodeError) StatusCode() int { return int(e) }
+
+func TestIsRetryableReadError(t *testing.T) {
+	RegisterRetryableErrorClassifier("test.io/classified", func(err error) bool {
+		return strings.Contains(err.Error(), "SlowDown")
+	})
+
+	tests := []struct {
+		name       string
+		pluginName string
+		err        error
+		expected   bool
+	}{
+		{name: "server error", err: statusCodeError(503), expected: true},
+		{name: "client error", err: statusCodeError(404), expected: false},
+		{name: "wrapped server error", err: errors.Wrap(statusCodeError(500), "get"), expected: true},
+		{name: "cancelled", err: context.Canceled, expected: false},
+		{name: "plain error", err: errors.New("SlowDown"), expected: false},
+		{name: "classified by the plugin's classifier", pluginName: "test.io/classified", err: errors.New("SlowDown"), expected: true},
+		{name: "not classified by the plugin's classifier", pluginName: "test.io/classified", err: errors.New("AccessDenied"), expected: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, isRetryableReadError(tc.pluginName, tc.err))
+		})
+	}
+}
+
+func TestRestartableObjectStoreReadRetries(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.On("getByKindAndName", key).Return(objectStore, nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{readRetriesConfigKey: "2", readRetryBackoffConfigKey: "1ms"}))
+	assert.Equal(t, 2, r.currentConfig().readRetries)
+	assert.Equal(t, time.Millisecond, r.currentConfig().readRetryBackoff)
+
+	// transient read failures are retried
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "prefix").Return(nil, statusCodeError(503)).Twice()
+	objectStore.On("ListObjectsV2", mock.Anything, "bucket", "prefix").Return([]string{"key"}, nil).Once()
+	keys, err := r.ListObjects("bucket", "prefix")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"key"}, keys)
+
+	// until the retries are exhausted
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "key").Return(osv2.ObjectInfo{}, statusCodeError(503)).Times(3)
+	_, err = r.ObjectExists("bucket", "key")
+	assert.True(t, errors.Is(err, statusCodeError(503)))
+
+	// other failures aren't retried
+	objectStore.On("ListCommonPrefixesV2", mock.Anything, "bucket", "prefix", "/").Return(nil, statusCodeError(403)).Once()
+	_, err = r.ListCommonPrefixes("bucket", "prefix", "/")
+	assert.True(t, errors.Is(err, statusCodeError(403)))
+
+	// and neither are writes
+	body := strings.NewReader("body")
+	objectStore.On("PutObjectV2", mock.Anything, "bucket", "key", body).Return(statusCodeError(503)).Once()
+	assert.True(t, errors.Is(r.PutObject("bucket", "key", body), statusCodeError(503)))
+}
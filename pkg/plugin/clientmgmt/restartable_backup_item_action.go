@@ -17,6 +17,7 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -61,7 +62,7 @@ func (r *restartableBackupItemAction) getBackupItemAction() (velero.BackupItemAc
 
 // getDelegate restarts the plugin process (if needed) and returns the backup item action for this restartableBackupItemAction.
 func (r *restartableBackupItemAction) getDelegate() (velero.BackupItemAction, error) {
-	if err := r.sharedPluginProcess.resetIfNeeded(); err != nil {
+	if err := r.sharedPluginProcess.resetIfNeeded(context.Background()); err != nil {
 		return nil, err
 	}
 
@@ -21,6 +21,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -81,7 +82,7 @@ func TestRestartableBackupItemActionGetDelegate(t *testing.T) {
 	defer p.AssertExpectations(t)
 
 	// Reset error
-	p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+	p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 	name := "pod"
 	r := newRestartableBackupItemAction(name, p)
 	a, err := r.getDelegate()
@@ -89,7 +90,7 @@ func TestRestartableBackupItemActionGetDelegate(t *testing.T) {
 	assert.EqualError(t, err, "reset error")
 
 	// Happy path
-	p.On("resetIfNeeded").Return(nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
 	expected := new(mocks.ItemAction)
 	key := kindAndName{kind: framework.PluginKindBackupItemAction, name: name}
 	p.On("getByKindAndName", key).Return(expected, nil)
@@ -32,6 +32,9 @@ type restartableDelegateTest struct {
 	inputs                  []interface{}
 	expectedErrorOutputs    []interface{}
 	expectedDelegateOutputs []interface{}
+	// expectedErrorPrefix is prepended to the messages of the expected
+	// errors, for restartables that wrap errors with what failed.
+	expectedErrorPrefix string
 }
 
 type mockable interface {
@@ -54,7 +57,7 @@ func runRestartableDelegateTests(
 			defer p.AssertExpectations(t)
 
 			// getDelegate error
-			p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+			p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 			name := "delegateName"
 			key := kindAndName{kind: kind, name: name}
 			r := newRestartable(key, p)
@@ -86,7 +89,7 @@ func runRestartableDelegateTests(
 					expectedErr, expectedErrOk := expected[i].(error)
 					// If both are errors, use EqualError
 					if actualErrOk && expectedErrOk {
-						assert.EqualError(t, actualErr, expectedErr.Error())
+						assert.EqualError(t, actualErr, tc.expectedErrorPrefix+expectedErr.Error())
 						continue
 					}
 
@@ -107,7 +110,7 @@ func runRestartableDelegateTests(
 			checkOutputs(tc.expectedErrorOutputs, actual)
 
 			// Invoke delegate, make sure all returned values are passed through
-			p.On("resetIfNeeded").Return(nil)
+			p.On("resetIfNeeded", mock.Anything).Return(nil)
 
 			delegate := newMock()
 			delegate.Test(t)
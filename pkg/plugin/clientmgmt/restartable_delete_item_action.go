@@ -17,6 +17,7 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
 	"github.com/pkg/errors"
 
 	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
@@ -60,7 +61,7 @@ func (r *restartableDeleteItemAction) getDeleteItemAction() (velero.DeleteItemAc
 
 // getDelegate restarts the plugin process (if needed) and returns the delete item action for this restartableDeleteItemAction.
 func (r *restartableDeleteItemAction) getDelegate() (velero.DeleteItemAction, error) {
-	if err := r.sharedPluginProcess.resetIfNeeded(); err != nil {
+	if err := r.sharedPluginProcess.resetIfNeeded(context.Background()); err != nil {
 		return nil, err
 	}
 
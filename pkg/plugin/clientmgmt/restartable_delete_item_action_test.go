@@ -21,6 +21,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -80,7 +81,7 @@ func TestRestartableDeleteItemActionGetDelegate(t *testing.T) {
 	defer p.AssertExpectations(t)
 
 	// Reset error
-	p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+	p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 	name := "pod"
 	r := newRestartableDeleteItemAction(name, p)
 	a, err := r.getDelegate()
@@ -89,7 +90,7 @@ func TestRestartableDeleteItemActionGetDelegate(t *testing.T) {
 
 	// Happy path
 	// Currently broken since this mocks out the restore item action interface
-	p.On("resetIfNeeded").Return(nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
 	expected := new(mocks.DeleteItemAction)
 	key := kindAndName{kind: framework.PluginKindDeleteItemAction, name: name}
 	p.On("getByKindAndName", key).Return(expected, nil)
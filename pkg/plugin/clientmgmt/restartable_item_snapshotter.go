@@ -59,7 +59,7 @@ func (r *restartableItemSnapshotter) getItemSnapshotter() (isv1.ItemSnapshotter,
 
 // getDelegate restarts the plugin process (if needed) and returns the item snapshotter for this restartableItemSnapshotter.
 func (r *restartableItemSnapshotter) getDelegate() (isv1.ItemSnapshotter, error) {
-	if err := r.sharedPluginProcess.resetIfNeeded(); err != nil {
+	if err := r.sharedPluginProcess.resetIfNeeded(context.Background()); err != nil {
 		return nil, err
 	}
 
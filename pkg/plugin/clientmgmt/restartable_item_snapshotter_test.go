@@ -25,6 +25,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -86,7 +87,7 @@ func TestRestartableItemSnapshotterGetDelegate(t *testing.T) {
 	defer p.AssertExpectations(t)
 
 	// Reset error
-	p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+	p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 	name := "pvc"
 	r := newRestartableItemSnapshotter(name, p)
 	a, err := r.getDelegate()
@@ -94,7 +95,7 @@ func TestRestartableItemSnapshotterGetDelegate(t *testing.T) {
 	assert.EqualError(t, err, "reset error")
 
 	// Happy path
-	p.On("resetIfNeeded").Return(nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
 	expected := new(mocks.ItemSnapshotter)
 	key := kindAndName{kind: framework.PluginKindItemSnapshotter, name: name}
 	p.On("getByKindAndName", key).Return(expected, nil)
@@ -17,13 +17,25 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"bytes"
+	"context"
 	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
 )
 
 // restartableObjectStore is an object store for a given implementation (such as "aws"). It is associated with
@@ -33,33 +45,268 @@ import (
 type restartableObjectStore struct {
 	key                 kindAndName
 	sharedPluginProcess RestartableProcess
-	// config contains the data used to initialize the plugin. It is used to reinitialize the plugin in the event its
-	// sharedPluginProcess gets restarted.
-	config map[string]string
+	// configLock guards config, which is replaced as a whole, never modified, when the object store is
+	// reinitialized with a new config.
+	configLock sync.RWMutex
+	config     *objectStoreConfig
+	// configProvider, if set, provides fresh config to reinitialize the plugin with instead of config.
+	configProvider ConfigProvider
+	reads          readDeduplicator
+	failureEvents  failureEvents
+	// operationMetrics records each operation and each restart of the plugin.
+	operationMetrics operationObserver
+	// closeFuncs are called once the plugins of the manager r came from are cleaned up.
+	closeLock  sync.Mutex
+	closeFuncs []func()
+	// restartRetries is how many times getting the plugin is retried while its process is still being
+	// restarted, and restartRetryBackoff how long to wait before the first retry; it doubles with each retry.
+	restartRetries      int
+	restartRetryBackoff time.Duration
+	// restarts counts how many times the plugin has been reinitialized after its process was restarted. It must
+	// be accessed atomically.
+	restarts uint32
+	logger   logrus.FieldLogger
+}
+
+// objectStoreConfig is the config an object store was initialized with, and the settings of the
+// restartableObjectStore itself read out of it. It is never modified once it's been built, so that operations
+// in flight keep working with the settings they started with when the object store is reinitialized.
+type objectStoreConfig struct {
+	// raw contains the data used to initialize the plugin. It is used to reinitialize the plugin in the event
+	// its process gets restarted. It is nil until the object store has been initialized.
+	raw map[string]string
+	// sortListings indicates whether the results of ListObjects and ListCommonPrefixes are sorted
+	// lexicographically before being returned, regardless of the order the plugin returns them in.
+	sortListings bool
+	// hedgeDelay is how long an idempotent read may take before a second, concurrent attempt is started.
+	// Zero disables hedging.
+	hedgeDelay time.Duration
+	// readRetries is how many times an idempotent read that failed with a transient error is retried.
+	// Zero disables retries.
+	readRetries int
+	// readRetryBackoff is how long to wait before the first retry of a read; it doubles with each retry.
+	readRetryBackoff time.Duration
+	// keyRewriter maps the keys callers use to the keys objects are stored under. It is nil if keys are used
+	// as they are.
+	keyRewriter KeyRewriter
+	// dedupReads indicates whether concurrent identical GetObject and ObjectExists calls are collapsed into a
+	// single call to the plugin.
+	dedupReads bool
+	// verifyChecksums indicates whether PutObject stores the SHA-256 digest of each object in its metadata, so
+	// that it can be verified when the object is read.
+	verifyChecksums bool
+	// uploadBufferSize is the size of the buffer PutObject reads the body of an object ahead into, so that
+	// the source of the body is decoupled from the upload but can't outpace it without bound. Zero passes
+	// the body straight through to the plugin.
+	uploadBufferSize int
+	// multipartPartSize is the size of the parts PutObjectMultipart splits objects into.
+	multipartPartSize int
+	// multipartParallelism is how many parts PutObjectMultipart uploads concurrently.
+	multipartParallelism int
+	// waitForConsistency indicates whether writes wait until the written object is visible before returning,
+	// for up to consistencyTimeout.
+	waitForConsistency bool
+	consistencyTimeout time.Duration
+	// softDelete indicates whether DeleteObject moves objects to the trash rather than deleting them, so that
+	// they can be recovered until they're purged with PurgeTrash.
+	softDelete bool
+	// failureEventThreshold is after how many consecutive failed operations a Warning event is recorded, if an
+	// event recorder has been set with SetEventRecorder.
+	failureEventThreshold int
+	// operationTimeout is how long each context-aware operation may take, including those the v1 methods are
+	// implemented with, before it's abandoned. Zero means operations may take as long as the plugin does.
+	operationTimeout time.Duration
+	// healthCheck indicates whether Healthy probes the plugin, and healthCheckTimeout is how long the plugin may
+	// take to answer the probe.
+	healthCheck        bool
+	healthCheckTimeout time.Duration
+	// maxConcurrentCalls is how many operations may be in flight on the plugin at once, and callSlots holds a
+	// token for each of them. callSlots is nil if their number isn't limited.
+	maxConcurrentCalls int
+	callSlots          chan struct{}
+}
+
+// newObjectStoreConfig returns the config of an object store which hasn't been initialized yet.
+func newObjectStoreConfig() *objectStoreConfig {
+	return &objectStoreConfig{
+		readRetryBackoff:      defaultReadRetryBackoff,
+		multipartPartSize:     defaultMultipartPartSize,
+		multipartParallelism:  defaultMultipartParallelism,
+		consistencyTimeout:    defaultConsistencyTimeout,
+		failureEventThreshold: defaultFailureEventThreshold,
+		healthCheckTimeout:    defaultHealthCheckTimeout,
+	}
+}
+
+const (
+	// sortListingsConfigKey is the config key used to enable sorting of listings.
+	sortListingsConfigKey = "sortListings"
+	// hedgeDelayConfigKey is the config key used to enable hedging of idempotent reads, as a duration
+	// such as "500ms".
+	hedgeDelayConfigKey = "hedgeDelay"
+	// readRetriesConfigKey is the config key used to set how many times transient failures of idempotent
+	// reads are retried.
+	readRetriesConfigKey = "readRetries"
+	// readRetryBackoffConfigKey is the config key used to set the initial backoff between read retries, as
+	// a duration such as "1s".
+	readRetryBackoffConfigKey = "readRetryBackoff"
+	// dedupReadsConfigKey is the config key used to enable sharing the results of concurrent identical reads.
+	dedupReadsConfigKey = "dedupReads"
+	// verifyChecksumsConfigKey is the config key used to enable storing the checksums of written objects.
+	verifyChecksumsConfigKey = "verifyChecksums"
+	// uploadBufferSizeConfigKey is the config key used to set the size of the upload buffer, as a quantity such
+	// as "8Mi".
+	uploadBufferSizeConfigKey = "uploadBufferSize"
+	// signingRegionConfigKey is the config key used to set the region requests are signed for, for object
+	// stores which require a different one than the region used to resolve their endpoint. Unlike the keys
+	// above it is passed on to the plugin, which signs the requests, and only validated here.
+	signingRegionConfigKey = "signingRegion"
+
+	defaultReadRetryBackoff = 500 * time.Millisecond
+
+	defaultRestartRetries      = 3
+	defaultRestartRetryBackoff = 500 * time.Millisecond
+)
+
+// restartableObjectStoreConfigKeys are the config keys handled by the restartableObjectStore itself. They are
+// not passed on to the plugin, which may reject keys it does not know about.
+var restartableObjectStoreConfigKeys = []string{
+	sortListingsConfigKey,
+	hedgeDelayConfigKey,
+	readRetriesConfigKey,
+	readRetryBackoffConfigKey,
+	dedupReadsConfigKey,
+	verifyChecksumsConfigKey,
+	uploadBufferSizeConfigKey,
+	multipartPartSizeConfigKey,
+	multipartParallelismConfigKey,
+	waitForConsistencyConfigKey,
+	consistencyTimeoutConfigKey,
+	softDeleteConfigKey,
+	failureEventThresholdConfigKey,
+	operationTimeoutConfigKey,
+	healthCheckConfigKey,
+	healthCheckTimeoutConfigKey,
+	maxConcurrentCallsConfigKey,
+	keyRewriterConfigKey,
+	keyPrefixConfigKey,
+	encodeKeysConfigKey,
 }
 
 // newRestartableObjectStore returns a new restartableObjectStore.
-func newRestartableObjectStore(name string, sharedPluginProcess RestartableProcess) *restartableObjectStore {
+func newRestartableObjectStore(name string, sharedPluginProcess RestartableProcess, logger logrus.FieldLogger) *restartableObjectStore {
 	key := kindAndName{kind: framework.PluginKindObjectStore, name: name}
 	r := &restartableObjectStore{
 		key:                 key,
 		sharedPluginProcess: sharedPluginProcess,
+		config:              newObjectStoreConfig(),
+		restartRetries:      defaultRestartRetries,
+		restartRetryBackoff: defaultRestartRetryBackoff,
+		operationMetrics:    defaultObjectStoreMetrics,
+		logger:              logger,
 	}
 
-	// Register our reinitializer so we can reinitialize after a restart with r.config.
+	// Register our reinitializer so we can reinitialize after a restart with the current config.
 	sharedPluginProcess.addReinitializer(key, r)
 
 	return r
 }
 
-// reinitialize reinitializes a re-dispensed plugin using the initial data passed to Init().
-func (r *restartableObjectStore) reinitialize(dispensed interface{}) error {
+// ConfigProvider returns the current config of an object store, e.g. built from its backup storage location and
+// the Secret holding its credentials, which may have changed since the object store was initialized. It's an alias
+// so that callers can set a provider without depending on this package.
+type ConfigProvider = func(ctx context.Context) (map[string]string, error)
+
+// SetConfigProvider makes r reinitialize the plugin with the config provider returns, rather than the config r was
+// last initialized with, when the plugin's process is restarted. A nil provider restores the default.
+func (r *restartableObjectStore) SetConfigProvider(provider ConfigProvider) {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+
+	r.configProvider = provider
+}
+
+// currentConfig returns the config r was last initialized with. Operations should get it once and use it
+// throughout, so that they see consistent settings if r is reinitialized while they're in flight.
+func (r *restartableObjectStore) currentConfig() *objectStoreConfig {
+	r.configLock.RLock()
+	defer r.configLock.RUnlock()
+
+	if r.config == nil {
+		// r wasn't built by newRestartableObjectStore
+		return newObjectStoreConfig()
+	}
+	return r.config
+}
+
+// setConfig makes config the config r was last initialized with. If the limit on concurrent calls to the plugin
+// is unchanged, the calls in flight keep holding their slots, so that the limit keeps holding across the change.
+func (r *restartableObjectStore) setConfig(config *objectStoreConfig) {
+	r.configLock.Lock()
+	defer r.configLock.Unlock()
+
+	if r.config != nil && r.config.maxConcurrentCalls == config.maxConcurrentCalls {
+		config.callSlots = r.config.callSlots
+	}
+	r.config = config
+}
+
+// reinitialize reinitializes a re-dispensed plugin using the config returned by the config provider if one is set,
+// or else the data last passed to Init() or UpdateConfig().
+func (r *restartableObjectStore) reinitialize(ctx context.Context, dispensed interface{}) error {
 	objectStore, ok := dispensed.(velero.ObjectStore)
 	if !ok {
 		return errors.Errorf("%T is not a ObjectStore!", dispensed)
 	}
+	atomic.AddUint32(&r.restarts, 1)
+	r.observeRestart()
 
-	return r.init(objectStore, r.config)
+	r.configLock.RLock()
+	provider := r.configProvider
+	r.configLock.RUnlock()
+	if provider != nil {
+		raw, err := provider(ctx)
+		var config *objectStoreConfig
+		if err == nil {
+			config, err = parseConfig(raw)
+		}
+		if err == nil {
+			err = checkSoftDelete(objectStore, config)
+		}
+		if err == nil {
+			r.setConfig(config)
+		} else {
+			r.logger.WithError(err).Warn("Unable to get fresh object store config, reinitializing the plugin with the previous config")
+		}
+	}
+
+	return r.init(ctx, objectStore, r.currentConfig().raw)
+}
+
+// OnClose makes r call f once the manager r came from cleans up its plugins, e.g. to stop reinitializing the plugin
+// with rotated credentials, as r mustn't be used after that.
+func (r *restartableObjectStore) OnClose(f func()) {
+	r.closeLock.Lock()
+	defer r.closeLock.Unlock()
+
+	r.closeFuncs = append(r.closeFuncs, f)
+}
+
+// close calls the funcs registered with OnClose.
+func (r *restartableObjectStore) close() {
+	r.closeLock.Lock()
+	closeFuncs := r.closeFuncs
+	r.closeFuncs = nil
+	r.closeLock.Unlock()
+
+	for _, f := range closeFuncs {
+		f()
+	}
+}
+
+// restartCount returns how many times the plugin has been reinitialized after its process was restarted.
+func (r *restartableObjectStore) restartCount() uint32 {
+	return atomic.LoadUint32(&r.restarts)
 }
 
 // getObjectStore returns the object store for this restartableObjectStore. It does *not* restart the
@@ -79,18 +326,371 @@ func (r *restartableObjectStore) getObjectStore() (velero.ObjectStore, error) {
 }
 
 // getDelegate restarts the plugin process (if needed) and returns the object store for this restartableObjectStore.
-func (r *restartableObjectStore) getDelegate() (velero.ObjectStore, error) {
-	if err := r.sharedPluginProcess.resetIfNeeded(); err != nil {
+// If the plugin process is still being restarted, getting the object store is retried up to r.restartRetries times,
+// backing off exponentially from r.restartRetryBackoff in between. Any other error is returned right away.
+func (r *restartableObjectStore) getDelegate(ctx context.Context) (velero.ObjectStore, error) {
+	backoff := r.restartRetryBackoff
+	for attempt := 0; ; attempt++ {
+		objectStore, err := r.resetAndGetObjectStore(ctx)
+		if err == nil || attempt >= r.restartRetries || !isRestartInProgress(err) {
+			return objectStore, err
+		}
+		r.logger.WithError(err).Debugf("Object store plugin is still restarting, retrying in %v", backoff)
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// resetAndGetObjectStore restarts the plugin process if needed and returns the object store for this
+// restartableObjectStore.
+func (r *restartableObjectStore) resetAndGetObjectStore(ctx context.Context) (velero.ObjectStore, error) {
+	if err := r.sharedPluginProcess.resetIfNeeded(ctx); err != nil {
 		return nil, err
 	}
 
 	return r.getObjectStore()
 }
 
+// getDelegateV2 restarts the plugin process (if needed) and returns the object store for this restartableObjectStore
+// as a v2 ObjectStore, adapting it if the plugin only implements the v1 API.
+func (r *restartableObjectStore) getDelegateV2(ctx context.Context) (osv2.ObjectStore, error) {
+	delegate, err := r.getDelegate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if objectStore, ok := delegate.(osv2.ObjectStore); ok {
+		return objectStore, nil
+	}
+	return newAdaptedV1ObjectStore(delegate, r.logger), nil
+}
+
 // Init initializes the object store instance using config. If this is the first invocation, r stores config for future
 // reinitialization needs. Init does NOT restart the shared plugin process. Init may only be called once.
 func (r *restartableObjectStore) Init(config map[string]string) error {
-	if r.config != nil {
+	return r.InitV2(context.Background(), config)
+}
+
+// init calls Init on objectStore with config. This is split out from Init() so that both Init() and reinitialize() may
+// call it using a specific ObjectStore. If ctx is done before a v1 plugin's Init returns, init gives up waiting for it
+// and returns an error, so that a hung plugin can't block the caller forever.
+func (r *restartableObjectStore) init(ctx context.Context, objectStore velero.ObjectStore, config map[string]string) error {
+	if objectStoreV2, ok := objectStore.(osv2.ObjectStore); ok {
+		return objectStoreV2.InitV2(ctx, pluginConfig(config))
+	}
+	if ctx.Done() == nil {
+		return objectStore.Init(pluginConfig(config))
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- objectStore.Init(pluginConfig(config))
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "timed out initializing object store plugin")
+	}
+}
+
+// parseConfig returns the config of an object store initialized with config, reading the keys handled by the
+// restartableObjectStore itself out of it. Keys which aren't set keep their default values.
+func parseConfig(config map[string]string) (*objectStoreConfig, error) {
+	c := newObjectStoreConfig()
+	c.raw = config
+
+	if val, ok := config[sortListingsConfigKey]; ok {
+		sortListings, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", sortListingsConfigKey)
+		}
+		c.sortListings = sortListings
+	}
+
+	if val, ok := config[hedgeDelayConfigKey]; ok {
+		hedgeDelay, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", hedgeDelayConfigKey)
+		}
+		c.hedgeDelay = hedgeDelay
+	}
+
+	if val, ok := config[readRetriesConfigKey]; ok {
+		readRetries, err := strconv.Atoi(val)
+		if err != nil || readRetries < 0 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", readRetriesConfigKey, val)
+		}
+		c.readRetries = readRetries
+	}
+
+	if val, ok := config[readRetryBackoffConfigKey]; ok {
+		readRetryBackoff, err := time.ParseDuration(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", readRetryBackoffConfigKey)
+		}
+		c.readRetryBackoff = readRetryBackoff
+	}
+
+	if val, ok := config[dedupReadsConfigKey]; ok {
+		dedupReads, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", dedupReadsConfigKey)
+		}
+		c.dedupReads = dedupReads
+	}
+
+	if val, ok := config[verifyChecksumsConfigKey]; ok {
+		verifyChecksums, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", verifyChecksumsConfigKey)
+		}
+		c.verifyChecksums = verifyChecksums
+	}
+
+	if val, ok := config[uploadBufferSizeConfigKey]; ok {
+		quantity, err := resource.ParseQuantity(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", uploadBufferSizeConfigKey)
+		}
+		size, ok := quantity.AsInt64()
+		if !ok || size < 0 || size > math.MaxInt32 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", uploadBufferSizeConfigKey, val)
+		}
+		c.uploadBufferSize = int(size)
+	}
+
+	if val, ok := config[multipartPartSizeConfigKey]; ok {
+		quantity, err := resource.ParseQuantity(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", multipartPartSizeConfigKey)
+		}
+		size, ok := quantity.AsInt64()
+		if !ok || size <= 0 || size > math.MaxInt32 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", multipartPartSizeConfigKey, val)
+		}
+		c.multipartPartSize = int(size)
+	}
+
+	if val, ok := config[multipartParallelismConfigKey]; ok {
+		multipartParallelism, err := strconv.Atoi(val)
+		if err != nil || multipartParallelism < 1 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", multipartParallelismConfigKey, val)
+		}
+		c.multipartParallelism = multipartParallelism
+	}
+
+	if val, ok := config[waitForConsistencyConfigKey]; ok {
+		waitForConsistency, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", waitForConsistencyConfigKey)
+		}
+		c.waitForConsistency = waitForConsistency
+	}
+
+	if val, ok := config[consistencyTimeoutConfigKey]; ok {
+		consistencyTimeout, err := time.ParseDuration(val)
+		if err != nil || consistencyTimeout <= 0 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", consistencyTimeoutConfigKey, val)
+		}
+		c.consistencyTimeout = consistencyTimeout
+	}
+
+	if val, ok := config[softDeleteConfigKey]; ok {
+		softDelete, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", softDeleteConfigKey)
+		}
+		c.softDelete = softDelete
+	}
+
+	if val, ok := config[failureEventThresholdConfigKey]; ok {
+		failureEventThreshold, err := strconv.Atoi(val)
+		if err != nil || failureEventThreshold < 1 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", failureEventThresholdConfigKey, val)
+		}
+		c.failureEventThreshold = failureEventThreshold
+	}
+
+	if val, ok := config[operationTimeoutConfigKey]; ok {
+		operationTimeout, err := time.ParseDuration(val)
+		if err != nil || operationTimeout < 0 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", operationTimeoutConfigKey, val)
+		}
+		c.operationTimeout = operationTimeout
+	}
+
+	if val, ok := config[healthCheckConfigKey]; ok {
+		healthCheck, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value for config key %q", healthCheckConfigKey)
+		}
+		c.healthCheck = healthCheck
+	}
+
+	if val, ok := config[healthCheckTimeoutConfigKey]; ok {
+		healthCheckTimeout, err := time.ParseDuration(val)
+		if err != nil || healthCheckTimeout <= 0 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", healthCheckTimeoutConfigKey, val)
+		}
+		c.healthCheckTimeout = healthCheckTimeout
+	}
+
+	if val, ok := config[maxConcurrentCallsConfigKey]; ok {
+		maxConcurrentCalls, err := strconv.Atoi(val)
+		if err != nil || maxConcurrentCalls < 0 {
+			return nil, errors.Errorf("invalid value for config key %q: %q", maxConcurrentCallsConfigKey, val)
+		}
+		c.maxConcurrentCalls = maxConcurrentCalls
+	}
+
+	if val, ok := config[signingRegionConfigKey]; ok && (val == "" || strings.ContainsAny(val, " \t\n/")) {
+		return nil, errors.Errorf("invalid value for config key %q: %q", signingRegionConfigKey, val)
+	}
+
+	keyRewriter, err := newKeyRewriter(config)
+	if err != nil {
+		return nil, err
+	}
+	c.keyRewriter = keyRewriter
+
+	if c.maxConcurrentCalls > 0 {
+		c.callSlots = make(chan struct{}, c.maxConcurrentCalls)
+	}
+	return c, nil
+}
+
+// pluginConfig returns a copy of config without the keys handled by the restartableObjectStore.
+func pluginConfig(config map[string]string) map[string]string {
+	if config == nil {
+		return nil
+	}
+
+	res := make(map[string]string, len(config))
+	for k, v := range config {
+		res[k] = v
+	}
+	for _, k := range restartableObjectStoreConfigKeys {
+		delete(res, k)
+	}
+	return res
+}
+
+// emptyBodyIfNil returns body, or an empty reader if body is nil, so that plugins are always given a reader
+// to create an object from, even a zero-byte one.
+func emptyBodyIfNil(body io.Reader) io.Reader {
+	if body == nil {
+		return bytes.NewReader(nil)
+	}
+	return body
+}
+
+// emptyObjectIfNil returns rc, or a reader which is immediately at EOF if rc is nil, as some plugins return a
+// nil reader rather than an empty one for zero-byte objects.
+func emptyObjectIfNil(rc io.ReadCloser) io.ReadCloser {
+	if rc == nil {
+		return ioutil.NopCloser(bytes.NewReader(nil))
+	}
+	return rc
+}
+
+// storedKey returns the key that key is stored under.
+func (r *restartableObjectStore) storedKey(key string) string {
+	keyRewriter := r.currentConfig().keyRewriter
+	if keyRewriter == nil {
+		return key
+	}
+	return keyRewriter.Rewrite(key)
+}
+
+// storedKeys returns the keys that keys are stored under.
+func (r *restartableObjectStore) storedKeys(keys []string) []string {
+	keyRewriter := r.currentConfig().keyRewriter
+	if keyRewriter == nil {
+		return keys
+	}
+	res := make([]string, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, keyRewriter.Rewrite(key))
+	}
+	return res
+}
+
+// restoreKeys maps the stored keys of a listing back to the keys callers use, dropping any stored keys that
+// were not written through the key rewriter.
+func (r *restartableObjectStore) restoreKeys(storedKeys []string, err error) ([]string, error) {
+	keyRewriter := r.currentConfig().keyRewriter
+	if err != nil || keyRewriter == nil {
+		return storedKeys, err
+	}
+	res := make([]string, 0, len(storedKeys))
+	for _, storedKey := range storedKeys {
+		if key, ok := keyRewriter.Restore(storedKey); ok {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+// sortListing sorts the result of a listing if r is configured to do so.
+func (r *restartableObjectStore) sortListing(keys []string, err error) ([]string, error) {
+	if err != nil || !r.currentConfig().sortListings {
+		return keys, err
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// PutObject restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) PutObject(bucket string, key string, body io.Reader) error {
+	return r.PutObjectV2(context.Background(), bucket, key, body)
+}
+
+// ObjectExists restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ObjectExists(bucket, key string) (bool, error) {
+	return r.ObjectExistsV2(context.Background(), bucket, key)
+}
+
+// GetObject restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) GetObject(bucket string, key string) (io.ReadCloser, error) {
+	return r.GetObjectV2(context.Background(), bucket, key)
+}
+
+// ListCommonPrefixes restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListCommonPrefixes(bucket string, prefix string, delimiter string) ([]string, error) {
+	return r.ListCommonPrefixesV2(context.Background(), bucket, prefix, delimiter)
+}
+
+// ListObjects restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListObjects(bucket string, prefix string) ([]string, error) {
+	return r.ListObjectsV2(context.Background(), bucket, prefix)
+}
+
+// DeleteObject restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) DeleteObject(bucket string, key string) error {
+	return r.DeleteObjectV2(context.Background(), bucket, key)
+}
+
+// CreateSignedURL restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) CreateSignedURL(bucket string, key string, ttl time.Duration) (string, error) {
+	return r.CreateSignedURLV2(context.Background(), bucket, key, ttl, osv2.SignedURLOptions{Method: osv2.SignedURLMethodGet})
+}
+
+// InitV2 initializes the object store instance using config. If this is the first invocation, r stores config for
+// future reinitialization needs. InitV2 does NOT restart the shared plugin process. InitV2 may only be called once.
+func (r *restartableObjectStore) InitV2(ctx context.Context, config map[string]string) (err error) {
+	ctx, op := r.startOperation(ctx, "Init", "", "")
+	defer func() { err = r.endOperation(op, err) }()
+
+	if r.currentConfig().raw != nil {
 		return errors.Errorf("already initialized")
 	}
 
@@ -100,76 +700,907 @@ func (r *restartableObjectStore) Init(config map[string]string) error {
 		return err
 	}
 
-	r.config = config
+	parsed, err := parseConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := checkSoftDelete(delegate, parsed); err != nil {
+		return err
+	}
+
+	r.setConfig(parsed)
 
-	return r.init(delegate, config)
+	return r.init(ctx, delegate, config)
 }
 
-// init calls Init on objectStore with config. This is split out from Init() so that both Init() and reinitialize() may
-// call it using a specific ObjectStore.
-func (r *restartableObjectStore) init(objectStore velero.ObjectStore, config map[string]string) error {
-	return objectStore.Init(config)
+// UpdateConfig reinitializes the object store with config, e.g. to pick up rotated credentials, and keeps using
+// config from then on, including when the plugin's process is restarted. If the plugin can't be initialized with
+// config, the previous config keeps being used. The object store must have been initialized already.
+func (r *restartableObjectStore) UpdateConfig(ctx context.Context, config map[string]string) (err error) {
+	defer func() { err = r.wrapError("UpdateConfig", "", "", err) }()
+
+	if r.currentConfig().raw == nil {
+		return errors.Errorf("not initialized")
+	}
+
+	delegate, err := r.getDelegate(ctx)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := parseConfig(config)
+	if err != nil {
+		return err
+	}
+	if err := checkSoftDelete(delegate, parsed); err != nil {
+		return err
+	}
+
+	// the previous config is kept if the plugin rejects config, so that it's still used if the plugin's process
+	// is restarted
+	if err := r.init(ctx, delegate, config); err != nil {
+		return err
+	}
+	r.setConfig(parsed)
+	return nil
 }
 
-// PutObject restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) PutObject(bucket string, key string, body io.Reader) error {
-	delegate, err := r.getDelegate()
+// PutObjectV2 restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) PutObjectV2(ctx context.Context, bucket string, key string, body io.Reader) (err error) {
+	ctx, op := r.startOperation(ctx, "PutObject", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+	if op.span.IsRecording() {
+		counter := &spanByteCounter{Reader: emptyBodyIfNil(body)}
+		defer func() { op.span.SetAttributes(spanAttributeBytes.Int64(counter.bytes)) }()
+		body = counter
+	}
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
 	if err != nil {
 		return err
 	}
-	return delegate.PutObject(bucket, key, body)
+	defer release()
+	config := r.currentConfig()
+	if config.uploadBufferSize > 0 {
+		buffered, stop := newUploadBuffer(emptyBodyIfNil(body), config.uploadBufferSize)
+		defer stop()
+		body = buffered
+	}
+	if config.verifyChecksums {
+		err = r.putObjectWithChecksum(ctx, delegate, bucket, key, emptyBodyIfNil(body))
+	} else {
+		err = delegate.PutObjectV2(ctx, bucket, r.storedKey(key), defaultObjectStoreMetrics.countUploaded(ctx, emptyBodyIfNil(body)))
+	}
+	if err != nil || !config.waitForConsistency {
+		return err
+	}
+	return r.waitForObject(ctx, delegate, bucket, key)
 }
 
-// ObjectExists restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) ObjectExists(bucket, key string) (bool, error) {
-	delegate, err := r.getDelegate()
+// ObjectExistsV2 restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ObjectExistsV2(ctx context.Context, bucket, key string) (_ bool, err error) {
+	ctx, op := r.startOperation(ctx, "ObjectExists", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
 	if err != nil {
 		return false, err
 	}
-	return delegate.ObjectExists(bucket, key)
+	return r.dedupObjectExists(ctx, bucket, key, func(ctx context.Context) (bool, error) {
+		// only the calls that reach the plugin take a slot, not the callers waiting to share their result
+		exists, err := r.retryRead(ctx, func() (interface{}, error) {
+			return hedge(ctx, r.currentConfig().hedgeDelay, r.tryAcquireCallSlot, func(ctx context.Context) (interface{}, error) {
+				return objectExists(ctx, delegate, bucket, r.storedKey(key))
+			}, nil)
+		})
+		found, _ := exists.(bool)
+		return found, err
+	})
 }
 
-// GetObject restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) GetObject(bucket string, key string) (io.ReadCloser, error) {
-	delegate, err := r.getDelegate()
+// objectExists checks whether there is an object with the given key using GetObjectInfoV2, so that the object's
+// content is never transferred, falling back to the plugin's ObjectExistsV2 if it doesn't support GetObjectInfoV2.
+func objectExists(ctx context.Context, delegate osv2.ObjectStore, bucket, key string) (bool, error) {
+	_, err := delegate.GetObjectInfoV2(ctx, bucket, key)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, osv2.ErrObjectNotFound):
+		return false, nil
+	case !errors.Is(err, osv2.ErrUnsupported):
+		return false, err
+	}
+
+	return delegate.ObjectExistsV2(ctx, bucket, key)
+}
+
+// GetObjectV2 restarts the plugin's process if needed, then delegates the call. Objects in the trash aren't found,
+// like they aren't listed.
+func (r *restartableObjectStore) GetObjectV2(ctx context.Context, bucket string, key string) (_ io.ReadCloser, err error) {
+	ctx, op := r.startOperation(ctx, "GetObject", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	if inTrash(key) {
+		return nil, errors.Wrapf(osv2.ErrObjectNotFound, "object %s is in the trash", key)
+	}
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() {
+		if err != nil {
+			err = done(err)
+		}
+	}()
+
+	delegate, err := r.getDelegateV2(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return delegate.GetObject(bucket, key)
+	rc, err := r.dedupGetObject(ctx, bucket, key, func(ctx context.Context) (io.ReadCloser, error) {
+		// only the calls that reach the plugin take a slot, not the callers waiting to share their result
+		body, err := r.retryRead(ctx, func() (interface{}, error) {
+			return hedge(ctx, r.currentConfig().hedgeDelay, r.tryAcquireCallSlot, func(ctx context.Context) (interface{}, error) {
+				return delegate.GetObjectV2(ctx, bucket, r.storedKey(key))
+			}, closeReadCloser)
+		})
+		rc, _ := body.(io.ReadCloser)
+		return rc, err
+	})
+	if err == nil {
+		rc = emptyObjectIfNil(rc)
+		if r.currentConfig().operationTimeout > 0 {
+			// the object is read with ctx, so the operation lasts until it's closed
+			rc = &releasingReadCloser{ReadCloser: rc, release: func() { done(nil) }}
+		}
+	}
+	return defaultObjectStoreMetrics.countDownloaded(ctx, rc), err
 }
 
-// ListCommonPrefixes restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) ListCommonPrefixes(bucket string, prefix string, delimiter string) ([]string, error) {
-	delegate, err := r.getDelegate()
+// ListCommonPrefixesV2 restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListCommonPrefixesV2(ctx context.Context, bucket string, prefix string, delimiter string) (_ []string, err error) {
+	ctx, op := r.startOperation(ctx, "ListCommonPrefixes", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return delegate.ListCommonPrefixes(bucket, prefix, delimiter)
+	prefixes, err := r.retryRead(ctx, func() (interface{}, error) {
+		return delegate.ListCommonPrefixesV2(ctx, bucket, r.storedKey(prefix), delimiter)
+	})
+	listedPrefixes, _ := prefixes.([]string)
+	listed, err := r.sortListing(r.restoreKeys(listedPrefixes, err))
+	return withoutTrash(r.currentConfig().softDelete, prefix, listed, err)
 }
 
-// ListObjects restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) ListObjects(bucket string, prefix string) ([]string, error) {
-	delegate, err := r.getDelegate()
+// ListObjectsV2 restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListObjectsV2(ctx context.Context, bucket string, prefix string) (_ []string, err error) {
+	ctx, op := r.startOperation(ctx, "ListObjects", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return delegate.ListObjects(bucket, prefix)
+	keys, err := r.retryRead(ctx, func() (interface{}, error) {
+		return hedge(ctx, r.currentConfig().hedgeDelay, r.tryAcquireCallSlot, func(ctx context.Context) (interface{}, error) {
+			return delegate.ListObjectsV2(ctx, bucket, r.storedKey(prefix))
+		}, nil)
+	})
+	listedKeys, _ := keys.([]string)
+	listed, err := r.sortListing(r.restoreKeys(listedKeys, err))
+	return withoutTrash(r.currentConfig().softDelete, prefix, listed, err)
 }
 
-// DeleteObject restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) DeleteObject(bucket string, key string) error {
-	delegate, err := r.getDelegate()
+// DeleteObjectV2 restarts the plugin's process if needed, then delegates the call. If softDelete is enabled, the
+// object is moved to the trash instead, unless it's already in it.
+func (r *restartableObjectStore) DeleteObjectV2(ctx context.Context, bucket string, key string) (err error) {
+	ctx, op := r.startOperation(ctx, "DeleteObject", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
 	if err != nil {
 		return err
 	}
-	return delegate.DeleteObject(bucket, key)
+	defer release()
+	if r.currentConfig().softDelete && !inTrash(key) {
+		return r.moveToTrash(ctx, delegate, bucket, key)
+	}
+	return delegate.DeleteObjectV2(ctx, bucket, r.storedKey(key))
 }
 
-// CreateSignedURL restarts the plugin's process if needed, then delegates the call.
-func (r *restartableObjectStore) CreateSignedURL(bucket string, key string, ttl time.Duration) (string, error) {
-	delegate, err := r.getDelegate()
+// CreateSignedURLV2 restarts the plugin's process if needed, then delegates the call, passing opts through as is.
+func (r *restartableObjectStore) CreateSignedURLV2(ctx context.Context, bucket string, key string, ttl time.Duration, opts osv2.SignedURLOptions) (_ string, err error) {
+	ctx, op := r.startOperation(ctx, "CreateSignedURL", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return delegate.CreateSignedURLV2(ctx, bucket, r.storedKey(key), ttl, opts)
+}
+
+// CreateSignedURLs restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) CreateSignedURLs(ctx context.Context, bucket string, keys []string, ttl time.Duration) (_ map[string]string, err error) {
+	ctx, op := r.startOperation(ctx, "CreateSignedURLs", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	urls, err := delegate.CreateSignedURLs(ctx, bucket, r.storedKeys(keys), ttl)
+	keyRewriter := r.currentConfig().keyRewriter
+	if err != nil || keyRewriter == nil {
+		return urls, err
+	}
+	res := make(map[string]string, len(urls))
+	for storedKey, url := range urls {
+		if key, ok := keyRewriter.Restore(storedKey); ok {
+			res[key] = url
+		}
+	}
+	return res, nil
+}
+
+// ObjectsExist restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ObjectsExist(ctx context.Context, bucket string, keys []string) (_ map[string]bool, err error) {
+	ctx, op := r.startOperation(ctx, "ObjectsExist", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	exists, err := delegate.ObjectsExist(ctx, bucket, r.storedKeys(keys))
+	keyRewriter := r.currentConfig().keyRewriter
+	if err != nil || keyRewriter == nil {
+		return exists, err
+	}
+	res := make(map[string]bool, len(exists))
+	for storedKey, found := range exists {
+		if key, ok := keyRewriter.Restore(storedKey); ok {
+			res[key] = found
+		}
+	}
+	return res, nil
+}
+
+// GetReplicationStatus restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) GetReplicationStatus(ctx context.Context, bucket string, key string) (_ osv2.ReplicationStatus, err error) {
+	ctx, op := r.startOperation(ctx, "GetReplicationStatus", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, err := r.acquireCallSlot(ctx)
 	if err != nil {
 		return "", err
 	}
-	return delegate.CreateSignedURL(bucket, key, ttl)
+	defer release()
+	return delegate.GetReplicationStatus(ctx, bucket, r.storedKey(key))
+}
+
+// RestoreArchivedObject restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) RestoreArchivedObject(ctx context.Context, bucket string, key string, tier string) (err error) {
+	ctx, op := r.startOperation(ctx, "RestoreArchivedObject", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return delegate.RestoreArchivedObject(ctx, bucket, r.storedKey(key), tier)
+}
+
+// AppendObject restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) AppendObject(ctx context.Context, bucket string, key string, body io.Reader) (err error) {
+	ctx, op := r.startOperation(ctx, "AppendObject", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return delegate.AppendObject(ctx, bucket, r.storedKey(key), defaultObjectStoreMetrics.countUploaded(ctx, body))
+}
+
+// GetBucketVersioning restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) GetBucketVersioning(ctx context.Context, bucket string) (_ bool, err error) {
+	ctx, op := r.startOperation(ctx, "GetBucketVersioning", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return false, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return delegate.GetBucketVersioning(ctx, bucket)
+}
+
+// ListObjectVersions restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListObjectVersions(ctx context.Context, bucket string, prefix string) (_ []osv2.ObjectVersion, err error) {
+	ctx, op := r.startOperation(ctx, "ListObjectVersions", bucket, prefix)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	versions, err := delegate.ListObjectVersions(ctx, bucket, r.storedKey(prefix))
+	keyRewriter := r.currentConfig().keyRewriter
+	if err != nil || keyRewriter == nil {
+		return versions, err
+	}
+	res := make([]osv2.ObjectVersion, 0, len(versions))
+	for _, version := range versions {
+		if key, ok := keyRewriter.Restore(version.Key); ok {
+			version.Key = key
+			res = append(res, version)
+		}
+	}
+	return res, nil
+}
+
+// DeleteObjectVersion restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) DeleteObjectVersion(ctx context.Context, bucket string, key string, versionID string) (err error) {
+	ctx, op := r.startOperation(ctx, "DeleteObjectVersion", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return delegate.DeleteObjectVersion(ctx, bucket, r.storedKey(key), versionID)
+}
+
+// GetObjectIfModifiedSince restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) GetObjectIfModifiedSince(ctx context.Context, bucket string, key string, since time.Time) (_ io.ReadCloser, _ bool, err error) {
+	ctx, op := r.startOperation(ctx, "GetObjectIfModifiedSince", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() {
+		if err != nil {
+			err = done(err)
+		}
+	}()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+	rc, modified, err := delegate.GetObjectIfModifiedSince(ctx, bucket, r.storedKey(key), since)
+	if err != nil {
+		return rc, modified, err
+	}
+	if !modified {
+		done(nil)
+		return nil, false, nil
+	}
+	rc = emptyObjectIfNil(rc)
+	if r.currentConfig().operationTimeout > 0 {
+		// the object is read with ctx, so the operation lasts until it's closed
+		rc = &releasingReadCloser{ReadCloser: rc, release: func() { done(nil) }}
+	}
+	return defaultObjectStoreMetrics.countDownloaded(ctx, rc), true, nil
+}
+
+// ListObjectsByTag restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListObjectsByTag(ctx context.Context, bucket string, tags map[string]string) (_ []string, err error) {
+	ctx, op := r.startOperation(ctx, "ListObjectsByTag", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return r.restoreKeys(delegate.ListObjectsByTag(ctx, bucket, tags))
+}
+
+// PutObjectWithMetadata restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) PutObjectWithMetadata(ctx context.Context, bucket string, key string, body io.Reader, metadata map[string]string) (err error) {
+	ctx, op := r.startOperation(ctx, "PutObjectWithMetadata", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return delegate.PutObjectWithMetadata(ctx, bucket, r.storedKey(key), defaultObjectStoreMetrics.countUploaded(ctx, emptyBodyIfNil(body)), metadata)
+}
+
+// GetObjectChecksum restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) GetObjectChecksum(ctx context.Context, bucket string, key string) (_ string, err error) {
+	ctx, op := r.startOperation(ctx, "GetObjectChecksum", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return delegate.GetObjectChecksum(ctx, bucket, r.storedKey(key))
+}
+
+// ListObjectsInfo restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) ListObjectsInfo(ctx context.Context, bucket string, prefix string) (_ map[string]osv2.ObjectInfo, err error) {
+	ctx, op := r.startOperation(ctx, "ListObjectsInfo", bucket, prefix)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	infos, err := delegate.ListObjectsInfo(ctx, bucket, r.storedKey(prefix))
+	keyRewriter := r.currentConfig().keyRewriter
+	if err != nil || keyRewriter == nil {
+		return infos, err
+	}
+	res := make(map[string]osv2.ObjectInfo, len(infos))
+	for storedKey, info := range infos {
+		if key, ok := keyRewriter.Restore(storedKey); ok {
+			res[key] = info
+		}
+	}
+	return res, nil
+}
+
+// MoveObject restarts the plugin's process if needed, then delegates the call. If the plugin can't rename
+// objects, the object is copied to dstKey and srcKey deleted afterwards instead, which isn't atomic: the object
+// is visible under both keys in between, and stays so if deleting srcKey fails.
+func (r *restartableObjectStore) MoveObject(ctx context.Context, bucket string, srcKey string, dstKey string) (err error) {
+	ctx, op := r.startOperation(ctx, "MoveObject", bucket, srcKey)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return r.moveObject(ctx, delegate, bucket, srcKey, dstKey)
+}
+
+// moveObject renames the object with the key srcKey to dstKey with delegate, or copies it with a server-side copy
+// and deletes srcKey if the plugin can't rename objects.
+func (r *restartableObjectStore) moveObject(ctx context.Context, delegate osv2.ObjectStore, bucket, srcKey, dstKey string) error {
+	err := delegate.MoveObject(ctx, bucket, r.storedKey(srcKey), r.storedKey(dstKey))
+	if !errors.Is(err, osv2.ErrUnsupported) {
+		return err
+	}
+
+	if err := delegate.CopyObjectV2(ctx, bucket, r.storedKey(srcKey), bucket, r.storedKey(dstKey)); err != nil {
+		return errors.Wrapf(err, "error copying object %s to %s", srcKey, dstKey)
+	}
+	return errors.Wrapf(delegate.DeleteObjectV2(ctx, bucket, r.storedKey(srcKey)), "error deleting object %s after copying it to %s", srcKey, dstKey)
+}
+
+// CreateMultipartUpload restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) CreateMultipartUpload(ctx context.Context, bucket string, key string) (_ string, err error) {
+	ctx, op := r.startOperation(ctx, "CreateMultipartUpload", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return delegate.CreateMultipartUpload(ctx, bucket, r.storedKey(key))
+}
+
+// UploadPart restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) UploadPart(ctx context.Context, bucket string, key string, uploadID string, partNumber int, body io.Reader) (_ string, err error) {
+	ctx, op := r.startOperation(ctx, "UploadPart", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return delegate.UploadPart(ctx, bucket, r.storedKey(key), uploadID, partNumber, defaultObjectStoreMetrics.countUploaded(ctx, body))
+}
+
+// CompleteMultipartUpload restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) CompleteMultipartUpload(ctx context.Context, bucket string, key string, uploadID string, parts []osv2.CompletedPart) (err error) {
+	ctx, op := r.startOperation(ctx, "CompleteMultipartUpload", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return delegate.CompleteMultipartUpload(ctx, bucket, r.storedKey(key), uploadID, parts)
+}
+
+// AbortMultipartUpload restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) AbortMultipartUpload(ctx context.Context, bucket string, key string, uploadID string) (err error) {
+	ctx, op := r.startOperation(ctx, "AbortMultipartUpload", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return delegate.AbortMultipartUpload(ctx, bucket, r.storedKey(key), uploadID)
+}
+
+// DeleteObjectsV2 restarts the plugin's process if needed, then deletes the objects in a single call to the plugin.
+// If the plugin can't delete objects in bulk, or softDelete is enabled, they're deleted one at a time as
+// DeleteObjectV2 does instead. The errors for the objects which couldn't be deleted are returned.
+func (r *restartableObjectStore) DeleteObjectsV2(ctx context.Context, bucket string, keys []string) (_ []osv2.DeleteError, err error) {
+	ctx, op := r.startOperation(ctx, "DeleteObjects", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	softDelete := r.currentConfig().softDelete
+	if !softDelete {
+		deleteErrs, err := delegate.DeleteObjectsV2(ctx, bucket, r.storedKeys(keys))
+		if !errors.Is(err, osv2.ErrUnsupported) {
+			return r.restoreDeleteErrorKeys(deleteErrs), err
+		}
+	}
+
+	var deleteErrs []osv2.DeleteError
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return deleteErrs, err
+		}
+		var err error
+		if softDelete && !inTrash(key) {
+			err = r.moveToTrash(ctx, delegate, bucket, key)
+		} else {
+			err = delegate.DeleteObjectV2(ctx, bucket, r.storedKey(key))
+		}
+		if err != nil {
+			deleteErrs = append(deleteErrs, osv2.DeleteError{Key: key, Err: err})
+		}
+	}
+	return deleteErrs, nil
+}
+
+// restoreDeleteErrorKeys maps the stored keys of deleteErrs back to the keys callers use.
+func (r *restartableObjectStore) restoreDeleteErrorKeys(deleteErrs []osv2.DeleteError) []osv2.DeleteError {
+	keyRewriter := r.currentConfig().keyRewriter
+	if keyRewriter == nil {
+		return deleteErrs
+	}
+	for i := range deleteErrs {
+		if key, ok := keyRewriter.Restore(deleteErrs[i].Key); ok {
+			deleteErrs[i].Key = key
+		}
+	}
+	return deleteErrs
+}
+
+// GetObjectInfoV2 restarts the plugin's process if needed, then delegates the call.
+func (r *restartableObjectStore) GetObjectInfoV2(ctx context.Context, bucket string, key string) (_ osv2.ObjectInfo, err error) {
+	ctx, op := r.startOperation(ctx, "GetObjectInfo", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return osv2.ObjectInfo{}, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return osv2.ObjectInfo{}, err
+	}
+	defer release()
+	return delegate.GetObjectInfoV2(ctx, bucket, r.storedKey(key))
+}
+
+// GetObjectRangeV2 restarts the plugin's process if needed, then delegates the call. Objects are never retrieved
+// whole instead: if the plugin can't read ranges of objects, osv2.ErrUnsupported is returned.
+func (r *restartableObjectStore) GetObjectRangeV2(ctx context.Context, bucket string, key string, offset int64, length int64) (_ io.ReadCloser, err error) {
+	ctx, op := r.startOperation(ctx, "GetObjectRange", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() {
+		if err != nil {
+			err = done(err)
+		}
+	}()
+
+	if offset < 0 {
+		return nil, errors.Errorf("invalid range offset %d", offset)
+	}
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rc, err := delegate.GetObjectRangeV2(ctx, bucket, r.storedKey(key), offset, length)
+	if err == nil {
+		rc = emptyObjectIfNil(rc)
+		if r.currentConfig().operationTimeout > 0 {
+			rc = &releasingReadCloser{ReadCloser: rc, release: func() { done(nil) }}
+		}
+	}
+	return defaultObjectStoreMetrics.countDownloaded(ctx, rc), err
+}
+
+// CopyObjectV2 restarts the plugin's process if needed, then delegates the call. Objects are never streamed
+// through Velero instead: if the plugin can't copy them, osv2.ErrCopyNotSupported is returned.
+func (r *restartableObjectStore) CopyObjectV2(ctx context.Context, srcBucket string, srcKey string, dstBucket string, dstKey string) (err error) {
+	ctx, op := r.startOperation(ctx, "CopyObject", srcBucket, srcKey)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	err = delegate.CopyObjectV2(ctx, srcBucket, r.storedKey(srcKey), dstBucket, r.storedKey(dstKey))
+	if errors.Is(err, osv2.ErrUnsupported) && !errors.Is(err, osv2.ErrCopyNotSupported) {
+		return osv2.ErrCopyNotSupported
+	}
+	return err
+}
+
+// ListObjectsPaged restarts the plugin's process if needed, then delegates the call. If the plugin can't list
+// objects a page at a time, every key is listed up front instead. Pages aren't sorted, even if sortListings is
+// enabled.
+func (r *restartableObjectStore) ListObjectsPaged(ctx context.Context, bucket string, prefix string, pageSize int) (_ osv2.ObjectIterator, err error) {
+	ctx, op := r.startOperation(ctx, "ListObjectsPaged", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	if pageSize <= 0 {
+		return nil, errors.Errorf("invalid page size %d", pageSize)
+	}
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	restarts := r.restartCount()
+	it, err := delegate.ListObjectsPaged(ctx, bucket, r.storedKey(prefix), pageSize)
+	if errors.Is(err, osv2.ErrUnsupported) {
+		var keys []string
+		keys, err = delegate.ListObjectsV2(ctx, bucket, r.storedKey(prefix))
+		it = osv2.NewSliceObjectIterator(keys, pageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &restartableObjectIterator{r: r, delegate: it, prefix: prefix, restarts: restarts}, nil
+}
+
+// PutObjectWithChecksumV2 restarts the plugin's process if needed, then delegates the call. If the plugin can't
+// compute checksums, the checksum is computed as the body is uploaded with PutObjectV2 instead, provided the plugin
+// didn't read any of the body first or the body can be rewound.
+func (r *restartableObjectStore) PutObjectWithChecksumV2(ctx context.Context, bucket string, key string, body io.Reader, algorithm osv2.ChecksumAlgorithm) (_ string, err error) {
+	ctx, op := r.startOperation(ctx, "PutObjectWithChecksum", bucket, key)
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	if _, err := algorithm.NewHash(); err != nil {
+		return "", err
+	}
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return "", err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	body = emptyBodyIfNil(body)
+	// a body which can't be rewound is tracked instead, since uploading what's left of it would write a
+	// truncated object
+	rewind := rewinder(body)
+	var tracked *readTracker
+	pluginBody := body
+	if rewind == nil {
+		tracked = &readTracker{Reader: body}
+		pluginBody = tracked
+	}
+	checksum, err := delegate.PutObjectWithChecksumV2(ctx, bucket, r.storedKey(key), defaultObjectStoreMetrics.countUploaded(ctx, pluginBody), algorithm)
+	if !errors.Is(err, osv2.ErrUnsupported) {
+		return checksum, err
+	}
+	if tracked != nil && tracked.read {
+		return "", errors.Wrap(err, "the plugin read from the body, which can't be rewound to upload it again")
+	}
+	if rewind != nil {
+		if err := rewind(); err != nil {
+			return "", err
+		}
+	}
+	body = defaultObjectStoreMetrics.countUploaded(ctx, body)
+	return putWithChecksum(body, algorithm, func(body io.Reader) error {
+		return delegate.PutObjectV2(ctx, bucket, r.storedKey(key), body)
+	})
+}
+
+// GetStorageUsageV2 restarts the plugin's process if needed, then delegates the call. If the plugin can't compute
+// the usage, ErrUnsupported is returned rather than listing the objects. Soft-deleted objects are counted if prefix
+// is empty, as the trash is in the bucket's root.
+func (r *restartableObjectStore) GetStorageUsageV2(ctx context.Context, bucket string, prefix string) (_ osv2.StorageUsage, err error) {
+	ctx, op := r.startOperation(ctx, "GetStorageUsage", bucket, "")
+	defer func() { err = r.endOperation(op, err) }()
+	ctx, done := r.withOperationTimeout(ctx)
+	defer func() { err = done(err) }()
+
+	delegate, err := r.getDelegateV2(ctx)
+	if err != nil {
+		return osv2.StorageUsage{}, err
+	}
+	release, err := r.acquireCallSlot(ctx)
+	if err != nil {
+		return osv2.StorageUsage{}, err
+	}
+	defer release()
+	return delegate.GetStorageUsageV2(ctx, bucket, r.storedKey(prefix))
 }
@@ -17,17 +17,24 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
 	providermocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/mocks"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
 )
 
 func TestRestartableGetObjectStore(t *testing.T) {
@@ -89,56 +96,159 @@ func TestRestartableObjectStoreReinitialize(t *testing.T) {
 	r := &restartableObjectStore{
 		key:                 key,
 		sharedPluginProcess: p,
-		config: map[string]string{
-			"color": "blue",
+		config: &objectStoreConfig{
+			raw: map[string]string{
+				"color": "blue",
+			},
 		},
 	}
 
-	err := r.reinitialize(3)
+	err := r.reinitialize(context.Background(), 3)
 	assert.EqualError(t, err, "int is not a ObjectStore!")
 
 	objectStore := new(providermocks.ObjectStore)
 	objectStore.Test(t)
 	defer objectStore.AssertExpectations(t)
 
-	objectStore.On("Init", r.config).Return(errors.Errorf("init error")).Once()
-	err = r.reinitialize(objectStore)
+	objectStore.On("Init", r.config.raw).Return(errors.Errorf("init error")).Once()
+	err = r.reinitialize(context.Background(), objectStore)
 	assert.EqualError(t, err, "init error")
 
-	objectStore.On("Init", r.config).Return(nil)
-	err = r.reinitialize(objectStore)
+	objectStore.On("Init", r.config.raw).Return(nil)
+	err = r.reinitialize(context.Background(), objectStore)
 	assert.NoError(t, err)
 }
 
+func TestRestartableObjectStoreReinitializeTimeout(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	r := &restartableObjectStore{
+		key:                 kindAndName{kind: framework.PluginKindObjectStore, name: "aws"},
+		sharedPluginProcess: p,
+		config: &objectStoreConfig{
+			raw: map[string]string{
+				"color": "blue",
+			},
+		},
+	}
+
+	objectStore := new(providermocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+
+	// a plugin whose Init hangs must not block reinitialization past the caller's deadline
+	release := make(chan struct{})
+	defer close(release)
+	objectStore.On("Init", r.config.raw).Run(func(mock.Arguments) { <-release }).Return(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := r.reinitialize(ctx, objectStore)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
 func TestRestartableObjectStoreGetDelegate(t *testing.T) {
 	p := new(mockRestartableProcess)
 	p.Test(t)
 	defer p.AssertExpectations(t)
 
 	// Reset error
-	p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+	p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 	name := "aws"
 	key := kindAndName{kind: framework.PluginKindObjectStore, name: name}
 	r := &restartableObjectStore{
 		key:                 key,
 		sharedPluginProcess: p,
 	}
-	a, err := r.getDelegate()
+	a, err := r.getDelegate(context.Background())
 	assert.Nil(t, a)
 	assert.EqualError(t, err, "reset error")
 
 	// Happy path
-	p.On("resetIfNeeded").Return(nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
 	objectStore := new(providermocks.ObjectStore)
 	objectStore.Test(t)
 	defer objectStore.AssertExpectations(t)
 	p.On("getByKindAndName", key).Return(objectStore, nil)
 
-	a, err = r.getDelegate()
+	a, err = r.getDelegate(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, objectStore, a)
 }
 
+func TestRestartableObjectStoreGetDelegateRetriesRestarts(t *testing.T) {
+	notReady := &restartInProgressError{err: errors.New("plugin process not ready")}
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+
+	tests := []struct {
+		name           string
+		resetErrs      []error
+		plugin         interface{}
+		expectedResets int
+		expectedErr    string
+	}{
+		{
+			name:           "restart errors are retried until the plugin is ready",
+			resetErrs:      []error{notReady, errors.Wrap(notReady, "error restarting")},
+			plugin:         new(providermocks.ObjectStore),
+			expectedResets: 3,
+		},
+		{
+			name:           "restart errors are returned once the retries are used up",
+			resetErrs:      []error{notReady, notReady, notReady, notReady},
+			expectedResets: 4,
+			expectedErr:    "plugin process not ready",
+		},
+		{
+			name:           "other reset errors are returned right away",
+			resetErrs:      []error{errors.New("unable to restart plugin process: exceeded maximum number of reset failures")},
+			expectedResets: 1,
+			expectedErr:    "unable to restart plugin process: exceeded maximum number of reset failures",
+		},
+		{
+			name:           "plugins which aren't object stores fail right away",
+			plugin:         "not an object store",
+			expectedResets: 1,
+			expectedErr:    "string is not a ObjectStore!",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := new(mockRestartableProcess)
+			p.Test(t)
+			for _, err := range tc.resetErrs {
+				p.On("resetIfNeeded", mock.Anything).Return(err).Once()
+			}
+			if tc.expectedResets > len(tc.resetErrs) {
+				p.On("resetIfNeeded", mock.Anything).Return(nil).Once()
+				p.On("getByKindAndName", key).Return(tc.plugin, nil).Once()
+			}
+
+			r := &restartableObjectStore{
+				key:                 key,
+				sharedPluginProcess: p,
+				restartRetries:      defaultRestartRetries,
+				restartRetryBackoff: time.Millisecond,
+				logger:              test.NewLogger(),
+			}
+			objectStore, err := r.getDelegate(context.Background())
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				assert.Nil(t, objectStore)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.plugin, objectStore)
+			}
+			p.AssertNumberOfCalls(t, "resetIfNeeded", tc.expectedResets)
+			p.AssertExpectations(t)
+		})
+	}
+}
+
 func TestRestartableObjectStoreInit(t *testing.T) {
 	p := new(mockRestartableProcess)
 	p.Test(t)
@@ -157,7 +267,7 @@ func TestRestartableObjectStoreInit(t *testing.T) {
 		"color": "blue",
 	}
 	err := r.Init(config)
-	assert.EqualError(t, err, "getByKindAndName error")
+	assert.EqualError(t, err, "Init: getByKindAndName error")
 
 	// Delegate returns error
 	objectStore := new(providermocks.ObjectStore)
@@ -167,20 +277,20 @@ func TestRestartableObjectStoreInit(t *testing.T) {
 	objectStore.On("Init", config).Return(errors.Errorf("Init error")).Once()
 
 	err = r.Init(config)
-	assert.EqualError(t, err, "Init error")
+	assert.EqualError(t, err, "Init: Init error")
 
 	// wipe this out because the previous failed Init call set it
-	r.config = nil
+	r.config = newObjectStoreConfig()
 
 	// Happy path
 	objectStore.On("Init", config).Return(nil)
 	err = r.Init(config)
 	assert.NoError(t, err)
-	assert.Equal(t, config, r.config)
+	assert.Equal(t, config, r.currentConfig().raw)
 
 	// Calling Init twice is forbidden
 	err = r.Init(config)
-	assert.EqualError(t, err, "already initialized")
+	assert.EqualError(t, err, "Init: already initialized")
 }
 
 func TestRestartableObjectStoreDelegatedFunctions(t *testing.T) {
@@ -201,36 +311,957 @@ func TestRestartableObjectStoreDelegatedFunctions(t *testing.T) {
 			inputs:                  []interface{}{"bucket", "key", strings.NewReader("body")},
 			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
 			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "PutObject bucket/key: ",
 		},
 		restartableDelegateTest{
 			function:                "GetObject",
 			inputs:                  []interface{}{"bucket", "key"},
 			expectedErrorOutputs:    []interface{}{nil, errors.Errorf("reset error")},
 			expectedDelegateOutputs: []interface{}{ioutil.NopCloser(strings.NewReader("object")), errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetObject bucket/key: ",
 		},
 		restartableDelegateTest{
 			function:                "ListCommonPrefixes",
 			inputs:                  []interface{}{"bucket", "prefix", "delimiter"},
 			expectedErrorOutputs:    []interface{}{([]string)(nil), errors.Errorf("reset error")},
 			expectedDelegateOutputs: []interface{}{[]string{"a", "b"}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListCommonPrefixes bucket: ",
 		},
 		restartableDelegateTest{
 			function:                "ListObjects",
 			inputs:                  []interface{}{"bucket", "prefix"},
 			expectedErrorOutputs:    []interface{}{([]string)(nil), errors.Errorf("reset error")},
 			expectedDelegateOutputs: []interface{}{[]string{"a", "b"}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListObjects bucket: ",
 		},
 		restartableDelegateTest{
 			function:                "DeleteObject",
 			inputs:                  []interface{}{"bucket", "key"},
 			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
 			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "DeleteObject bucket/key: ",
 		},
 		restartableDelegateTest{
 			function:                "CreateSignedURL",
 			inputs:                  []interface{}{"bucket", "key", 30 * time.Minute},
 			expectedErrorOutputs:    []interface{}{"", errors.Errorf("reset error")},
 			expectedDelegateOutputs: []interface{}{"signedURL", errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "CreateSignedURL bucket/key: ",
+		},
+	)
+}
+
+func TestRestartableObjectStoreV2DelegatedFunctions(t *testing.T) {
+	ctx := context.Background()
+	runRestartableDelegateTests(
+		t,
+		framework.PluginKindObjectStore,
+		func(key kindAndName, p RestartableProcess) interface{} {
+			return &restartableObjectStore{
+				key:                 key,
+				sharedPluginProcess: p,
+			}
+		},
+		func() mockable {
+			return new(osv2mocks.ObjectStore)
+		},
+		restartableDelegateTest{
+			function:                "PutObjectV2",
+			inputs:                  []interface{}{ctx, "bucket", "key", strings.NewReader("body")},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "PutObject bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "GetObjectV2",
+			inputs:                  []interface{}{ctx, "bucket", "key"},
+			expectedErrorOutputs:    []interface{}{nil, errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{ioutil.NopCloser(strings.NewReader("object")), errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetObject bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "ListCommonPrefixesV2",
+			inputs:                  []interface{}{ctx, "bucket", "prefix", "delimiter"},
+			expectedErrorOutputs:    []interface{}{([]string)(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{[]string{"a", "b"}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListCommonPrefixes bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "ListObjectsV2",
+			inputs:                  []interface{}{ctx, "bucket", "prefix"},
+			expectedErrorOutputs:    []interface{}{([]string)(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{[]string{"a", "b"}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListObjects bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "DeleteObjectV2",
+			inputs:                  []interface{}{ctx, "bucket", "key"},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "DeleteObject bucket/key: ",
+		},
+		restartableDelegateTest{
+			function: "CreateSignedURLV2",
+			inputs: []interface{}{ctx, "bucket", "key", 30 * time.Minute, osv2.SignedURLOptions{
+				Method:          osv2.SignedURLMethodPut,
+				ResponseHeaders: map[string]string{"Cache-Control": "no-store"},
+			}},
+			expectedErrorOutputs:    []interface{}{"", errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{"signedURL", errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "CreateSignedURL bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "CreateSignedURLs",
+			inputs:                  []interface{}{ctx, "bucket", []string{"key1", "key2"}, 30 * time.Minute},
+			expectedErrorOutputs:    []interface{}{map[string]string(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{map[string]string{"key1": "url1"}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "CreateSignedURLs bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "ObjectsExist",
+			inputs:                  []interface{}{ctx, "bucket", []string{"key1", "key2"}},
+			expectedErrorOutputs:    []interface{}{map[string]bool(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{map[string]bool{"key1": true}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ObjectsExist bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "GetReplicationStatus",
+			inputs:                  []interface{}{ctx, "bucket", "key"},
+			expectedErrorOutputs:    []interface{}{osv2.ReplicationStatus(""), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{osv2.ReplicationStatusPending, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetReplicationStatus bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "RestoreArchivedObject",
+			inputs:                  []interface{}{ctx, "bucket", "key", "Bulk"},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "RestoreArchivedObject bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "AppendObject",
+			inputs:                  []interface{}{ctx, "bucket", "key", strings.NewReader("more")},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "AppendObject bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "GetBucketVersioning",
+			inputs:                  []interface{}{ctx, "bucket"},
+			expectedErrorOutputs:    []interface{}{false, errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{true, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetBucketVersioning bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "ListObjectVersions",
+			inputs:                  []interface{}{ctx, "bucket", "prefix"},
+			expectedErrorOutputs:    []interface{}{[]osv2.ObjectVersion(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{[]osv2.ObjectVersion{{Key: "key", VersionID: "v1"}}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListObjectVersions bucket/prefix: ",
+		},
+		restartableDelegateTest{
+			function:                "DeleteObjectVersion",
+			inputs:                  []interface{}{ctx, "bucket", "key", "v1"},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "DeleteObjectVersion bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "GetObjectIfModifiedSince",
+			inputs:                  []interface{}{ctx, "bucket", "key", time.Unix(0, 0)},
+			expectedErrorOutputs:    []interface{}{nil, false, errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{ioutil.NopCloser(strings.NewReader("object")), true, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetObjectIfModifiedSince bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "ListObjectsByTag",
+			inputs:                  []interface{}{ctx, "bucket", map[string]string{"retention-class": "expired"}},
+			expectedErrorOutputs:    []interface{}{([]string)(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{[]string{"a", "b"}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListObjectsByTag bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "PutObjectWithMetadata",
+			inputs:                  []interface{}{ctx, "bucket", "key", strings.NewReader("body"), map[string]string{osv2.ChecksumMetadataKey: "digest"}},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "PutObjectWithMetadata bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "GetObjectChecksum",
+			inputs:                  []interface{}{ctx, "bucket", "key"},
+			expectedErrorOutputs:    []interface{}{"", errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{"digest", errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetObjectChecksum bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "ListObjectsInfo",
+			inputs:                  []interface{}{ctx, "bucket", "backups/"},
+			expectedErrorOutputs:    []interface{}{(map[string]osv2.ObjectInfo)(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{map[string]osv2.ObjectInfo{"backups/b1/velero-backup.json": {Size: 10}}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "ListObjectsInfo bucket/backups/: ",
+		},
+		restartableDelegateTest{
+			function:                "MoveObject",
+			inputs:                  []interface{}{ctx, "bucket", "staging/b1/b1.tar.gz", "backups/b1/b1.tar.gz"},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "MoveObject bucket/staging/b1/b1.tar.gz: ",
+		},
+		restartableDelegateTest{
+			function:                "CreateMultipartUpload",
+			inputs:                  []interface{}{ctx, "bucket", "key"},
+			expectedErrorOutputs:    []interface{}{"", errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{"upload-1", errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "CreateMultipartUpload bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "UploadPart",
+			inputs:                  []interface{}{ctx, "bucket", "key", "upload-1", 1, strings.NewReader("part")},
+			expectedErrorOutputs:    []interface{}{"", errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{"etag-1", errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "UploadPart bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "CompleteMultipartUpload",
+			inputs:                  []interface{}{ctx, "bucket", "key", "upload-1", []osv2.CompletedPart{{PartNumber: 1, ETag: "etag-1"}}},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "CompleteMultipartUpload bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "AbortMultipartUpload",
+			inputs:                  []interface{}{ctx, "bucket", "key", "upload-1"},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "AbortMultipartUpload bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "DeleteObjectsV2",
+			inputs:                  []interface{}{ctx, "bucket", []string{"key1", "key2"}},
+			expectedErrorOutputs:    []interface{}{[]osv2.DeleteError(nil), errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{[]osv2.DeleteError{{Key: "key2", Err: errors.Errorf("access denied")}}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "DeleteObjects bucket: ",
+		},
+		restartableDelegateTest{
+			function:                "GetObjectInfoV2",
+			inputs:                  []interface{}{ctx, "bucket", "key"},
+			expectedErrorOutputs:    []interface{}{osv2.ObjectInfo{}, errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{osv2.ObjectInfo{Size: 1024, LastModified: time.Unix(1600000000, 0), ETag: `"9b2cf535f27731c974343645a3985328"`}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetObjectInfo bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "GetObjectRangeV2",
+			inputs:                  []interface{}{ctx, "bucket", "key", int64(512), int64(512)},
+			expectedErrorOutputs:    []interface{}{nil, errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{ioutil.NopCloser(strings.NewReader("header")), errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetObjectRange bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "CopyObjectV2",
+			inputs:                  []interface{}{ctx, "src-bucket", "backups/b1/b1.tar.gz", "dst-bucket", "backups/b1/b1.tar.gz"},
+			expectedErrorOutputs:    []interface{}{errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "CopyObject src-bucket/backups/b1/b1.tar.gz: ",
+		},
+		restartableDelegateTest{
+			function:                "PutObjectWithChecksumV2",
+			inputs:                  []interface{}{ctx, "bucket", "key", strings.NewReader("body"), osv2.ChecksumSHA256},
+			expectedErrorOutputs:    []interface{}{"", errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{"checksum", errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "PutObjectWithChecksum bucket/key: ",
+		},
+		restartableDelegateTest{
+			function:                "GetStorageUsageV2",
+			inputs:                  []interface{}{ctx, "bucket", "backups/b1/"},
+			expectedErrorOutputs:    []interface{}{osv2.StorageUsage{}, errors.Errorf("reset error")},
+			expectedDelegateOutputs: []interface{}{osv2.StorageUsage{ObjectCount: 12, TotalBytes: 4096}, errors.Errorf("delegate error")},
+			expectedErrorPrefix:     "GetStorageUsage bucket: ",
 		},
 	)
 }
+
+func TestRestartableObjectStoreV2AdaptsV1Delegate(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+
+	objectStore := new(providermocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
+	p.On("getByKindAndName", key).Return(objectStore, nil)
+	objectStore.On("ListObjects", "bucket", "prefix").Return([]string{"a"}, nil)
+
+	keys, err := r.ListObjectsV2(context.Background(), "bucket", "prefix")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	_, err = r.GetReplicationStatus(context.Background(), "bucket", "key")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+}
+
+func TestRestartableObjectStoreGetStorageUsageUnsupported(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+
+	// a v1 plugin can't compute the usage, and the objects mustn't be listed to compute it instead
+	objectStore := new(providermocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
+	p.On("getByKindAndName", key).Return(objectStore, nil)
+
+	usage, err := r.GetStorageUsageV2(context.Background(), "bucket", "backups/b1/")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+	assert.Equal(t, osv2.StorageUsage{}, usage)
+	objectStore.AssertNotCalled(t, "ListObjects", mock.Anything, mock.Anything)
+}
+
+func TestRestartableObjectStoreV1OnlyPlugin(t *testing.T) {
+	ctx := context.Background()
+
+	// the fake object store only implements the v1 API, so the v2 methods fall back to v1 semantics
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	config := map[string]string{"region": "us-east-1", sortListingsConfigKey: "true"}
+	require.NoError(t, r.InitV2(ctx, config))
+	assert.Equal(t, map[string]string{"region": "us-east-1"}, objectStore.Config)
+
+	require.NoError(t, r.PutObjectV2(ctx, "bucket", "backups/b1/velero-backup.json", strings.NewReader("backup")))
+
+	exists, err := r.ObjectsExist(ctx, "bucket", []string{"backups/b1/velero-backup.json", "backups/b2/velero-backup.json"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"backups/b1/velero-backup.json": true, "backups/b2/velero-backup.json": false}, exists)
+
+	rc, modified, err := r.GetObjectIfModifiedSince(ctx, "bucket", "backups/b1/velero-backup.json", time.Now())
+	require.NoError(t, err)
+	assert.True(t, modified)
+	data, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(data))
+
+	// capabilities without a v1 equivalent fail clearly
+	_, err = r.GetObjectInfoV2(ctx, "bucket", "backups/b1/velero-backup.json")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+	// a restart reinitializes the plugin through the v1 API as well
+	objectStore.Config = nil
+	require.NoError(t, p.reset(ctx))
+	assert.Equal(t, map[string]string{"region": "us-east-1"}, objectStore.Config)
+
+	// a v2 plugin advertising only v1 support is only called through the v1 API
+	objectStoreV2 := new(osv2mocks.ObjectStore)
+	objectStoreV2.Test(t)
+	defer objectStoreV2.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "v1-only", test.V1Only(objectStoreV2))
+	r = newRestartableObjectStore("v1-only", p, test.NewLogger())
+
+	objectStoreV2.On("Init", map[string]string{}).Return(nil)
+	require.NoError(t, r.InitV2(ctx, map[string]string{}))
+
+	objectStoreV2.On("ListObjects", "bucket", "prefix").Return([]string{"a"}, nil)
+	keys, err := r.ListObjectsV2(ctx, "bucket", "prefix")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	_, err = r.GetBucketVersioning(ctx, "bucket")
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+}
+
+func TestRestartableObjectStoreZeroByteObjects(t *testing.T) {
+	ctx := context.Background()
+
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	readAll := func(rc io.ReadCloser, err error) string {
+		require.NoError(t, err)
+		require.NotNil(t, rc)
+		defer rc.Close()
+		data, err := ioutil.ReadAll(rc)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	// both an empty and a nil body create a zero-byte object
+	require.NoError(t, r.PutObject("bucket", "dir/empty", strings.NewReader("")))
+	require.NoError(t, r.PutObject("bucket", "dir/nil", nil))
+
+	for _, key := range []string{"dir/empty", "dir/nil"} {
+		exists, err := r.ObjectExists("bucket", key)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		assert.Equal(t, "", readAll(r.GetObject("bucket", key)))
+
+		rc, modified, err := r.GetObjectIfModifiedSince(ctx, "bucket", key, time.Now())
+		assert.True(t, modified)
+		assert.Equal(t, "", readAll(rc, err))
+
+		_, err = r.CreateSignedURL("bucket", key, time.Minute)
+		assert.NoError(t, err)
+	}
+
+	exists, err := r.ObjectsExist(ctx, "bucket", []string{"dir/empty", "dir/nil"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"dir/empty": true, "dir/nil": true}, exists)
+
+	keys, err := r.ListObjects("bucket", "dir/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/empty", "dir/nil"}, keys)
+
+	prefixes, err := r.ListCommonPrefixes("bucket", "", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dir/"}, prefixes)
+
+	require.NoError(t, r.DeleteObject("bucket", "dir/empty"))
+	exists, err = r.ObjectsExist(ctx, "bucket", []string{"dir/empty", "dir/nil"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"dir/empty": false, "dir/nil": true}, exists)
+
+	// a plugin returning a nil reader for a zero-byte object still gives the caller a valid reader
+	objectStoreV2 := new(osv2mocks.ObjectStore)
+	objectStoreV2.Test(t)
+	defer objectStoreV2.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "nil-reader", objectStoreV2)
+	r = newRestartableObjectStore("nil-reader", p, test.NewLogger())
+
+	objectStoreV2.On("GetObjectV2", mock.Anything, "bucket", "empty").Return(nil, nil)
+	assert.Equal(t, "", readAll(r.GetObject("bucket", "empty")))
+
+	objectStoreV2.On("GetObjectIfModifiedSince", mock.Anything, "bucket", "empty", mock.Anything).Return(nil, true, nil)
+	assert.Equal(t, "", readAll(func() (io.ReadCloser, error) {
+		rc, _, err := r.GetObjectIfModifiedSince(ctx, "bucket", "empty", time.Now())
+		return rc, err
+	}()))
+}
+
+func TestRestartableObjectStoreObjectExistsUsesGetObjectInfo(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "found").Return(osv2.ObjectInfo{Size: 10}, nil)
+	exists, err := r.ObjectExists("bucket", "found")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "missing").Return(osv2.ObjectInfo{}, osv2.ErrObjectNotFound)
+	exists, err = r.ObjectExists("bucket", "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "forbidden").Return(osv2.ObjectInfo{}, errors.New("access denied"))
+	_, err = r.ObjectExists("bucket", "forbidden")
+	assert.EqualError(t, err, "ObjectExists bucket/forbidden: access denied")
+
+	// plugins without GetObjectInfoV2 fall back to asking the plugin whether the object exists
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", mock.Anything).Return(osv2.ObjectInfo{}, osv2.ErrUnsupported)
+	objectStore.On("ObjectExistsV2", mock.Anything, "bucket", "unstattable").Return(true, nil)
+	exists, err = r.ObjectExists("bucket", "unstattable")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	objectStore.On("ObjectExistsV2", mock.Anything, "bucket", "unstattable-missing").Return(false, nil)
+	exists, err = r.ObjectExists("bucket", "unstattable-missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// lazyGetObjectStore is a v1 ObjectStore whose GetObject always succeeds, and only fails when the object is
+// read, like the gRPC client of v1 plugins does for missing objects.
+type lazyGetObjectStore struct {
+	*test.FakeObjectStore
+}
+
+func (s *lazyGetObjectStore) GetObject(bucket, key string) (io.ReadCloser, error) {
+	body, err := s.FakeObjectStore.GetObject(bucket, key)
+	if err != nil {
+		return ioutil.NopCloser(iotest.ErrReader(err)), nil
+	}
+	return body, nil
+}
+
+func TestRestartableObjectStoreObjectExistsV1Plugin(t *testing.T) {
+	objectStore := &lazyGetObjectStore{FakeObjectStore: test.NewFakeObjectStore("bucket")}
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "v1-only", test.V1Only(objectStore))
+	r := newRestartableObjectStore("v1-only", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	require.NoError(t, r.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup")))
+
+	exists, err := r.ObjectExists("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = r.ObjectExists("bucket", "backups/b2/b2.tar.gz")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.Zero(t, objectStore.BytesRead())
+}
+
+func TestRestartableObjectStoreObjectExistsReadsNoContent(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	require.NoError(t, r.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader(strings.Repeat("x", 1024))))
+
+	exists, err := r.ObjectExists("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = r.ObjectExists("bucket", "backups/b2/b2.tar.gz")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.Zero(t, objectStore.BytesRead())
+}
+
+// copyingObjectStore is a v2 object store backed by a FakeObjectStore, which copies objects within it with
+// CopyObjectV2 like a plugin with server-side copies would.
+type copyingObjectStore struct {
+	*adaptedV1ObjectStore
+	fake *test.FakeObjectStore
+}
+
+func newCopyingObjectStore(buckets ...string) *copyingObjectStore {
+	fake := test.NewFakeObjectStore(buckets...)
+	return &copyingObjectStore{adaptedV1ObjectStore: newAdaptedV1ObjectStore(fake, nil), fake: fake}
+}
+
+func (c *copyingObjectStore) CopyObjectV2(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	body, err := c.fake.GetObject(srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return c.fake.PutObject(dstBucket, dstKey, body)
+}
+
+func TestRestartableObjectStoreMoveObjectFallsBackToCopy(t *testing.T) {
+	ctx := context.Background()
+	objectStore := newCopyingObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	require.NoError(t, r.PutObject("bucket", "staging/b1/b1.tar.gz", strings.NewReader("backup")))
+	require.NoError(t, r.MoveObject(ctx, "bucket", "staging/b1/b1.tar.gz", "backups/b1/b1.tar.gz"))
+
+	exists, err := r.ObjectExists("bucket", "staging/b1/b1.tar.gz")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	rc, err := r.GetObject("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	defer rc.Close()
+	contents, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "backup", string(contents))
+
+	err = r.MoveObject(ctx, "bucket", "staging/b2/b2.tar.gz", "backups/b2/b2.tar.gz")
+	assert.Error(t, err)
+
+	// objects aren't streamed through Velero for plugins that can't copy them
+	p = newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "v1", test.NewFakeObjectStore("bucket"))
+	r = newRestartableObjectStore("v1", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+	require.NoError(t, r.PutObject("bucket", "staging/b1/b1.tar.gz", strings.NewReader("backup")))
+
+	err = r.MoveObject(ctx, "bucket", "staging/b1/b1.tar.gz", "backups/b1/b1.tar.gz")
+	assert.True(t, errors.Is(err, osv2.ErrCopyNotSupported))
+	exists, err = r.ObjectExists("bucket", "staging/b1/b1.tar.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestRestartableObjectStoreGetPrefixSizeFallsBackToListing(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{keyRewriterConfigKey: "prefix", keyPrefixConfigKey: "cluster-a/"}))
+
+	objectStore.On("GetStorageUsageV2", mock.Anything, "bucket", "cluster-a/backups/").Return(osv2.StorageUsage{}, osv2.ErrUnsupported)
+	objectStore.On("ListObjectsInfo", mock.Anything, "bucket", "cluster-a/backups/").Return(map[string]osv2.ObjectInfo{
+		"cluster-a/backups/b1/b1.tar.gz": {Size: 100},
+		"cluster-a/backups/b2/b2.tar.gz": {Size: 250},
+	}, nil)
+
+	totalBytes, objectCount, err := osv2.GetPrefixSize(context.Background(), r, "bucket", "backups/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(350), totalBytes)
+	assert.Equal(t, int64(2), objectCount)
+}
+
+func TestRestartableObjectStoreDeleteObjects(t *testing.T) {
+	keys := []string{"backups/b1/b1.tar.gz", "backups/b1/b1-logs.gz", "backups/b1/velero-backup.json"}
+	accessDenied := errors.New("access denied")
+
+	tests := []struct {
+		name     string
+		setup    func(objectStore *osv2mocks.ObjectStore)
+		expected []osv2.DeleteError
+	}{
+		{
+			name: "objects are deleted in bulk and the keys of per-object errors are restored",
+			setup: func(objectStore *osv2mocks.ObjectStore) {
+				objectStore.On("DeleteObjectsV2", mock.Anything, "bucket", []string{
+					"cluster-a/backups/b1/b1.tar.gz", "cluster-a/backups/b1/b1-logs.gz", "cluster-a/backups/b1/velero-backup.json",
+				}).Return([]osv2.DeleteError{{Key: "cluster-a/backups/b1/b1-logs.gz", Err: accessDenied}}, nil)
+			},
+			expected: []osv2.DeleteError{{Key: "backups/b1/b1-logs.gz", Err: accessDenied}},
+		},
+		{
+			name: "objects are deleted one at a time if the plugin can't delete them in bulk",
+			setup: func(objectStore *osv2mocks.ObjectStore) {
+				objectStore.On("DeleteObjectsV2", mock.Anything, "bucket", mock.Anything).Return(nil, osv2.ErrUnsupported)
+				objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "cluster-a/backups/b1/b1.tar.gz").Return(nil)
+				objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "cluster-a/backups/b1/b1-logs.gz").Return(accessDenied)
+				objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "cluster-a/backups/b1/velero-backup.json").Return(nil)
+			},
+			expected: []osv2.DeleteError{{Key: "backups/b1/b1-logs.gz", Err: accessDenied}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newFakeRestartableProcess()
+			objectStore := new(osv2mocks.ObjectStore)
+			objectStore.Test(t)
+			p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+			r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+			objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+			require.NoError(t, r.Init(map[string]string{keyRewriterConfigKey: "prefix", keyPrefixConfigKey: "cluster-a/"}))
+			tc.setup(objectStore)
+
+			deleteErrs, err := r.DeleteObjectsV2(context.Background(), "bucket", keys)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, deleteErrs)
+			objectStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRestartableObjectStoreGetObjectRangeUnsupported(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	// the object isn't retrieved whole instead, callers decide whether that's worth it
+	objectStore.On("GetObjectRangeV2", mock.Anything, "bucket", "backups/b1/b1.tar.gz", int64(7), int64(3)).Return(nil, osv2.ErrUnsupported)
+	_, err := r.GetObjectRangeV2(context.Background(), "bucket", "backups/b1/b1.tar.gz", 7, 3)
+	assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+}
+
+func TestRestartableObjectStoreCopyObjectV2(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("objects are copied by the plugin", func(t *testing.T) {
+		p := newFakeRestartableProcess()
+		objectStore := new(osv2mocks.ObjectStore)
+		objectStore.Test(t)
+		defer objectStore.AssertExpectations(t)
+		p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+		r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+		objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+		require.NoError(t, r.Init(map[string]string{keyRewriterConfigKey: "prefix", keyPrefixConfigKey: "cluster-a/"}))
+
+		objectStore.On("CopyObjectV2", mock.Anything, "src-bucket", "cluster-a/backups/b1/b1.tar.gz", "dst-bucket", "cluster-a/backups/b1/b1.tar.gz").Return(nil).Once()
+		assert.NoError(t, r.CopyObjectV2(ctx, "src-bucket", "backups/b1/b1.tar.gz", "dst-bucket", "backups/b1/b1.tar.gz"))
+
+		objectStore.On("CopyObjectV2", mock.Anything, "src-bucket", "cluster-a/backups/b2/b2.tar.gz", "dst-bucket", "cluster-a/backups/b2/b2.tar.gz").Return(osv2.ErrUnsupported).Once()
+		err := r.CopyObjectV2(ctx, "src-bucket", "backups/b2/b2.tar.gz", "dst-bucket", "backups/b2/b2.tar.gz")
+		assert.True(t, errors.Is(err, osv2.ErrCopyNotSupported))
+	})
+
+	t.Run("objects aren't streamed for plugins that can't copy them", func(t *testing.T) {
+		objectStore := test.NewFakeObjectStore("src-bucket", "dst-bucket")
+		p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+		r := newRestartableObjectStore("fake", p, test.NewLogger())
+		require.NoError(t, r.Init(map[string]string{}))
+		require.NoError(t, r.PutObject("src-bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup")))
+
+		err := r.CopyObjectV2(ctx, "src-bucket", "backups/b1/b1.tar.gz", "dst-bucket", "backups/b1/b1.tar.gz")
+		assert.True(t, errors.Is(err, osv2.ErrCopyNotSupported))
+		assert.True(t, errors.Is(err, osv2.ErrUnsupported))
+
+		exists, err := r.ObjectExists("dst-bucket", "backups/b1/b1.tar.gz")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestRestartableObjectStoreUpdateConfig(t *testing.T) {
+	ctx := context.Background()
+
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	assert.EqualError(t, r.UpdateConfig(ctx, map[string]string{"credentialsFile": "/credentials/cloud"}), "UpdateConfig: not initialized")
+
+	require.NoError(t, r.Init(map[string]string{"credentialsFile": "/credentials/cloud", sortListingsConfigKey: "false"}))
+	require.NoError(t, r.UpdateConfig(ctx, map[string]string{"credentialsFile": "/credentials/rotated", sortListingsConfigKey: "true"}))
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/rotated"}, objectStore.Config)
+	assert.True(t, r.currentConfig().sortListings)
+
+	// the updated config is used when the plugin process restarts
+	objectStore.Config = nil
+	require.NoError(t, p.reset(ctx))
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/rotated"}, objectStore.Config)
+
+	assert.EqualError(t, r.UpdateConfig(ctx, map[string]string{sortListingsConfigKey: "maybe"}),
+		`UpdateConfig: invalid value for config key "sortListings": strconv.ParseBool: parsing "maybe": invalid syntax`)
+}
+
+func TestRestartableObjectStoreUpdateConfigInitFailure(t *testing.T) {
+	ctx := context.Background()
+
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{"credentialsFile": "/credentials/cloud"}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{"credentialsFile": "/credentials/cloud"}))
+
+	// the plugin rejects the rotated credentials, so the previous config is kept
+	objectStore.On("InitV2", mock.Anything, map[string]string{"credentialsFile": "/credentials/bad"}).Return(errors.New("bad credentials")).Once()
+	assert.EqualError(t, r.UpdateConfig(ctx, map[string]string{"credentialsFile": "/credentials/bad", sortListingsConfigKey: "true"}),
+		"UpdateConfig: bad credentials")
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/cloud"}, r.currentConfig().raw)
+	assert.False(t, r.currentConfig().sortListings)
+
+	// and used when the plugin process restarts
+	require.NoError(t, p.reset(ctx))
+	objectStore.AssertNumberOfCalls(t, "InitV2", 3)
+}
+
+func TestRestartableObjectStoreConfigProvider(t *testing.T) {
+	ctx := context.Background()
+
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	current := map[string]string{"credentialsFile": "/credentials/cloud"}
+	var providerErr error
+	r.SetConfigProvider(func(context.Context) (map[string]string, error) {
+		return current, providerErr
+	})
+	require.NoError(t, r.Init(map[string]string{"credentialsFile": "/credentials/cloud"}))
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/cloud"}, objectStore.Config)
+
+	// the credentials are rotated, and the plugin picks them up when its process restarts
+	current = map[string]string{"credentialsFile": "/credentials/rotated", sortListingsConfigKey: "true"}
+	require.NoError(t, p.reset(ctx))
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/rotated"}, objectStore.Config)
+	assert.True(t, r.currentConfig().sortListings)
+
+	// the previous config is used if fresh config can't be had
+	providerErr = errors.New("secret not found")
+	current = nil
+	objectStore.Config = nil
+	require.NoError(t, p.reset(ctx))
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/rotated"}, objectStore.Config)
+
+	// without a provider, the config the plugin was last initialized with is used
+	r.SetConfigProvider(nil)
+	objectStore.Config = nil
+	require.NoError(t, p.reset(ctx))
+	assert.Equal(t, map[string]string{"credentialsFile": "/credentials/rotated"}, objectStore.Config)
+}
+
+func TestRestartableObjectStoreSigningRegion(t *testing.T) {
+	ctx := context.Background()
+
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	config := map[string]string{"region": "minio", signingRegionConfigKey: "us-east-1"}
+	require.NoError(t, r.Init(config))
+	assert.Equal(t, config, objectStore.Config)
+
+	// the signing region is passed on again when the plugin process restarts
+	objectStore.Config = nil
+	require.NoError(t, p.reset(ctx))
+	assert.Equal(t, config, objectStore.Config)
+
+	assert.EqualError(t, r.UpdateConfig(ctx, map[string]string{"region": "minio", signingRegionConfigKey: ""}),
+		`UpdateConfig: invalid value for config key "signingRegion": ""`)
+}
+
+func TestRestartableObjectStoreSortListings(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           map[string]string
+		expectedKeys     []string
+		expectedPrefixes []string
+		expectedError    string
+	}{
+		{
+			name:             "listings are returned as-is by default",
+			config:           map[string]string{"bucket": "bucket"},
+			expectedKeys:     []string{"c", "a", "b"},
+			expectedPrefixes: []string{"y/", "x/"},
+		},
+		{
+			name:             "listings are sorted when sortListings is true",
+			config:           map[string]string{"bucket": "bucket", sortListingsConfigKey: "true"},
+			expectedKeys:     []string{"a", "b", "c"},
+			expectedPrefixes: []string{"x/", "y/"},
+		},
+		{
+			name:          "invalid sortListings value",
+			config:        map[string]string{"bucket": "bucket", sortListingsConfigKey: "maybe"},
+			expectedError: `Init: invalid value for config key "sortListings": strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := new(mockRestartableProcess)
+			p.Test(t)
+			defer p.AssertExpectations(t)
+
+			key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+			r := &restartableObjectStore{
+				key:                 key,
+				sharedPluginProcess: p,
+			}
+
+			objectStore := new(providermocks.ObjectStore)
+			objectStore.Test(t)
+			defer objectStore.AssertExpectations(t)
+			p.On("getByKindAndName", key).Return(objectStore, nil)
+
+			if tc.expectedError != "" {
+				assert.EqualError(t, r.Init(tc.config), tc.expectedError)
+				return
+			}
+
+			// the sortListings key is handled by the restartableObjectStore and never reaches the plugin
+			objectStore.On("Init", map[string]string{"bucket": "bucket"}).Return(nil)
+			require.NoError(t, r.Init(tc.config))
+
+			p.On("resetIfNeeded", mock.Anything).Return(nil)
+			objectStore.On("ListObjects", "bucket", "").Return([]string{"c", "a", "b"}, nil)
+			objectStore.On("ListCommonPrefixes", "bucket", "", "/").Return([]string{"y/", "x/"}, nil)
+
+			keys, err := r.ListObjects("bucket", "")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedKeys, keys)
+
+			prefixes, err := r.ListCommonPrefixesV2(context.Background(), "bucket", "", "/")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPrefixes, prefixes)
+		})
+	}
+}
+
+func TestRestartableObjectStoreHedgedReads(t *testing.T) {
+	p := new(mockRestartableProcess)
+	p.Test(t)
+	defer p.AssertExpectations(t)
+
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "aws"}
+	r := &restartableObjectStore{
+		key:                 key,
+		sharedPluginProcess: p,
+	}
+
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.On("getByKindAndName", key).Return(objectStore, nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{hedgeDelayConfigKey: "10ms"}))
+	assert.Equal(t, 10*time.Millisecond, r.currentConfig().hedgeDelay)
+
+	// the first attempt hangs until it's cancelled, the hedged one succeeds
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "key").Run(func(args mock.Arguments) {
+		<-args.Get(0).(context.Context).Done()
+	}).Return(osv2.ObjectInfo{}, context.Canceled).Once()
+	objectStore.On("GetObjectInfoV2", mock.Anything, "bucket", "key").Return(osv2.ObjectInfo{Size: 10}, nil).Once()
+
+	exists, err := r.ObjectExists("bucket", "key")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestRestartableObjectStoreCreateSignedURLOptions(t *testing.T) {
+	p := newFakeRestartableProcess()
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p.dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	// the v1 method creates GET URLs without overrides
+	objectStore.On("CreateSignedURLV2", mock.Anything, "bucket", "key", time.Minute, osv2.SignedURLOptions{Method: osv2.SignedURLMethodGet}).Return("get-url", nil).Once()
+	url, err := r.CreateSignedURL("bucket", "key", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "get-url", url)
+
+	opts := osv2.SignedURLOptions{
+		Method:                     osv2.SignedURLMethodGet,
+		ResponseContentDisposition: `attachment; filename="backup.tar.gz"`,
+		ResponseHeaders:            map[string]string{"Cache-Control": "no-store"},
+	}
+	objectStore.On("CreateSignedURLV2", mock.Anything, "bucket", "key", time.Minute, opts).Return("download-url", nil).Once()
+	url, err = r.CreateSignedURLV2(context.Background(), "bucket", "key", time.Minute, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "download-url", url)
+
+	_, err = r.CreateSignedURLV2(context.Background(), "bucket", "key", time.Minute, osv2.SignedURLOptions{Method: "DELETE"})
+	assert.EqualError(t, err, `CreateSignedURL bucket/key: unsupported signed URL method "DELETE"`)
+}
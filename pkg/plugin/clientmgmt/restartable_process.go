@@ -17,7 +17,9 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -38,10 +40,13 @@ func (rpf *restartableProcessFactory) newRestartableProcess(command string, logg
 	return newRestartableProcess(command, logger, logLevel)
 }
 
+// reinitializeTimeout is how long the plugins of a restarted process have to reinitialize.
+const reinitializeTimeout = time.Minute
+
 type RestartableProcess interface {
 	addReinitializer(key kindAndName, r reinitializer)
-	reset() error
-	resetIfNeeded() error
+	reset(ctx context.Context) error
+	resetIfNeeded(ctx context.Context) error
 	getByKindAndName(key kindAndName) (interface{}, error)
 	stop()
 }
@@ -50,9 +55,10 @@ type RestartableProcess interface {
 // to restart a plugin process if it is terminated for any reason. If this happens, all plugins are reinitialized using
 // the original configuration data.
 type restartableProcess struct {
-	command  string
-	logger   logrus.FieldLogger
-	logLevel logrus.Level
+	command        string
+	logger         logrus.FieldLogger
+	logLevel       logrus.Level
+	processFactory ProcessFactory
 
 	// lock guards all of the fields below
 	lock           sync.RWMutex
@@ -64,8 +70,10 @@ type restartableProcess struct {
 
 // reinitializer is capable of reinitializing a restartable plugin instance using the newly dispensed plugin.
 type reinitializer interface {
-	// reinitialize reinitializes a restartable plugin instance using the newly dispensed plugin.
-	reinitialize(dispensed interface{}) error
+	// reinitialize reinitializes a restartable plugin instance using the newly dispensed plugin. ctx has the
+	// values of the call which triggered the restart, but not its cancellation, as the restart is shared by every
+	// plugin of the process; it's cancelled if reinitializing takes longer than reinitializeTimeout.
+	reinitialize(ctx context.Context, dispensed interface{}) error
 }
 
 // newRestartableProcess creates a new restartableProcess for the given command and options.
@@ -74,12 +82,13 @@ func newRestartableProcess(command string, logger logrus.FieldLogger, logLevel l
 		command:        command,
 		logger:         logger,
 		logLevel:       logLevel,
+		processFactory: newProcessFactory(),
 		plugins:        make(map[kindAndName]interface{}),
 		reinitializers: make(map[kindAndName]reinitializer),
 	}
 
 	// This launches the process
-	err := p.reset()
+	err := p.reset(context.Background())
 
 	return p, err
 }
@@ -93,29 +102,35 @@ func (p *restartableProcess) addReinitializer(key kindAndName, r reinitializer)
 }
 
 // reset acquires the lock and calls resetLH.
-func (p *restartableProcess) reset() error {
+func (p *restartableProcess) reset(ctx context.Context) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	return p.resetLH()
+	return p.resetLH(ctx)
 }
 
 // resetLH (re)launches the plugin process. It redispenses all previously dispensed plugins and reinitializes all the
 // registered reinitializers using the newly dispensed plugins.
 //
 // Callers of resetLH *must* acquire the lock before calling it.
-func (p *restartableProcess) resetLH() error {
+func (p *restartableProcess) resetLH(ctx context.Context) error {
 	if p.resetFailures > 10 {
 		return errors.Errorf("unable to restart plugin process: exceeded maximum number of reset failures")
 	}
 
-	process, err := newProcess(p.command, p.logger, p.logLevel)
+	// failing to launch the process, e.g. because the command doesn't exist, won't be fixed by trying again
+	process, err := p.processFactory.newProcess(p.command, p.logger, p.logLevel)
 	if err != nil {
 		p.resetFailures++
 		return err
 	}
 	p.process = process
 
+	// The plugins are reinitialized for everyone sharing the process, so the caller which happened to trigger
+	// the restart mustn't be able to fail it by giving up.
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, reinitializeTimeout)
+	defer cancel()
+
 	// Redispense any previously dispensed plugins, reinitializing if necessary.
 	// Start by creating a new map to hold the newly dispensed plugins.
 	newPlugins := make(map[kindAndName]interface{})
@@ -123,16 +138,16 @@ func (p *restartableProcess) resetLH() error {
 		// Re-dispense
 		dispensed, err := p.process.dispense(key)
 		if err != nil {
-			p.resetFailures++
-			return err
+			p.resetFailedLH()
+			return &restartInProgressError{err: err}
 		}
 		// Store in the new map
 		newPlugins[key] = dispensed
 
 		// Reinitialize
 		if r, found := p.reinitializers[key]; found {
-			if err := r.reinitialize(dispensed); err != nil {
-				p.resetFailures++
+			if err := r.reinitialize(ctx, dispensed); err != nil {
+				p.resetFailedLH()
 				return err
 			}
 		}
@@ -146,14 +161,23 @@ func (p *restartableProcess) resetLH() error {
 	return nil
 }
 
+// resetFailedLH counts a failure to restart the plugin process after it was launched, and kills it, so that the next
+// call restarts it again rather than keep using the plugins dispensed from the process before.
+//
+// Callers of resetFailedLH *must* acquire the lock before calling it.
+func (p *restartableProcess) resetFailedLH() {
+	p.resetFailures++
+	p.process.kill()
+}
+
 // resetIfNeeded checks if the plugin process has exited and resets p if it has.
-func (p *restartableProcess) resetIfNeeded() error {
+func (p *restartableProcess) resetIfNeeded(ctx context.Context) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
 	if p.process.exited() {
 		p.logger.Info("Plugin process exited - restarting.")
-		return p.resetLH()
+		return p.resetLH(ctx)
 	}
 
 	return nil
@@ -183,6 +207,26 @@ func (p *restartableProcess) getByKindAndNameLH(key kindAndName) (interface{}, e
 	return p.plugins[key], nil
 }
 
+// restartInProgressError wraps an error from redispensing a plugin from a process while it's being restarted, which
+// is usually transient as a process that has just been launched may not be ready to serve plugins yet.
+type restartInProgressError struct {
+	err error
+}
+
+func (e *restartInProgressError) Error() string {
+	return e.err.Error()
+}
+
+func (e *restartInProgressError) Unwrap() error {
+	return e.err
+}
+
+// isRestartInProgress reports whether err is from a plugin process that is still being restarted.
+func isRestartInProgress(err error) bool {
+	var restartErr *restartInProgressError
+	return errors.As(err, &restartErr)
+}
+
 // stop terminates the plugin process.
 func (p *restartableProcess) stop() {
 	p.lock.Lock()
@@ -0,0 +1,166 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// fakeProcess is a Process which dispenses the same plugin for every key until it's killed, or fails with
+// dispenseErr if it's set.
+type fakeProcess struct {
+	plugin      interface{}
+	dispenseErr error
+	killed      bool
+}
+
+func (p *fakeProcess) dispense(key kindAndName) (interface{}, error) {
+	if p.dispenseErr != nil {
+		return nil, p.dispenseErr
+	}
+	return p.plugin, nil
+}
+
+func (p *fakeProcess) exited() bool {
+	return p.killed
+}
+
+func (p *fakeProcess) kill() {
+	p.killed = true
+}
+
+// fakeProcessFactory launches a fakeProcess dispensing plugin or failing with dispenseErr, or fails with err if
+// it's set.
+type fakeProcessFactory struct {
+	plugin      interface{}
+	dispenseErr error
+	err         error
+	launched    []*fakeProcess
+}
+
+func (f *fakeProcessFactory) newProcess(command string, logger logrus.FieldLogger, logLevel logrus.Level) (Process, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	process := &fakeProcess{plugin: f.plugin, dispenseErr: f.dispenseErr}
+	f.launched = append(f.launched, process)
+	return process, nil
+}
+
+// reinitializerFunc is a reinitializer calling itself.
+type reinitializerFunc func(ctx context.Context, dispensed interface{}) error
+
+func (f reinitializerFunc) reinitialize(ctx context.Context, dispensed interface{}) error {
+	return f(ctx, dispensed)
+}
+
+func newTestRestartableProcess(t *testing.T, factory *fakeProcessFactory) *restartableProcess {
+	p := &restartableProcess{
+		command:        "velero-plugin-fake",
+		logger:         test.NewLogger(),
+		processFactory: factory,
+		plugins:        make(map[kindAndName]interface{}),
+		reinitializers: make(map[kindAndName]reinitializer),
+	}
+	require.NoError(t, p.reset(context.Background()))
+	return p
+}
+
+func TestRestartableProcessResetIgnoresCallerCancellation(t *testing.T) {
+	factory := &fakeProcessFactory{plugin: "plugin"}
+	p := newTestRestartableProcess(t, factory)
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "fake"}
+	_, err := p.getByKindAndName(key)
+	require.NoError(t, err)
+
+	var reinitErr error
+	p.addReinitializer(key, reinitializerFunc(func(ctx context.Context, dispensed interface{}) error {
+		reinitErr = ctx.Err()
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline, "reinitializing should be bounded")
+		return reinitErr
+	}))
+
+	// the caller which happens to trigger the restart has given up already
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	factory.launched[0].kill()
+	require.NoError(t, p.resetIfNeeded(ctx))
+
+	assert.NoError(t, reinitErr)
+	assert.Zero(t, p.resetFailures)
+	assert.Len(t, factory.launched, 2)
+}
+
+func TestRestartableProcessResetFailureRestartsAgain(t *testing.T) {
+	factory := &fakeProcessFactory{plugin: "plugin"}
+	p := newTestRestartableProcess(t, factory)
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "fake"}
+	_, err := p.getByKindAndName(key)
+	require.NoError(t, err)
+
+	reinitErr := errors.New("reinitialize error")
+	p.addReinitializer(key, reinitializerFunc(func(ctx context.Context, dispensed interface{}) error {
+		return reinitErr
+	}))
+
+	factory.launched[0].kill()
+	assert.Equal(t, reinitErr, p.resetIfNeeded(context.Background()))
+	assert.Equal(t, 1, p.resetFailures)
+
+	// the process whose plugins couldn't be reinitialized isn't used, but restarted again by the next call
+	reinitErr = nil
+	require.NoError(t, p.resetIfNeeded(context.Background()))
+	assert.Len(t, factory.launched, 3)
+	assert.Zero(t, p.resetFailures)
+}
+
+func TestRestartableProcessRestartInProgressErrors(t *testing.T) {
+	factory := &fakeProcessFactory{plugin: "plugin"}
+	p := newTestRestartableProcess(t, factory)
+	key := kindAndName{kind: framework.PluginKindObjectStore, name: "fake"}
+	_, err := p.getByKindAndName(key)
+	require.NoError(t, err)
+
+	// a process which can't be launched won't be launched by retrying either
+	factory.err = errors.New("exec: \"velero-plugin-fake\": executable file not found in $PATH")
+	factory.launched[0].kill()
+	err = p.resetIfNeeded(context.Background())
+	assert.EqualError(t, err, factory.err.Error())
+	assert.False(t, isRestartInProgress(err))
+
+	// one which was launched but isn't serving plugins yet may be by the time it's retried
+	factory.err = nil
+	factory.dispenseErr = errors.New("plugin process not ready")
+	err = p.resetIfNeeded(context.Background())
+	assert.EqualError(t, err, "plugin process not ready")
+	assert.True(t, isRestartInProgress(err))
+
+	// dispensing a plugin for the first time isn't part of a restart
+	_, err = p.getByKindAndName(kindAndName{kind: framework.PluginKindBackupItemAction, name: "fake"})
+	require.Error(t, err)
+	assert.False(t, isRestartInProgress(err))
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -80,7 +81,7 @@ func TestRestartableRestoreItemActionGetDelegate(t *testing.T) {
 	defer p.AssertExpectations(t)
 
 	// Reset error
-	p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+	p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 	name := "pod"
 	r := newRestartableRestoreItemAction(name, p)
 	a, err := r.getDelegate()
@@ -88,7 +89,7 @@ func TestRestartableRestoreItemActionGetDelegate(t *testing.T) {
 	assert.EqualError(t, err, "reset error")
 
 	// Happy path
-	p.On("resetIfNeeded").Return(nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
 	expected := new(mocks.ItemAction)
 	key := kindAndName{kind: framework.PluginKindRestoreItemAction, name: name}
 	p.On("getByKindAndName", key).Return(expected, nil)
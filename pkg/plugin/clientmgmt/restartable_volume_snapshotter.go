@@ -17,6 +17,7 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -49,7 +50,7 @@ func newRestartableVolumeSnapshotter(name string, sharedPluginProcess Restartabl
 }
 
 // reinitialize reinitializes a re-dispensed plugin using the initial data passed to Init().
-func (r *restartableVolumeSnapshotter) reinitialize(dispensed interface{}) error {
+func (r *restartableVolumeSnapshotter) reinitialize(ctx context.Context, dispensed interface{}) error {
 	volumeSnapshotter, ok := dispensed.(velero.VolumeSnapshotter)
 	if !ok {
 		return errors.Errorf("%T is not a VolumeSnapshotter!", dispensed)
@@ -75,7 +76,7 @@ func (r *restartableVolumeSnapshotter) getVolumeSnapshotter() (velero.VolumeSnap
 
 // getDelegate restarts the plugin process (if needed) and returns the volume snapshotter for this restartableVolumeSnapshotter.
 func (r *restartableVolumeSnapshotter) getDelegate() (velero.VolumeSnapshotter, error) {
-	if err := r.sharedPluginProcess.resetIfNeeded(); err != nil {
+	if err := r.sharedPluginProcess.resetIfNeeded(context.Background()); err != nil {
 		return nil, err
 	}
 
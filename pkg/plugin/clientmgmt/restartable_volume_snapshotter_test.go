@@ -17,11 +17,13 @@ limitations under the License.
 package clientmgmt
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Azure/go-autorest/autorest/to"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
@@ -93,7 +95,7 @@ func TestRestartableVolumeSnapshotterReinitialize(t *testing.T) {
 		},
 	}
 
-	err := r.reinitialize(3)
+	err := r.reinitialize(context.Background(), 3)
 	assert.EqualError(t, err, "int is not a VolumeSnapshotter!")
 
 	volumeSnapshotter := new(providermocks.VolumeSnapshotter)
@@ -101,11 +103,11 @@ func TestRestartableVolumeSnapshotterReinitialize(t *testing.T) {
 	defer volumeSnapshotter.AssertExpectations(t)
 
 	volumeSnapshotter.On("Init", r.config).Return(errors.Errorf("init error")).Once()
-	err = r.reinitialize(volumeSnapshotter)
+	err = r.reinitialize(context.Background(), volumeSnapshotter)
 	assert.EqualError(t, err, "init error")
 
 	volumeSnapshotter.On("Init", r.config).Return(nil)
-	err = r.reinitialize(volumeSnapshotter)
+	err = r.reinitialize(context.Background(), volumeSnapshotter)
 	assert.NoError(t, err)
 }
 
@@ -115,7 +117,7 @@ func TestRestartableVolumeSnapshotterGetDelegate(t *testing.T) {
 	defer p.AssertExpectations(t)
 
 	// Reset error
-	p.On("resetIfNeeded").Return(errors.Errorf("reset error")).Once()
+	p.On("resetIfNeeded", mock.Anything).Return(errors.Errorf("reset error")).Once()
 	name := "aws"
 	key := kindAndName{kind: framework.PluginKindVolumeSnapshotter, name: name}
 	r := &restartableVolumeSnapshotter{
@@ -127,7 +129,7 @@ func TestRestartableVolumeSnapshotterGetDelegate(t *testing.T) {
 	assert.EqualError(t, err, "reset error")
 
 	// Happy path
-	p.On("resetIfNeeded").Return(nil)
+	p.On("resetIfNeeded", mock.Anything).Return(nil)
 	volumeSnapshotter := new(providermocks.VolumeSnapshotter)
 	volumeSnapshotter.Test(t)
 	defer volumeSnapshotter.AssertExpectations(t)
@@ -0,0 +1,132 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+const (
+	// softDeleteConfigKey is the config key used to make DeleteObject move objects to the trash instead of
+	// deleting them.
+	softDeleteConfigKey = "softDelete"
+
+	// trashPrefix is the prefix soft-deleted objects are moved to. While softDelete is enabled, it is excluded
+	// from listings unless it's listed explicitly.
+	trashPrefix = ".trash/"
+	// trashTimestampFormat is the format of the deletion time in the keys of soft-deleted objects, which sorts
+	// chronologically.
+	trashTimestampFormat = "20060102T150405.000000000Z"
+)
+
+// trashKey returns the key the object with the given key is moved to when it's soft-deleted at deletedAt.
+func trashKey(key string, deletedAt time.Time) string {
+	return trashPrefix + deletedAt.UTC().Format(trashTimestampFormat) + "/" + key
+}
+
+// parseTrashKey returns the time the object moved to the trash under the given key was soft-deleted.
+func parseTrashKey(key string) (time.Time, bool) {
+	if !strings.HasPrefix(key, trashPrefix) {
+		return time.Time{}, false
+	}
+	timestamp := strings.SplitN(strings.TrimPrefix(key, trashPrefix), "/", 2)[0]
+	deletedAt, err := time.Parse(trashTimestampFormat, timestamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return deletedAt, true
+}
+
+// withoutTrash removes the keys or common prefixes in the trash from keys if softDelete is enabled, unless prefix
+// is in the trash itself, i.e. the trash is being listed explicitly. Without softDelete, there's no trash, and keys
+// which happen to start with its prefix are left alone.
+func withoutTrash(softDelete bool, prefix string, keys []string, err error) ([]string, error) {
+	if err != nil || !softDelete || inTrash(prefix) {
+		return keys, err
+	}
+
+	trashed := 0
+	for _, key := range keys {
+		if inTrash(key) {
+			trashed++
+		}
+	}
+	if trashed == 0 {
+		return keys, nil
+	}
+
+	res := make([]string, 0, len(keys)-trashed)
+	for _, key := range keys {
+		if !inTrash(key) {
+			res = append(res, key)
+		}
+	}
+	return res, nil
+}
+
+// inTrash returns whether the object with the given key is in the trash.
+func inTrash(key string) bool {
+	return strings.HasPrefix(key, trashPrefix)
+}
+
+// checkSoftDelete returns an error if config enables softDelete but objectStore is a v1 plugin, which can't move
+// or copy objects into the trash.
+func checkSoftDelete(objectStore velero.ObjectStore, config *objectStoreConfig) error {
+	if !config.softDelete {
+		return nil
+	}
+	if _, ok := objectStore.(osv2.ObjectStore); !ok {
+		return errors.Errorf("config key %q requires a plugin implementing the v2 object store API, which can copy objects", softDeleteConfigKey)
+	}
+	return nil
+}
+
+// moveToTrash soft-deletes the object with the given key by moving it into the trash with delegate, from where it
+// can be recovered with MoveObject until it's purged.
+func (r *restartableObjectStore) moveToTrash(ctx context.Context, delegate osv2.ObjectStore, bucket, key string) error {
+	return errors.Wrapf(r.moveObject(ctx, delegate, bucket, key, trashKey(key, time.Now())), "error moving object %s to the trash", key)
+}
+
+// PurgeTrash permanently deletes the objects in bucket which were soft-deleted more than olderThan ago. Objects
+// are purged even if softDelete has been disabled since they were deleted.
+func (r *restartableObjectStore) PurgeTrash(ctx context.Context, bucket string, olderThan time.Duration) error {
+	keys, err := r.ListObjectsV2(ctx, bucket, trashPrefix)
+	if err != nil {
+		return errors.Wrap(err, "error listing the trash")
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	var errs []error
+	for _, key := range keys {
+		deletedAt, ok := parseTrashKey(key)
+		if !ok || !deletedAt.Before(cutoff) {
+			continue
+		}
+		if err := r.DeleteObjectV2(ctx, bucket, key); err != nil {
+			errs = append(errs, errors.Wrapf(err, "error purging object %s", key))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
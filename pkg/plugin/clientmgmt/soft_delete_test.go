@@ -0,0 +1,139 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+func TestTrashKey(t *testing.T) {
+	deletedAt := time.Date(2022, 3, 4, 5, 6, 7, 8, time.UTC)
+	key := trashKey("backups/b1/b1.tar.gz", deletedAt)
+	assert.Equal(t, ".trash/20220304T050607.000000008Z/backups/b1/b1.tar.gz", key)
+
+	parsed, ok := parseTrashKey(key)
+	require.True(t, ok)
+	assert.Equal(t, deletedAt, parsed)
+
+	_, ok = parseTrashKey("backups/b1/b1.tar.gz")
+	assert.False(t, ok)
+	_, ok = parseTrashKey(".trash/not-a-timestamp/backups/b1/b1.tar.gz")
+	assert.False(t, ok)
+}
+
+func TestRestartableObjectStoreSoftDelete(t *testing.T) {
+	objectStore := newCopyingObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{softDeleteConfigKey: "true"}))
+
+	require.NoError(t, r.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup")))
+	require.NoError(t, r.PutObject("bucket", "backups/b2/b2.tar.gz", strings.NewReader("backup")))
+	require.NoError(t, r.DeleteObject("bucket", "backups/b1/b1.tar.gz"))
+
+	exists, err := r.ObjectExists("bucket", "backups/b1/b1.tar.gz")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// the trash is left out of listings, unless it's listed explicitly
+	keys, err := r.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b2/b2.tar.gz"}, keys)
+	prefixes, err := r.ListCommonPrefixes("bucket", "", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/"}, prefixes)
+
+	trashed, err := r.ListObjects("bucket", trashPrefix)
+	require.NoError(t, err)
+	require.Len(t, trashed, 1)
+	assert.True(t, strings.HasSuffix(trashed[0], "/backups/b1/b1.tar.gz"))
+
+	// objects in the trash can't be read until they're moved out of it
+	_, err = r.GetObject("bucket", trashed[0])
+	assert.True(t, errors.Is(err, osv2.ErrObjectNotFound), "unexpected error %v", err)
+
+	// objects are only purged once they've been in the trash for long enough
+	require.NoError(t, r.PurgeTrash(context.Background(), "bucket", time.Hour))
+	keys, err = objectStore.fake.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{trashed[0], "backups/b2/b2.tar.gz"}, keys)
+
+	require.NoError(t, r.PurgeTrash(context.Background(), "bucket", 0))
+	keys, err = objectStore.fake.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backups/b2/b2.tar.gz"}, keys)
+}
+
+func TestRestartableObjectStoreHardDeleteByDefault(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	require.NoError(t, r.PutObject("bucket", "backups/b1/b1.tar.gz", strings.NewReader("backup")))
+	require.NoError(t, r.DeleteObject("bucket", "backups/b1/b1.tar.gz"))
+
+	keys, err := objectStore.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestRestartableObjectStoreListingsWithoutSoftDelete(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+
+	// without softDelete there's no trash, so objects which happen to be under its prefix are listed
+	require.NoError(t, r.PutObject("bucket", ".trash/b1.tar.gz", strings.NewReader("backup")))
+	require.NoError(t, r.PutObject("bucket", "backups/b2/b2.tar.gz", strings.NewReader("backup")))
+
+	keys, err := r.ListObjects("bucket", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{".trash/b1.tar.gz", "backups/b2/b2.tar.gz"}, keys)
+	prefixes, err := r.ListCommonPrefixes("bucket", "", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{trashPrefix, "backups/"}, prefixes)
+}
+
+func TestRestartableObjectStoreSoftDeleteV1Plugin(t *testing.T) {
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", test.V1Only(test.NewFakeObjectStore("bucket")))
+
+	// a v1 plugin can't copy objects into the trash
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+	err := r.Init(map[string]string{softDeleteConfigKey: "true"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), softDeleteConfigKey)
+
+	r = newRestartableObjectStore("fake", p, test.NewLogger())
+	require.NoError(t, r.Init(map[string]string{}))
+	err = r.UpdateConfig(context.Background(), map[string]string{softDeleteConfigKey: "true"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), softDeleteConfigKey)
+	assert.False(t, r.currentConfig().softDelete)
+}
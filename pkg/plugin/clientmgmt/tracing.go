@@ -0,0 +1,89 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the OpenTelemetry tracer object store spans are started with.
+const tracerName = "github.com/vmware-tanzu/velero/pkg/plugin/clientmgmt"
+
+// Attribute keys recorded on object store spans. Every span records the plugin, and the bucket and key if the
+// operation acts on them. Only PutObject spans record a byte count, of the bytes read from the uploaded body.
+const (
+	spanAttributePlugin = attribute.Key("velero.object_store.plugin")
+	spanAttributeBucket = attribute.Key("velero.object_store.bucket")
+	spanAttributeKey    = attribute.Key("velero.object_store.key")
+	spanAttributeBytes  = attribute.Key("velero.object_store.bytes")
+)
+
+// startSpan starts a span for the object store operation op, e.g. "PutObject", as a child of the span in ctx, if
+// any. bucket and key may be empty for operations that don't act on a bucket or a single object. Spans are started
+// with the global tracer provider, so they are dropped, and ctx is returned as is, unless one has been configured.
+func (r *restartableObjectStore) startSpan(ctx context.Context, op, bucket, key string) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{spanAttributePlugin.String(r.key.name)}
+	if bucket != "" {
+		attributes = append(attributes, spanAttributeBucket.String(bucket))
+	}
+	if key != "" {
+		attributes = append(attributes, spanAttributeKey.String(key))
+	}
+	spanCtx, span := otel.Tracer(tracerName).Start(ctx, "ObjectStore."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	if !span.IsRecording() {
+		// leave ctx untouched so that tracing is a no-op when no tracer is configured
+		return ctx, span
+	}
+	return spanCtx, span
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// endOperation ends the span of an operation like endSpan, counts the operation's outcome towards the
+// consecutive failures events are recorded about, and records it in the operation metrics. It returns err wrapped
+// with wrapError.
+func (r *restartableObjectStore) endOperation(op *objectStoreOperation, err error) error {
+	endSpan(op.span, err)
+	r.recordOutcome(err)
+	r.observeOperation(op, err)
+	return r.wrapError(op.method, op.bucket, op.key, err)
+}
+
+// spanByteCounter counts the bytes read from Reader, to record them on a PutObject span.
+type spanByteCounter struct {
+	io.Reader
+	bytes int64
+}
+
+func (c *spanByteCounter) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.bytes += int64(n)
+	return n, err
+}
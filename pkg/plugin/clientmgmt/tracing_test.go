@@ -0,0 +1,134 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	osv2mocks "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// fakeSpan records what is done to it. The embedded no-op span provides the rest of the Span interface.
+type fakeSpan struct {
+	trace.Span
+	name       string
+	parent     trace.Span
+	attributes map[attribute.Key]attribute.Value
+	err        error
+	status     codes.Code
+	ended      bool
+}
+
+func (s *fakeSpan) IsRecording() bool { return true }
+
+func (s *fakeSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attributes[a.Key] = a.Value
+	}
+}
+
+func (s *fakeSpan) RecordError(err error, _ ...trace.EventOption) { s.err = err }
+
+func (s *fakeSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+
+func (s *fakeSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+// fakeTracerProvider is a TracerProvider whose tracers record the spans they start.
+type fakeTracerProvider struct {
+	lock  sync.Mutex
+	spans []*fakeSpan
+}
+
+func (p *fakeTracerProvider) Tracer(string, ...trace.TracerOption) trace.Tracer { return p }
+
+func (p *fakeTracerProvider) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	span := &fakeSpan{
+		Span:       trace.SpanFromContext(context.Background()),
+		name:       name,
+		parent:     trace.SpanFromContext(ctx),
+		attributes: make(map[attribute.Key]attribute.Value),
+	}
+	config := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(config.Attributes()...)
+
+	p.lock.Lock()
+	p.spans = append(p.spans, span)
+	p.lock.Unlock()
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+func TestRestartableObjectStoreTracing(t *testing.T) {
+	provider := new(fakeTracerProvider)
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	objectStore := new(osv2mocks.ObjectStore)
+	objectStore.Test(t)
+	defer objectStore.AssertExpectations(t)
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "aws", objectStore)
+	r := newRestartableObjectStore("aws", p, test.NewLogger())
+
+	objectStore.On("InitV2", mock.Anything, map[string]string{}).Return(nil)
+	require.NoError(t, r.Init(map[string]string{}))
+
+	parentCtx, parent := provider.Start(context.Background(), "Backup")
+
+	objectStore.On("PutObjectV2", mock.Anything, "bucket", "key", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		data, err := ioutil.ReadAll(args.Get(3).(io.Reader))
+		assert.NoError(t, err)
+		assert.Equal(t, "backup", string(data))
+	})
+	require.NoError(t, r.PutObjectV2(parentCtx, "bucket", "key", strings.NewReader("backup")))
+
+	objectStore.On("DeleteObjectV2", mock.Anything, "bucket", "key").Return(errors.New("delete error"))
+	assert.EqualError(t, r.DeleteObjectV2(parentCtx, "bucket", "key"), "DeleteObject bucket/key: delete error")
+
+	require.Len(t, provider.spans, 4)
+
+	put := provider.spans[2]
+	assert.Equal(t, "ObjectStore.PutObject", put.name)
+	assert.Equal(t, parent, put.parent)
+	assert.Equal(t, "aws", put.attributes[spanAttributePlugin].AsString())
+	assert.Equal(t, "bucket", put.attributes[spanAttributeBucket].AsString())
+	assert.Equal(t, "key", put.attributes[spanAttributeKey].AsString())
+	assert.Equal(t, int64(6), put.attributes[spanAttributeBytes].AsInt64())
+	assert.Equal(t, codes.Unset, put.status)
+	assert.True(t, put.ended)
+
+	del := provider.spans[3]
+	assert.Equal(t, "ObjectStore.DeleteObject", del.name)
+	assert.Equal(t, parent, del.parent)
+	assert.EqualError(t, del.err, "delete error")
+	assert.Equal(t, codes.Error, del.status)
+	assert.True(t, del.ended)
+}
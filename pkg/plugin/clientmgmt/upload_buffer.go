@@ -0,0 +1,92 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"io"
+	"sync"
+)
+
+// maxUploadBufferChunkSize is the largest chunk an upload buffer reads from its source at once.
+const maxUploadBufferChunkSize = 32 * 1024
+
+// uploadBuffer reads ahead from the body of an upload in the background, into a buffer of bounded size. While the
+// buffer is full, reading ahead is blocked, so that a source which produces data faster than it can be uploaded
+// is held back rather than buffered without bound.
+type uploadBuffer struct {
+	chunks chan []byte
+	done   chan struct{}
+	stop   sync.Once
+	// readErr is the error reading from the source failed with, io.EOF at its end. It is set before chunks
+	// is closed.
+	readErr error
+	current []byte
+}
+
+// newUploadBuffer returns a reader of body which reads ahead up to about size bytes, and a function which must be
+// called once the reader isn't used anymore to release the goroutine reading from body.
+func newUploadBuffer(body io.Reader, size int) (io.Reader, func()) {
+	chunkSize := size
+	if chunkSize > maxUploadBufferChunkSize {
+		chunkSize = maxUploadBufferChunkSize
+	}
+	b := &uploadBuffer{
+		// one more chunk is held by fill while it waits for room, and another by Read
+		chunks: make(chan []byte, size/chunkSize),
+		done:   make(chan struct{}),
+	}
+	go b.fill(body, chunkSize)
+	return b, func() {
+		b.stop.Do(func() { close(b.done) })
+	}
+}
+
+func (b *uploadBuffer) fill(body io.Reader, chunkSize int) {
+	defer close(b.chunks)
+	for {
+		chunk := make([]byte, chunkSize)
+		n, err := body.Read(chunk)
+		if n > 0 {
+			select {
+			case b.chunks <- chunk[:n]:
+			case <-b.done:
+				return
+			}
+		}
+		if err != nil {
+			b.readErr = err
+			return
+		}
+	}
+}
+
+func (b *uploadBuffer) Read(p []byte) (int, error) {
+	for len(b.current) == 0 {
+		chunk, ok := <-b.chunks
+		if !ok {
+			if b.readErr == nil {
+				// the buffer was stopped
+				return 0, io.ErrClosedPipe
+			}
+			return 0, b.readErr
+		}
+		b.current = chunk
+	}
+	n := copy(p, b.current)
+	b.current = b.current[n:]
+	return n, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clientmgmt
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/framework"
+	"github.com/vmware-tanzu/velero/pkg/test"
+)
+
+// countingSource is an endless source of data which counts the bytes read from it.
+type countingSource struct {
+	read int64
+}
+
+func (s *countingSource) Read(p []byte) (int, error) {
+	atomic.AddInt64(&s.read, int64(len(p)))
+	return len(p), nil
+}
+
+func TestUploadBuffer(t *testing.T) {
+	// the whole body is read through the buffer
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	buffered, stop := newUploadBuffer(bytes.NewReader(data), 4096)
+	read, err := ioutil.ReadAll(buffered)
+	require.NoError(t, err)
+	assert.Equal(t, data, read)
+	stop()
+
+	// errors reading the body are passed on
+	buffered, stop = newUploadBuffer(io.MultiReader(strings.NewReader("data"), iotest.ErrReader(errors.New("read error"))), 4096)
+	_, err = ioutil.ReadAll(buffered)
+	assert.EqualError(t, err, "read error")
+	stop()
+
+	// a source which is faster than the upload is held back once the buffer is full
+	source := new(countingSource)
+	_, stop = newUploadBuffer(source, 4*maxUploadBufferChunkSize)
+	defer stop()
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, atomic.LoadInt64(&source.read), int64(5*maxUploadBufferChunkSize))
+}
+
+func TestRestartableObjectStoreUploadBuffer(t *testing.T) {
+	objectStore := test.NewFakeObjectStore("bucket")
+	p := newFakeRestartableProcess().dispense(framework.PluginKindObjectStore, "fake", objectStore)
+	r := newRestartableObjectStore("fake", p, test.NewLogger())
+
+	require.NoError(t, r.Init(map[string]string{uploadBufferSizeConfigKey: "64Ki"}))
+	assert.Equal(t, 64*1024, r.currentConfig().uploadBufferSize)
+	assert.Equal(t, map[string]string{}, objectStore.Config)
+
+	data := strings.Repeat("backup", 100000)
+	require.NoError(t, r.PutObject("bucket", "key", strings.NewReader(data)))
+
+	rc, err := objectStore.GetObject("bucket", "key")
+	require.NoError(t, err)
+	read, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(read))
+
+	r = newRestartableObjectStore("fake", p, test.NewLogger())
+	assert.EqualError(t, r.Init(map[string]string{uploadBufferSizeConfigKey: "-1"}), `Init: invalid value for config key "uploadBufferSize": "-1"`)
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package framework
 
 import (
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/status"
 
 	proto "github.com/vmware-tanzu/velero/pkg/plugin/generated"
@@ -29,23 +32,34 @@ import (
 // This function should be used in the internal plugin client code to convert
 // all errors returned from the plugin server before they're passed back to
 // the rest of the Velero codebase. This will enable them to display location
-// information when they're logged.
+// information when they're logged. The status code and request ID of a failed
+// provider request are kept too, so that osv2.ProviderErrorDetails can report them.
 func fromGRPCError(err error) error {
 	statusErr, ok := status.FromError(err)
 	if !ok {
 		return statusErr.Err()
 	}
 
+	var stack *proto.Stack
 	for _, detail := range statusErr.Details() {
 		switch t := detail.(type) {
 		case *proto.Stack:
-			return &protoStackError{
-				error: err,
-				stack: t,
+			if stack == nil {
+				stack = t
+			}
+		case *errdetails.ErrorInfo:
+			if t.Reason == providerErrorReason && t.Domain == providerErrorDomain {
+				err = newProviderError(err, t.Metadata)
 			}
 		}
 	}
 
+	if stack != nil {
+		return &protoStackError{
+			error: err,
+			stack: stack,
+		}
+	}
 	return err
 }
 
@@ -77,3 +91,37 @@ func (e *protoStackError) Function() string {
 
 	return e.stack.Frames[0].Function
 }
+
+func (e *protoStackError) Unwrap() error {
+	return e.error
+}
+
+// providerError is an error reporting the status code and request ID of a failed
+// provider request.
+type providerError struct {
+	error
+	statusCode int
+	requestID  string
+}
+
+func newProviderError(err error, metadata map[string]string) *providerError {
+	// the status code is only missing if the plugin didn't report one
+	statusCode, _ := strconv.Atoi(metadata[providerErrorStatusCodeKey])
+	return &providerError{
+		error:      err,
+		statusCode: statusCode,
+		requestID:  metadata[providerErrorRequestIDKey],
+	}
+}
+
+func (e *providerError) StatusCode() int {
+	return e.statusCode
+}
+
+func (e *providerError) RequestID() string {
+	return e.requestID
+}
+
+func (e *providerError) Unwrap() error {
+	return e.error
+}
@@ -0,0 +1,54 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+type requestFailure struct {
+	statusCode int
+	requestID  string
+}
+
+func (e *requestFailure) Error() string     { return "request failed" }
+func (e *requestFailure) StatusCode() int   { return e.statusCode }
+func (e *requestFailure) RequestID() string { return e.requestID }
+
+func TestFromGRPCErrorKeepsProviderErrorDetails(t *testing.T) {
+	err := fromGRPCError(newGRPCError(errors.WithStack(&requestFailure{statusCode: 503, requestID: "req-1"})))
+
+	code, requestID, ok := osv2.ProviderErrorDetails(err)
+	assert.True(t, ok)
+	assert.Equal(t, 503, code)
+	assert.Equal(t, "req-1", requestID)
+
+	// the stack trace is still reported
+	stackErr, ok := err.(*protoStackError)
+	if assert.True(t, ok) {
+		assert.NotEmpty(t, stackErr.Function())
+	}
+
+	// errors without details stay as they are
+	_, _, ok = osv2.ProviderErrorDetails(fromGRPCError(newGRPCError(errors.New("plain"))))
+	assert.False(t, ok)
+}
@@ -17,15 +17,29 @@ limitations under the License.
 package framework
 
 import (
+	"strconv"
+
 	goproto "github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	proto "github.com/vmware-tanzu/velero/pkg/plugin/generated"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
 	"github.com/vmware-tanzu/velero/pkg/util/logging"
 )
 
+const (
+	// providerErrorReason and providerErrorDomain identify the ErrorInfo detail carrying the
+	// details of a failed provider request across the wire.
+	providerErrorReason = "PROVIDER_ERROR"
+	providerErrorDomain = "velero.io"
+
+	providerErrorStatusCodeKey = "statusCode"
+	providerErrorRequestIDKey  = "requestID"
+)
+
 // newGRPCErrorWithCode wraps err in a gRPC status error with the error's stack trace
 // included in the details if it exists. This provides an easy way to send
 // stack traces from plugin servers across the wire to the plugin client.
@@ -44,6 +58,12 @@ func newGRPCErrorWithCode(err error, code codes.Code, details ...goproto.Message
 		details = append(details, stack)
 	}
 
+	// add the status code and request ID of a failed provider request, so that the
+	// client can report them
+	if info := providerErrorInfo(err); info != nil {
+		details = append(details, info)
+	}
+
 	statusErr, err = statusErr.WithDetails(details...)
 	if err != nil {
 		return status.Errorf(codes.Unknown, "error adding details to the gRPC error: %v", err)
@@ -83,3 +103,21 @@ func errorStack(err error) *proto.Stack {
 type stackTracer interface {
 	StackTrace() errors.StackTrace
 }
+
+// providerErrorInfo returns the details of the failed provider request err reports, if any,
+// as an ErrorInfo.
+func providerErrorInfo(err error) *errdetails.ErrorInfo {
+	code, requestID, ok := osv2.ProviderErrorDetails(err)
+	if !ok {
+		return nil
+	}
+
+	return &errdetails.ErrorInfo{
+		Reason: providerErrorReason,
+		Domain: providerErrorDomain,
+		Metadata: map[string]string{
+			providerErrorStatusCodeKey: strconv.Itoa(code),
+			providerErrorRequestIDKey:  requestID,
+		},
+	}
+}
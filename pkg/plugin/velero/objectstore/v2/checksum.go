@@ -0,0 +1,97 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+)
+
+// ChecksumAlgorithm is an algorithm the checksums of objects are computed with.
+type ChecksumAlgorithm string
+
+const (
+	// ChecksumSHA256 computes the SHA-256 digest of objects.
+	ChecksumSHA256 ChecksumAlgorithm = "SHA256"
+	// ChecksumCRC32C computes the CRC-32 of objects with the Castagnoli polynomial.
+	ChecksumCRC32C ChecksumAlgorithm = "CRC32C"
+)
+
+// NewHash returns a hash computing checksums with a. Checksums are the hex encoding of the
+// hash's sum, in lower case.
+func (a ChecksumAlgorithm) NewHash() (hash.Hash, error) {
+	switch a {
+	case ChecksumSHA256:
+		return sha256.New(), nil
+	case ChecksumCRC32C:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli)), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm %q", a)
+	}
+}
+
+// ChecksumMismatchError is returned by a reader from NewVerifyingReader when the object it read
+// doesn't have the expected checksum, e.g. because it was corrupted or truncated.
+type ChecksumMismatchError struct {
+	Algorithm ChecksumAlgorithm
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// NewVerifyingReader returns a reader for body, e.g. an object returned by GetObjectV2, which
+// computes body's checksum with algorithm as it's read and, once body is read to the end, returns
+// a ChecksumMismatchError instead of io.EOF if it isn't expected. Closing the returned reader
+// closes body.
+func NewVerifyingReader(body io.ReadCloser, algorithm ChecksumAlgorithm, expected string) (io.ReadCloser, error) {
+	h, err := algorithm.NewHash()
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{body: body, hash: h, algorithm: algorithm, expected: expected}, nil
+}
+
+type verifyingReader struct {
+	body      io.ReadCloser
+	hash      hash.Hash
+	algorithm ChecksumAlgorithm
+	expected  string
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+	if err != io.EOF {
+		return n, err
+	}
+	if actual := hex.EncodeToString(r.hash.Sum(nil)); !strings.EqualFold(actual, r.expected) {
+		return n, &ChecksumMismatchError{Algorithm: r.algorithm, Expected: r.expected, Actual: actual}
+	}
+	return n, io.EOF
+}
+
+func (r *verifyingReader) Close() error {
+	return r.body.Close()
+}
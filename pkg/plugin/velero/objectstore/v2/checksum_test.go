@@ -0,0 +1,71 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+func TestVerifyingReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm v2.ChecksumAlgorithm
+		checksum  string
+	}{
+		{
+			name:      "SHA-256",
+			algorithm: v2.ChecksumSHA256,
+			checksum:  "f97c387c9eb1a86dd0fa1d22c75c998d4695ec1bb0e7834cd7d4f5607b676cbc",
+		},
+		{
+			name:      "CRC32C",
+			algorithm: v2.ChecksumCRC32C,
+			checksum:  "20e40852",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, err := v2.NewVerifyingReader(ioutil.NopCloser(strings.NewReader("backup contents")), tc.algorithm, tc.checksum)
+			require.NoError(t, err)
+			contents, err := ioutil.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, "backup contents", string(contents))
+
+			// a truncated body doesn't match the checksum
+			rc, err = v2.NewVerifyingReader(ioutil.NopCloser(strings.NewReader("backup")), tc.algorithm, tc.checksum)
+			require.NoError(t, err)
+			_, err = ioutil.ReadAll(rc)
+			var mismatch *v2.ChecksumMismatchError
+			require.True(t, errors.As(err, &mismatch), "unexpected error %v", err)
+			assert.Equal(t, tc.algorithm, mismatch.Algorithm)
+			assert.Equal(t, tc.checksum, mismatch.Expected)
+			assert.NotEqual(t, tc.checksum, mismatch.Actual)
+		})
+	}
+
+	_, err := v2.NewVerifyingReader(ioutil.NopCloser(strings.NewReader("")), "MD5", "")
+	assert.EqualError(t, err, `unsupported checksum algorithm "MD5"`)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ListObjectsModifiedSince returns the metadata of the objects in bucket with the given prefix
+// that were modified after since, keyed by object key, so that callers syncing state from object
+// storage only need to fetch the objects which changed, e.g. with GetObjectIfModifiedSince. It
+// uses a single ListObjectsInfo call, falling back to stating each listed object if the object
+// store doesn't support it. Deleted objects aren't reported.
+func ListObjectsModifiedSince(ctx context.Context, store ObjectStore, bucket, prefix string, since time.Time) (map[string]ObjectInfo, error) {
+	infos, err := store.ListObjectsInfo(ctx, bucket, prefix)
+	if errors.Is(err, ErrUnsupported) {
+		infos, err = statObjects(ctx, store, bucket, prefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	changed := make(map[string]ObjectInfo)
+	for key, info := range infos {
+		if info.LastModified.After(since) {
+			changed[key] = info
+		}
+	}
+	return changed, nil
+}
+
+// statObjects gets the metadata of the objects in bucket with the given prefix one object at a time.
+func statObjects(ctx context.Context, store ObjectStore, bucket, prefix string) (map[string]ObjectInfo, error) {
+	keys, err := store.ListObjectsV2(ctx, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make(map[string]ObjectInfo, len(keys))
+	for _, key := range keys {
+		info, err := store.GetObjectInfoV2(ctx, bucket, key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting the metadata of object %s", key)
+		}
+		infos[key] = info
+	}
+	return infos, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+)
+
+func TestListObjectsModifiedSince(t *testing.T) {
+	since := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+	old := v2.ObjectInfo{Size: 10, LastModified: since.Add(-time.Hour)}
+	changed := v2.ObjectInfo{Size: 20, LastModified: since.Add(time.Hour)}
+
+	t.Run("uses ListObjectsInfo", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		defer store.AssertExpectations(t)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "backups/").Return(map[string]v2.ObjectInfo{
+			"backups/b1/velero-backup.json": old,
+			"backups/b2/velero-backup.json": changed,
+		}, nil)
+
+		res, err := v2.ListObjectsModifiedSince(context.Background(), store, "bucket", "backups/", since)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]v2.ObjectInfo{"backups/b2/velero-backup.json": changed}, res)
+	})
+
+	t.Run("falls back to GetObjectInfoV2", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		defer store.AssertExpectations(t)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "backups/").Return(nil, v2.ErrUnsupported)
+		store.On("ListObjectsV2", context.Background(), "bucket", "backups/").Return([]string{"backups/b1/velero-backup.json", "backups/b2/velero-backup.json"}, nil)
+		store.On("GetObjectInfoV2", context.Background(), "bucket", "backups/b1/velero-backup.json").Return(old, nil)
+		store.On("GetObjectInfoV2", context.Background(), "bucket", "backups/b2/velero-backup.json").Return(changed, nil)
+
+		res, err := v2.ListObjectsModifiedSince(context.Background(), store, "bucket", "backups/", since)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]v2.ObjectInfo{"backups/b2/velero-backup.json": changed}, res)
+	})
+
+	t.Run("fails if the objects can't be stated", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "backups/").Return(nil, v2.ErrUnsupported)
+		store.On("ListObjectsV2", context.Background(), "bucket", "backups/").Return([]string{"backups/b1/velero-backup.json"}, nil)
+		store.On("GetObjectInfoV2", context.Background(), "bucket", "backups/b1/velero-backup.json").Return(v2.ObjectInfo{}, v2.ErrUnsupported)
+
+		_, err := v2.ListObjectsModifiedSince(context.Background(), store, "bucket", "backups/", since)
+		assert.True(t, errors.Is(err, v2.ErrUnsupported))
+	})
+}
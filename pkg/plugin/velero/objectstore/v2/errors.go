@@ -0,0 +1,110 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported is returned by an ObjectStore for an operation that the underlying
+// provider does not support. Callers are expected to check for it with errors.Is and
+// fall back to an alternative where one exists.
+var ErrUnsupported = errors.New("operation not supported by object store")
+
+// ErrObjectNotFound is returned by an ObjectStore when there is no object with the given key.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrBucketNotFound is returned by an ObjectStore when the bucket doesn't exist.
+var ErrBucketNotFound = errors.New("bucket not found")
+
+// ErrTransient is returned by an ObjectStore for a failure which is expected to go away, e.g. a
+// network error, a throttled request or a plugin process being restarted, so that the operation
+// is worth retrying.
+var ErrTransient = errors.New("transient object store error")
+
+// ErrAccessDenied is returned by an ObjectStore when its credentials aren't allowed to perform
+// the operation.
+var ErrAccessDenied = errors.New("access to object store denied")
+
+// ErrRangeNotSatisfiable is returned by GetObjectRangeV2 when the range starts beyond the end
+// of the object.
+var ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+
+// ErrCopyNotSupported is returned by CopyObjectV2 when the object store can't copy objects
+// without them passing through Velero. Callers may fall back to streaming the object with
+// GetObject and PutObject. It wraps ErrUnsupported.
+var ErrCopyNotSupported = fmt.Errorf("%w: objects can't be copied without streaming them", ErrUnsupported)
+
+// ProviderErrorDetails extracts the status code and the request ID of a failed provider
+// request from err, which object stores report through StatusCode() int and
+// RequestID() string methods of an error in err's chain. ok is false if err has neither.
+func ProviderErrorDetails(err error) (code int, requestID string, ok bool) {
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		code, ok = statusErr.StatusCode(), true
+	}
+	var requestErr interface{ RequestID() string }
+	if errors.As(err, &requestErr) {
+		requestID, ok = requestErr.RequestID(), true
+	}
+	return code, requestID, ok
+}
+
+// IsObjectNotFound reports whether err, or an error in its chain, is ErrObjectNotFound.
+func IsObjectNotFound(err error) bool {
+	return errors.Is(err, ErrObjectNotFound)
+}
+
+// OperationError is an error of a failed ObjectStore operation, telling which operation failed
+// on which object. It wraps the error the object store returned, so that errors.Is and errors.As
+// see through it, and its message is that error's prefixed with the operation and object, e.g.
+// "GetObject bucket/backups/b1/b1.tar.gz: connection reset by peer". If Kind is set, errors.Is
+// also reports the error to be Kind.
+type OperationError struct {
+	// Op is the ObjectStore method which failed, e.g. "GetObject".
+	Op string
+	// Bucket is the bucket the operation was on, if any.
+	Bucket string
+	// Key is the key or prefix the operation was on, if any.
+	Key string
+	// Kind is one of ErrObjectNotFound, ErrBucketNotFound, ErrTransient and ErrAccessDenied,
+	// for errors which were classified as such without being one, e.g. from their status code.
+	Kind error
+	// Err is the error the object store returned.
+	Err error
+}
+
+func (e *OperationError) Error() string {
+	switch {
+	case e.Bucket == "" && e.Key == "":
+		return fmt.Sprintf("%s: %v", e.Op, e.Err)
+	case e.Key == "":
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Bucket, e.Err)
+	default:
+		return fmt.Sprintf("%s %s/%s: %v", e.Op, e.Bucket, e.Key, e.Err)
+	}
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is e's Kind.
+func (e *OperationError) Is(target error) bool {
+	return e.Kind != nil && target == e.Kind
+}
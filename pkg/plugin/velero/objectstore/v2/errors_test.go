@@ -0,0 +1,63 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+func TestIsObjectNotFound(t *testing.T) {
+	assert.True(t, v2.IsObjectNotFound(v2.ErrObjectNotFound))
+	assert.True(t, v2.IsObjectNotFound(fmt.Errorf("error getting backup: %w", v2.ErrObjectNotFound)))
+	assert.False(t, v2.IsObjectNotFound(v2.ErrBucketNotFound))
+	assert.False(t, v2.IsObjectNotFound(nil))
+}
+
+func TestOperationError(t *testing.T) {
+	cause := errors.New("connection reset by peer")
+	var err error = &v2.OperationError{Op: "GetObject", Bucket: "bucket", Key: "backups/b1/b1.tar.gz", Kind: v2.ErrTransient, Err: cause}
+
+	// the message says which operation failed on which object, and the error is still the
+	// object store's
+	assert.EqualError(t, err, "GetObject bucket/backups/b1/b1.tar.gz: connection reset by peer")
+	assert.True(t, errors.Is(err, cause))
+	// as well as the kind it was classified as, and no other
+	assert.True(t, errors.Is(err, v2.ErrTransient))
+	assert.False(t, errors.Is(err, v2.ErrAccessDenied))
+	assert.False(t, v2.IsObjectNotFound(err))
+
+	var opErr *v2.OperationError
+	require.True(t, errors.As(fmt.Errorf("error downloading backup: %w", err), &opErr))
+	assert.Equal(t, "GetObject", opErr.Op)
+	assert.Equal(t, "backups/b1/b1.tar.gz", opErr.Key)
+
+	// errors which aren't classified are only what they wrap
+	err = &v2.OperationError{Op: "GetObjectInfo", Err: v2.ErrObjectNotFound}
+	assert.True(t, v2.IsObjectNotFound(err))
+	assert.False(t, errors.Is(err, v2.ErrTransient))
+
+	// operations on a bucket, or on no object at all, only say what they can
+	assert.EqualError(t, &v2.OperationError{Op: "ListObjects", Bucket: "bucket", Err: cause}, "ListObjects bucket: connection reset by peer")
+	assert.EqualError(t, &v2.OperationError{Op: "Init", Err: cause}, "Init: connection reset by peer")
+}
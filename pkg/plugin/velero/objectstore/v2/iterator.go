@@ -0,0 +1,73 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"context"
+	"io"
+)
+
+// ObjectIterator returns the keys of a listing of objects a page at a time.
+type ObjectIterator interface {
+	// Next returns the keys of the next page of objects. It returns io.EOF once every page
+	// has been returned.
+	Next(ctx context.Context) ([]string, error)
+}
+
+// RestartListingError is returned by ObjectIterator.Next when the listing can't be continued,
+// e.g. because the plugin serving it was restarted. The keys returned so far are still valid,
+// but the listing must be started over with a new ObjectIterator to get the rest.
+type RestartListingError struct {
+	// Err is why the listing can't be continued.
+	Err error
+}
+
+func (e *RestartListingError) Error() string {
+	return "listing must be restarted: " + e.Err.Error()
+}
+
+func (e *RestartListingError) Unwrap() error {
+	return e.Err
+}
+
+// NewSliceObjectIterator returns an ObjectIterator which returns keys pageSize at a time, for
+// object stores which can't list objects a page at a time.
+func NewSliceObjectIterator(keys []string, pageSize int) ObjectIterator {
+	return &sliceObjectIterator{keys: keys, pageSize: pageSize}
+}
+
+type sliceObjectIterator struct {
+	keys     []string
+	pageSize int
+}
+
+func (it *sliceObjectIterator) Next(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(it.keys) == 0 {
+		return nil, io.EOF
+	}
+
+	n := it.pageSize
+	if n <= 0 || n > len(it.keys) {
+		n = len(it.keys)
+	}
+	page := it.keys[:n:n]
+	it.keys = it.keys[n:]
+	return page, nil
+}
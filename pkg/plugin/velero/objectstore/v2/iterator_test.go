@@ -0,0 +1,61 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+func TestSliceObjectIterator(t *testing.T) {
+	it := v2.NewSliceObjectIterator([]string{"a", "b", "c", "d", "e"}, 2)
+
+	var pages [][]string
+	for {
+		page, err := it.Next(context.Background())
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		pages = append(pages, page)
+	}
+	assert.Equal(t, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}, pages)
+
+	// an empty listing has no pages
+	_, err := v2.NewSliceObjectIterator(nil, 2).Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = v2.NewSliceObjectIterator([]string{"a"}, 2).Next(ctx)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestRestartListingError(t *testing.T) {
+	cause := errors.New("plugin restarted")
+	var err error = &v2.RestartListingError{Err: cause}
+
+	assert.EqualError(t, err, "listing must be restarted: plugin restarted")
+	assert.True(t, errors.Is(err, cause))
+}
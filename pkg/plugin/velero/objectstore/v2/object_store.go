@@ -0,0 +1,319 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+)
+
+// ReplicationStatus is the state of the cross-region replication of an object.
+type ReplicationStatus string
+
+const (
+	ReplicationStatusCompleted ReplicationStatus = "COMPLETED"
+	ReplicationStatusPending   ReplicationStatus = "PENDING"
+	ReplicationStatusFailed    ReplicationStatus = "FAILED"
+)
+
+// HTTP methods pre-signed URLs can be created for.
+const (
+	SignedURLMethodGet  = "GET"
+	SignedURLMethodPut  = "PUT"
+	SignedURLMethodHead = "HEAD"
+)
+
+// SignedURLOptions holds the HTTP method a pre-signed URL is created for, and optional overrides for
+// the response returned when it's fetched.
+type SignedURLOptions struct {
+	// Method is the HTTP method the URL may be used with: SignedURLMethodGet, SignedURLMethodPut or
+	// SignedURLMethodHead. It defaults to SignedURLMethodGet.
+	Method string
+	// ResponseContentDisposition overrides the Content-Disposition header of the response, e.g.
+	// `attachment; filename="backup-logs.gz"`, so that downloads get a human-friendly file name.
+	ResponseContentDisposition string
+	// ResponseContentType overrides the Content-Type header of the response.
+	ResponseContentType string
+	// ResponseHeaders overrides other headers of the response, by name. The fields above take
+	// precedence over the same headers in ResponseHeaders.
+	ResponseHeaders map[string]string
+}
+
+// HTTPMethod returns the HTTP method the URL is created for, defaulting to SignedURLMethodGet.
+func (o SignedURLOptions) HTTPMethod() string {
+	if o.Method == "" {
+		return SignedURLMethodGet
+	}
+	return o.Method
+}
+
+// Validate returns an error if o's method isn't one pre-signed URLs can be created for.
+func (o SignedURLOptions) Validate() error {
+	switch o.HTTPMethod() {
+	case SignedURLMethodGet, SignedURLMethodPut, SignedURLMethodHead:
+		return nil
+	default:
+		return fmt.Errorf("unsupported signed URL method %q", o.Method)
+	}
+}
+
+// ResponseHeaderOverrides returns every response header o overrides, by name.
+func (o SignedURLOptions) ResponseHeaderOverrides() map[string]string {
+	headers := make(map[string]string, len(o.ResponseHeaders)+2)
+	for name, value := range o.ResponseHeaders {
+		headers[name] = value
+	}
+	if o.ResponseContentDisposition != "" {
+		headers["Content-Disposition"] = o.ResponseContentDisposition
+	}
+	if o.ResponseContentType != "" {
+		headers["Content-Type"] = o.ResponseContentType
+	}
+	return headers
+}
+
+// ObjectInfo holds the metadata of an object in object storage.
+type ObjectInfo struct {
+	// Size is the size of the object in bytes.
+	Size int64
+	// LastModified is the time the object was last written.
+	LastModified time.Time
+	// StorageClass is the provider-specific storage class or tier the object is stored in,
+	// e.g. "STANDARD" or "GLACIER". It is empty if the provider does not report one.
+	StorageClass string
+	// ETag is the entity tag of the object's content as reported by the object store. It is
+	// empty if the object store does not report one.
+	ETag string
+}
+
+// ObjectVersion describes a version of an object in a versioned bucket.
+type ObjectVersion struct {
+	// Key is the object's key.
+	Key string
+	// VersionID identifies this version of the object.
+	VersionID string
+	// IsLatest indicates whether this is the current version of the object.
+	IsLatest bool
+	// IsDeleteMarker indicates whether this version is a delete marker rather than data.
+	IsDeleteMarker bool
+	// LastModified is the time this version was written.
+	LastModified time.Time
+}
+
+// ChecksumMetadataKey is the metadata key under which the hex-encoded SHA-256 digest of an
+// object's content is stored.
+const ChecksumMetadataKey = "x-velero-sha256"
+
+// CompletedPart identifies a part uploaded as part of a multipart upload.
+type CompletedPart struct {
+	// PartNumber is the 1-based position of the part in the object.
+	PartNumber int
+	// ETag is the identifier the object store returned when the part was uploaded.
+	ETag string
+}
+
+// DeleteError reports why the object with Key couldn't be deleted by DeleteObjectsV2.
+type DeleteError struct {
+	// Key is the key of the object which couldn't be deleted.
+	Key string
+	// Err is why it couldn't be deleted.
+	Err error
+}
+
+func (e DeleteError) Error() string {
+	return fmt.Sprintf("error deleting object %s: %v", e.Key, e.Err)
+}
+
+// StorageUsage is how much storage the objects under a prefix take up.
+type StorageUsage struct {
+	// ObjectCount is the number of objects.
+	ObjectCount int64
+	// TotalBytes is the sum of the sizes of the objects in bytes.
+	TotalBytes int64
+}
+
+// ObjectStore exposes basic object-storage operations required
+// by Velero.
+type ObjectStore interface {
+	// Import the v1 methods, which are kept as-is so that v1 callers keep working.
+	velero.ObjectStore
+
+	// InitV2 prepares the ObjectStore for usage using the provided map of
+	// configuration key-value pairs. It returns an error if the ObjectStore
+	// cannot be initialized from the provided config.
+	InitV2(ctx context.Context, config map[string]string) error
+
+	// PutObjectV2 creates a new object using the data in body within the specified
+	// object storage bucket with the given key.
+	PutObjectV2(ctx context.Context, bucket, key string, body io.Reader) error
+
+	// ObjectExistsV2 checks if there is an object with the given key in the object storage bucket.
+	ObjectExistsV2(ctx context.Context, bucket, key string) (bool, error)
+
+	// GetObjectV2 retrieves the object with the given key from the specified
+	// bucket in object storage.
+	GetObjectV2(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+
+	// ListCommonPrefixesV2 gets a list of all object key prefixes that start with
+	// the specified prefix and stop at the next instance of the provided delimiter.
+	ListCommonPrefixesV2(ctx context.Context, bucket, prefix, delimiter string) ([]string, error)
+
+	// ListObjectsV2 gets a list of all keys in the specified bucket
+	// that have the given prefix.
+	ListObjectsV2(ctx context.Context, bucket, prefix string) ([]string, error)
+
+	// DeleteObjectV2 removes the object with the specified key from the given
+	// bucket.
+	DeleteObjectV2(ctx context.Context, bucket, key string) error
+
+	// CreateSignedURLV2 creates a pre-signed URL for the given bucket and key that expires after ttl,
+	// for the HTTP method in opts, binding the response header overrides in opts into the
+	// signature. Object stores return ErrUnsupported for methods they can't sign URLs for.
+	CreateSignedURLV2(ctx context.Context, bucket, key string, ttl time.Duration, opts SignedURLOptions) (string, error)
+
+	// CreateSignedURLs creates pre-signed URLs for the given keys in bucket that expire after ttl,
+	// returning them keyed by object key. Object stores which sign locally can do this without a
+	// round-trip per key.
+	CreateSignedURLs(ctx context.Context, bucket string, keys []string, ttl time.Duration) (map[string]string, error)
+
+	// ObjectsExist checks, for each of the given keys, whether an object with that key exists in
+	// bucket, returning the results keyed by object key.
+	ObjectsExist(ctx context.Context, bucket string, keys []string) (map[string]bool, error)
+
+	// GetReplicationStatus returns the cross-region replication status of the object with the
+	// given key. Object stores which do not expose replication status return ErrUnsupported.
+	GetReplicationStatus(ctx context.Context, bucket, key string) (ReplicationStatus, error)
+
+	// RestoreArchivedObject starts the retrieval of an archived object so that it can
+	// later be read with GetObject. tier is the provider-specific retrieval tier, e.g.
+	// "Expedited" or "Bulk". Object stores without archive tiers return ErrUnsupported.
+	RestoreArchivedObject(ctx context.Context, bucket, key, tier string) error
+
+	// AppendObject appends the data in body to the object with the given key, creating
+	// the object if it does not exist. Object stores without append semantics return
+	// ErrUnsupported, in which case callers may fall back to reading, modifying and
+	// rewriting the whole object.
+	AppendObject(ctx context.Context, bucket, key string, body io.Reader) error
+
+	// GetBucketVersioning returns whether versioning is enabled for bucket. Object stores
+	// without versioning support return ErrUnsupported.
+	GetBucketVersioning(ctx context.Context, bucket string) (bool, error)
+
+	// ListObjectVersions lists all versions, including delete markers, of the objects in
+	// bucket whose keys begin with prefix. Object stores without versioning support return
+	// ErrUnsupported.
+	ListObjectVersions(ctx context.Context, bucket, prefix string) ([]ObjectVersion, error)
+
+	// DeleteObjectVersion permanently deletes the given version of the object with the given
+	// key. Object stores without versioning support return ErrUnsupported.
+	DeleteObjectVersion(ctx context.Context, bucket, key, versionID string) error
+
+	// GetObjectIfModifiedSince retrieves the object with the given key if it has been
+	// modified after since. If it hasn't, it returns a nil reader and false, without
+	// downloading the object. Object stores without conditional reads should compare
+	// since against the object's last-modified time as returned by GetObjectInfoV2.
+	GetObjectIfModifiedSince(ctx context.Context, bucket, key string, since time.Time) (io.ReadCloser, bool, error)
+
+	// ListObjectsByTag lists the keys of the objects in bucket carrying every one of the
+	// given tags, using a server-side query. Object stores which can't query by tag return
+	// ErrUnsupported, in which case callers may fall back to filtering client-side.
+	ListObjectsByTag(ctx context.Context, bucket string, tags map[string]string) ([]string, error)
+
+	// PutObjectWithMetadata creates a new object like PutObjectV2, storing metadata as the
+	// object's custom metadata. Object stores without custom metadata return ErrUnsupported.
+	PutObjectWithMetadata(ctx context.Context, bucket, key string, body io.Reader, metadata map[string]string) error
+
+	// GetObjectChecksum returns the digest stored under ChecksumMetadataKey in the metadata
+	// of the object with the given key, or an empty string if there is none. Object stores
+	// without custom metadata return ErrUnsupported.
+	GetObjectChecksum(ctx context.Context, bucket, key string) (string, error)
+
+	// ListObjectsInfo gets the metadata of all objects in the specified bucket that have
+	// the given prefix, keyed by object key, in a single listing. Object stores which
+	// can't report metadata when listing return ErrUnsupported.
+	ListObjectsInfo(ctx context.Context, bucket, prefix string) (map[string]ObjectInfo, error)
+
+	// MoveObject renames the object with the key srcKey to dstKey within bucket, atomically,
+	// so that the object is never visible under both keys or neither. Object stores without
+	// server-side renames return ErrUnsupported.
+	MoveObject(ctx context.Context, bucket, srcKey, dstKey string) error
+
+	// CreateMultipartUpload starts a multipart upload of an object with the given key to
+	// bucket and returns its ID. The object isn't visible until the upload is completed.
+	// Object stores without multipart uploads return ErrUnsupported.
+	CreateMultipartUpload(ctx context.Context, bucket, key string) (string, error)
+
+	// UploadPart uploads body as the part numbered partNumber of the multipart upload
+	// uploadID, and returns the part's ETag. Parts may be uploaded concurrently and in any
+	// order.
+	UploadPart(ctx context.Context, bucket, key, uploadID string, partNumber int, body io.Reader) (string, error)
+
+	// CompleteMultipartUpload assembles the object from parts, which are sorted by part
+	// number, and makes it visible under its key.
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []CompletedPart) error
+
+	// AbortMultipartUpload cancels the multipart upload uploadID and discards the parts
+	// uploaded for it.
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+
+	// DeleteObjectsV2 removes the objects with the given keys from bucket in as few calls as
+	// the object store allows, e.g. with S3's DeleteObjects. It returns a DeleteError for each
+	// object which couldn't be deleted, and an error only if the call failed as a whole. Object
+	// stores without bulk deletes return ErrUnsupported.
+	DeleteObjectsV2(ctx context.Context, bucket string, keys []string) ([]DeleteError, error)
+
+	// GetObjectInfoV2 returns the size, last-modified time and ETag of the object with the
+	// given key without retrieving its content. It returns ErrObjectNotFound if there is no object
+	// with the given key.
+	GetObjectInfoV2(ctx context.Context, bucket, key string) (ObjectInfo, error)
+
+	// GetObjectRangeV2 retrieves length bytes of the object with the given key starting at
+	// offset, or the rest of the object if length is negative. It returns
+	// ErrRangeNotSatisfiable if offset is greater than the object's size. Object stores
+	// without ranged reads return ErrUnsupported, in which case callers may fall back to
+	// ReadRange.
+	GetObjectRangeV2(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error)
+
+	// CopyObjectV2 copies the object with the key srcKey in srcBucket to dstKey in dstBucket
+	// without it passing through Velero, e.g. with a server-side copy. Object stores which
+	// can't copy objects that way return ErrCopyNotSupported.
+	CopyObjectV2(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
+
+	// ListObjectsPaged lists the keys in bucket with the given prefix like ListObjectsV2, but
+	// returns an ObjectIterator which returns them pageSize at a time, so that large listings
+	// don't have to be held in memory at once. Object stores which can't list objects a page
+	// at a time may return ErrUnsupported, in which case callers may fall back to
+	// ListObjectsV2 and NewSliceObjectIterator.
+	ListObjectsPaged(ctx context.Context, bucket, prefix string, pageSize int) (ObjectIterator, error)
+
+	// PutObjectWithChecksumV2 writes body to the object with the given key like PutObjectV2,
+	// computing its checksum with algorithm and sending it along so that the object store can
+	// reject a body corrupted in transit. It returns the hex-encoded checksum, which callers may
+	// verify the object against when it's read with NewVerifyingReader. Object stores which
+	// can't compute checksums return ErrUnsupported.
+	PutObjectWithChecksumV2(ctx context.Context, bucket, key string, body io.Reader, algorithm ChecksumAlgorithm) (string, error)
+
+	// GetStorageUsageV2 returns the number and the total size of the objects in bucket whose
+	// keys start with prefix, e.g. to find out how much storage a backup takes up. Object stores
+	// which can't compute it without listing every object return ErrUnsupported, so that callers
+	// decide whether listing is worth it, e.g. by using GetPrefixSize.
+	GetStorageUsageV2(ctx context.Context, bucket, prefix string) (StorageUsage, error)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+func TestSignedURLOptions(t *testing.T) {
+	var opts v2.SignedURLOptions
+	assert.Equal(t, v2.SignedURLMethodGet, opts.HTTPMethod())
+	assert.NoError(t, opts.Validate())
+	assert.Empty(t, opts.ResponseHeaderOverrides())
+
+	opts = v2.SignedURLOptions{
+		Method:                     v2.SignedURLMethodPut,
+		ResponseContentDisposition: `attachment; filename="backup.tar.gz"`,
+		ResponseHeaders: map[string]string{
+			"Content-Disposition": "inline",
+			"Cache-Control":       "no-store",
+		},
+	}
+	assert.NoError(t, opts.Validate())
+	assert.Equal(t, map[string]string{
+		"Content-Disposition": `attachment; filename="backup.tar.gz"`,
+		"Cache-Control":       "no-store",
+	}, opts.ResponseHeaderOverrides())
+
+	assert.EqualError(t, v2.SignedURLOptions{Method: "POST"}.Validate(), `unsupported signed URL method "POST"`)
+}
@@ -0,0 +1,54 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// ReadRange returns a reader for length bytes of body starting at offset, or for the rest of
+// body if length is negative, for object stores which don't support GetObjectRangeV2. The bytes
+// before offset are read and discarded. If body ends before offset, it's closed and
+// ErrRangeNotSatisfiable is returned. Closing the returned reader closes body.
+func ReadRange(body io.ReadCloser, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		body.Close()
+		return nil, errors.Errorf("invalid range offset %d", offset)
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, body, offset); err != nil {
+		body.Close()
+		if err == io.EOF {
+			return nil, ErrRangeNotSatisfiable
+		}
+		return nil, errors.Wrap(err, "error skipping to the start of the range")
+	}
+
+	if length < 0 {
+		return body, nil
+	}
+	return &rangeReadCloser{Reader: io.LimitReader(body, length), Closer: body}, nil
+}
+
+// rangeReadCloser reads a range of the object Closer reads.
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,85 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+func TestReadRange(t *testing.T) {
+	tests := []struct {
+		name        string
+		offset      int64
+		length      int64
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "a range within the object",
+			offset:   7,
+			length:   3,
+			expected: "con",
+		},
+		{
+			name:     "a negative length reads to the end of the object",
+			offset:   7,
+			length:   -1,
+			expected: "contents",
+		},
+		{
+			name:     "a range past the end of the object is cut short",
+			offset:   11,
+			length:   100,
+			expected: "ents",
+		},
+		{
+			name:     "a range starting at the end of the object is empty",
+			offset:   15,
+			length:   -1,
+			expected: "",
+		},
+		{
+			name:        "a range starting beyond the end of the object can't be satisfied",
+			offset:      16,
+			length:      -1,
+			expectedErr: v2.ErrRangeNotSatisfiable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rc, err := v2.ReadRange(ioutil.NopCloser(strings.NewReader("backup contents")), tc.offset, tc.length)
+			if tc.expectedErr != nil {
+				assert.True(t, errors.Is(err, tc.expectedErr))
+				return
+			}
+			require.NoError(t, err)
+			defer rc.Close()
+			contents, err := ioutil.ReadAll(rc)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, string(contents))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// GetPrefixSize returns the total size in bytes and the number of the objects in bucket with the
+// given prefix, using the object store's GetStorageUsageV2 where it's supported and falling back
+// to SumObjectSizes otherwise.
+func GetPrefixSize(ctx context.Context, store ObjectStore, bucket, prefix string) (int64, int64, error) {
+	usage, err := store.GetStorageUsageV2(ctx, bucket, prefix)
+	if errors.Is(err, ErrUnsupported) {
+		return SumObjectSizes(ctx, store, bucket, prefix)
+	}
+	return usage.TotalBytes, usage.ObjectCount, err
+}
+
+// SumObjectSizes returns the total size in bytes and the number of the objects in bucket with
+// the given prefix by listing them, for object stores which don't support GetStorageUsageV2. It uses
+// a single ListObjectsInfo call, falling back to stating each listed object if the object store
+// doesn't support it.
+func SumObjectSizes(ctx context.Context, store ObjectStore, bucket, prefix string) (int64, int64, error) {
+	infos, err := store.ListObjectsInfo(ctx, bucket, prefix)
+	if errors.Is(err, ErrUnsupported) {
+		infos, err = statObjects(ctx, store, bucket, prefix)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var totalBytes int64
+	for _, info := range infos {
+		totalBytes += info.Size
+	}
+	return totalBytes, int64(len(infos)), nil
+}
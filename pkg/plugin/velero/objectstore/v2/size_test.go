@@ -0,0 +1,92 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v2_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2/mocks"
+)
+
+func TestGetPrefixSize(t *testing.T) {
+	t.Run("uses GetStorageUsageV2", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		defer store.AssertExpectations(t)
+		store.On("GetStorageUsageV2", context.Background(), "bucket", "backups/").Return(v2.StorageUsage{ObjectCount: 2, TotalBytes: 350}, nil)
+
+		totalBytes, objectCount, err := v2.GetPrefixSize(context.Background(), store, "bucket", "backups/")
+		require.NoError(t, err)
+		assert.Equal(t, int64(350), totalBytes)
+		assert.Equal(t, int64(2), objectCount)
+	})
+
+	t.Run("falls back to listing", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		defer store.AssertExpectations(t)
+		store.On("GetStorageUsageV2", context.Background(), "bucket", "backups/").Return(v2.StorageUsage{}, v2.ErrUnsupported)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "backups/").Return(map[string]v2.ObjectInfo{"backups/b1/b1.tar.gz": {Size: 100}}, nil)
+
+		totalBytes, objectCount, err := v2.GetPrefixSize(context.Background(), store, "bucket", "backups/")
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), totalBytes)
+		assert.Equal(t, int64(1), objectCount)
+	})
+}
+
+func TestSumObjectSizes(t *testing.T) {
+	t.Run("uses ListObjectsInfo", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		defer store.AssertExpectations(t)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "backups/").Return(map[string]v2.ObjectInfo{
+			"backups/b1/b1.tar.gz": {Size: 100},
+			"backups/b2/b2.tar.gz": {Size: 250},
+		}, nil)
+
+		totalBytes, objectCount, err := v2.SumObjectSizes(context.Background(), store, "bucket", "backups/")
+		require.NoError(t, err)
+		assert.Equal(t, int64(350), totalBytes)
+		assert.Equal(t, int64(2), objectCount)
+	})
+
+	t.Run("falls back to GetObjectInfoV2", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		defer store.AssertExpectations(t)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "backups/").Return(nil, v2.ErrUnsupported)
+		store.On("ListObjectsV2", context.Background(), "bucket", "backups/").Return([]string{"backups/b1/b1.tar.gz"}, nil)
+		store.On("GetObjectInfoV2", context.Background(), "bucket", "backups/b1/b1.tar.gz").Return(v2.ObjectInfo{Size: 100}, nil)
+
+		totalBytes, objectCount, err := v2.SumObjectSizes(context.Background(), store, "bucket", "backups/")
+		require.NoError(t, err)
+		assert.Equal(t, int64(100), totalBytes)
+		assert.Equal(t, int64(1), objectCount)
+	})
+
+	t.Run("empty prefix", func(t *testing.T) {
+		store := new(mocks.ObjectStore)
+		store.On("ListObjectsInfo", context.Background(), "bucket", "restores/").Return(map[string]v2.ObjectInfo{}, nil)
+
+		totalBytes, objectCount, err := v2.SumObjectSizes(context.Background(), store, "bucket", "restores/")
+		require.NoError(t, err)
+		assert.Zero(t, totalBytes)
+		assert.Zero(t, objectCount)
+	})
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"path"
 	"sort"
 	"testing"
 	"time"
@@ -719,6 +720,35 @@ func TestRestoreResourcePriorities(t *testing.T) {
 	}
 }
 
+// TestRestoreOrderOfDependentResources restores a backup read from an object store and verifies that resources
+// are created after the resources they depend on.
+func TestRestoreOrderOfDependentResources(t *testing.T) {
+	h := newHarness(t)
+	h.restorer.resourcePriorities = []string{"customresourcedefinitions", "namespaces", "persistentvolumes", "persistentvolumeclaims", "serviceaccounts", "pods"}
+
+	backup := defaultBackup().Result()
+	store := test.NewFakeObjectStore("bucket")
+	tarball := test.NewTarWriter(t).
+		AddItems("pods", builder.ForPod("ns-1", "pod-1").Result()).
+		AddItems("volumesnapshotlocations.velero.io", builder.ForVolumeSnapshotLocation("ns-1", "vsl-1").Result()).
+		AddItems("persistentvolumeclaims", builder.ForPersistentVolumeClaim("ns-1", "pvc-1").Result()).
+		AddItems("serviceaccounts", builder.ForServiceAccount("ns-1", "sa-1").Result()).
+		AddItems("persistentvolumes", builder.ForPersistentVolume("pv-1").Result()).
+		AddItems("customresourcedefinitions.apiextensions.k8s.io", builder.ForCustomResourceDefinitionV1Beta1("volumesnapshotlocations.velero.io").Result()).
+		Done()
+	require.NoError(t, store.PutObject("bucket", backupTarballKey(backup.Name), tarball))
+
+	h.restoreAndAssertOrder(t, store, "bucket", defaultRestore().Result(), backup,
+		[]restoreOrderConstraint{
+			{before: "customresourcedefinitions.apiextensions.k8s.io", after: "volumesnapshotlocations.velero.io"},
+			{before: "persistentvolumes", after: "persistentvolumeclaims"},
+			{before: "persistentvolumeclaims", after: "pods"},
+			{before: "serviceaccounts", after: "pods"},
+		},
+		test.CRDs(), test.VSLs(), test.PVs(), test.PVCs(), test.ServiceAccounts(), test.Pods(),
+	)
+}
+
 // TestInvalidTarballContents runs restores for tarballs that are invalid in some way, and
 // verifies that the set of items created in the API and the errors returned are correct.
 // Validation is done by looking at the namespaces/names of the items in the API and the
@@ -1383,6 +1413,36 @@ func TestRestoreActionModifications(t *testing.T) {
 	}
 }
 
+// TestRestoreActionsWithBackupFromObjectStore runs a restore of a backup read from an object store with
+// restore item actions, and verifies the items the actions modified.
+func TestRestoreActionsWithBackupFromObjectStore(t *testing.T) {
+	h := newHarness(t)
+
+	backup := defaultBackup().Result()
+	store := test.NewFakeObjectStore("bucket")
+	tarball := test.NewTarWriter(t).
+		AddItems("pods", builder.ForPod("ns-1", "pod-1").Result(), builder.ForPod("ns-2", "pod-2").Result()).
+		Done()
+	require.NoError(t, store.PutObject("bucket", backupTarballKey(backup.Name), tarball))
+
+	actions := []velero.RestoreItemAction{
+		&pluggableAction{
+			selector: velero.ResourceSelector{IncludedNamespaces: []string{"ns-1"}},
+			executeFunc: func(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+				obj := input.Item.(*unstructured.Unstructured).DeepCopy()
+				obj.SetAnnotations(map[string]string{"modified-by": "action"})
+				return velero.NewRestoreItemActionExecuteOutput(obj), nil
+			},
+		},
+	}
+
+	restored := h.restoreFromObjectStore(t, store, "bucket", defaultRestore().Result(), backup, actions, test.Pods())
+
+	require.Len(t, restored, 2)
+	assert.Equal(t, map[string]string{"modified-by": "action"}, restored["pods/ns-1/pod-1"].GetAnnotations())
+	assert.Empty(t, restored["pods/ns-2/pod-2"].GetAnnotations())
+}
+
 // TestRestoreActionAdditionalItems runs restores with restore item actions that return additional items
 // to be restored, and verifies that that the correct set of items is created in the API. Verification is
 // done by looking at the namespaces/names of the items in the API; contents are not checked.
@@ -2974,6 +3034,126 @@ func newHarness(t *testing.T) *harness {
 	}
 }
 
+// backupTarballKey returns the key of the named backup's contents in an object store without a prefix.
+func backupTarballKey(backupName string) string {
+	return path.Join("backups", backupName, backupName+".tar.gz")
+}
+
+// restoreFromObjectStore restores the backup read from bucket in store with the given restore item actions,
+// asserting that it completes without warnings or errors. It returns the items of the given resources that
+// exist afterwards, keyed by "<resource>/<namespace>/<name>", so tests can assert on what the actions did
+// without a real object store or cluster.
+func (h *harness) restoreFromObjectStore(
+	t *testing.T,
+	store *test.FakeObjectStore,
+	bucket string,
+	restore *velerov1api.Restore,
+	backup *velerov1api.Backup,
+	actions []velero.RestoreItemAction,
+	resources ...*test.APIResource,
+) map[string]*unstructured.Unstructured {
+	t.Helper()
+
+	for _, r := range resources {
+		h.AddItems(t, r)
+	}
+
+	tarball, err := store.GetObject(bucket, backupTarballKey(backup.Name))
+	require.NoError(t, err)
+	defer tarball.Close()
+
+	data := Request{
+		Log:          h.log,
+		Restore:      restore,
+		Backup:       backup,
+		BackupReader: tarball,
+	}
+	warnings, errs := h.restorer.Restore(
+		data,
+		actions,
+		nil, // snapshot location lister
+		nil, // volume snapshotter getter
+	)
+	assertEmptyResults(t, warnings, errs)
+
+	restored := make(map[string]*unstructured.Unstructured)
+	for _, r := range resources {
+		list, err := h.DynamicClient.Resource(r.GVR()).List(context.TODO(), metav1.ListOptions{})
+		require.NoError(t, err)
+		for i := range list.Items {
+			item := &list.Items[i]
+			restored[path.Join(r.Name, item.GetNamespace(), item.GetName())] = item
+		}
+	}
+	return restored
+}
+
+// restoreOrderConstraint requires that every item of the resource before is created before any item of the
+// resource after, e.g. CRDs before the custom resources they define. Resources are group resources as they're
+// recorded by createRecorder, e.g. "deployments.apps".
+type restoreOrderConstraint struct {
+	before string
+	after  string
+}
+
+// restoreAndAssertOrder restores the backup read from bucket in store like restoreFromObjectStore, recording the
+// order items are created in, and asserts that it satisfies each of constraints. resources are created in the
+// API before the restore is started, so they should not contain items. Restored CRDs are marked as established,
+// since the fake API server has no controller to do so and the restore waits for them.
+func (h *harness) restoreAndAssertOrder(
+	t *testing.T,
+	store *test.FakeObjectStore,
+	bucket string,
+	restore *velerov1api.Restore,
+	backup *velerov1api.Backup,
+	constraints []restoreOrderConstraint,
+	resources ...*test.APIResource,
+) {
+	t.Helper()
+
+	h.DynamicClient.PrependReactor("create", "customresourcedefinitions", func(action kubetesting.Action) (bool, runtime.Object, error) {
+		crd, ok := action.(kubetesting.CreateAction).GetObject().(*unstructured.Unstructured)
+		if !ok {
+			return false, nil, nil
+		}
+		conditions := []interface{}{
+			map[string]interface{}{"type": "Established", "status": "True"},
+			map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+		}
+		return false, nil, unstructured.SetNestedSlice(crd.Object, conditions, "status", "conditions")
+	})
+	recorder := &createRecorder{t: t}
+	h.DynamicClient.PrependReactor("create", "*", recorder.reactor())
+
+	h.restoreFromObjectStore(t, store, bucket, restore, backup, nil, resources...)
+
+	assertCreatedInOrder(t, recorder.resources, constraints)
+}
+
+// assertCreatedInOrder asserts that createdResources, in the order they were created, satisfy each of
+// constraints. Both resources of a constraint must have been created.
+func assertCreatedInOrder(t *testing.T, createdResources []resourceID, constraints []restoreOrderConstraint) {
+	t.Helper()
+
+	first := make(map[string]int)
+	last := make(map[string]int)
+	for i, r := range createdResources {
+		if _, ok := first[r.groupResource]; !ok {
+			first[r.groupResource] = i
+		}
+		last[r.groupResource] = i
+	}
+
+	for _, c := range constraints {
+		lastBefore, beforeCreated := last[c.before]
+		firstAfter, afterCreated := first[c.after]
+		if !assert.True(t, beforeCreated, "no %s were restored", c.before) || !assert.True(t, afterCreated, "no %s were restored", c.after) {
+			continue
+		}
+		assert.Less(t, lastBefore, firstAfter, "%s were restored before %s were: %v", c.after, c.before, createdResources)
+	}
+}
+
 func (h *harness) AddItems(t *testing.T, resource *test.APIResource) {
 	t.Helper()
 
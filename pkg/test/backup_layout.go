@@ -0,0 +1,57 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+// VerifyBackupLayout checks that the object store contains each of expectedKeys under the directory of the
+// backup named backupName, e.g. "velero-backup.json" or backupName+".tar.gz". It returns an error listing
+// every key which is missing.
+func VerifyBackupLayout(ctx context.Context, store osv2.ObjectStore, bucket, backupName string, expectedKeys []string) error {
+	backupDir := path.Join("backups", backupName)
+
+	keys := make([]string, 0, len(expectedKeys))
+	for _, key := range expectedKeys {
+		keys = append(keys, path.Join(backupDir, key))
+	}
+
+	exists, err := store.ObjectsExist(ctx, bucket, keys)
+	if err != nil {
+		return errors.Wrapf(err, "error checking the contents of backup %s", backupName)
+	}
+
+	var missing []string
+	for _, key := range keys {
+		if !exists[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return errors.Errorf("backup %s is missing objects in bucket %s: %s", backupName, bucket, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+)
+
+// FakeObjectStore is an in-memory implementation of the ObjectStore plugin interface, safe for concurrent
+// use, for supplying backup data to tests without real storage.
+type FakeObjectStore struct {
+	lock   sync.Mutex
+	Config map[string]string
+	// Latency is how long each PutObject and GetObject call takes, to simulate a remote object store. It must
+	// be set before o is used.
+	Latency   time.Duration
+	buckets   map[string]map[string][]byte
+	bytesRead int64
+}
+
+// NewFakeObjectStore returns a FakeObjectStore containing the given empty buckets.
+func NewFakeObjectStore(buckets ...string) *FakeObjectStore {
+	o := &FakeObjectStore{
+		buckets: make(map[string]map[string][]byte),
+	}
+	for _, bucket := range buckets {
+		o.buckets[bucket] = make(map[string][]byte)
+	}
+	return o
+}
+
+func (o *FakeObjectStore) Init(config map[string]string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.Config = config
+	return nil
+}
+
+func (o *FakeObjectStore) PutObject(bucket, key string, body io.Reader) error {
+	time.Sleep(o.Latency)
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	objects, err := o.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	objects[key] = data
+	return nil
+}
+
+func (o *FakeObjectStore) ObjectExists(bucket, key string) (bool, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	objects, err := o.bucket(bucket)
+	if err != nil {
+		return false, err
+	}
+	_, ok := objects[key]
+	return ok, nil
+}
+
+func (o *FakeObjectStore) GetObject(bucket, key string) (io.ReadCloser, error) {
+	time.Sleep(o.Latency)
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	objects, err := o.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := objects[key]
+	if !ok {
+		return nil, errors.Errorf("key %s not found in bucket %s", key, bucket)
+	}
+	return ioutil.NopCloser(&countingReader{Reader: bytes.NewReader(data), store: o}), nil
+}
+
+func (o *FakeObjectStore) ListCommonPrefixes(bucket, prefix, delimiter string) ([]string, error) {
+	keys, err := o.ListObjects(bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, key := range keys {
+		afterPrefix := key[len(prefix):]
+		delimiterStart := strings.Index(afterPrefix, delimiter)
+		if delimiterStart == -1 {
+			continue
+		}
+		fullPrefix := prefix + afterPrefix[0:delimiterStart] + delimiter
+		if !seen[fullPrefix] {
+			seen[fullPrefix] = true
+			prefixes = append(prefixes, fullPrefix)
+		}
+	}
+	return prefixes, nil
+}
+
+// ListObjects returns the keys in bucket beginning with prefix, sorted.
+func (o *FakeObjectStore) ListObjects(bucket, prefix string) ([]string, error) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	objects, err := o.bucket(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key := range objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (o *FakeObjectStore) DeleteObject(bucket, key string) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	objects, err := o.bucket(bucket)
+	if err != nil {
+		return err
+	}
+	delete(objects, key)
+	return nil
+}
+
+func (o *FakeObjectStore) CreateSignedURL(bucket, key string, ttl time.Duration) (string, error) {
+	if exists, err := o.ObjectExists(bucket, key); err != nil {
+		return "", err
+	} else if !exists {
+		return "", errors.Errorf("key %s not found in bucket %s", key, bucket)
+	}
+	return "https://fake-object-store/" + bucket + "/" + key, nil
+}
+
+// BytesRead returns the number of bytes of object content read from o so far.
+func (o *FakeObjectStore) BytesRead() int64 {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	return o.bytesRead
+}
+
+// countingReader adds the bytes read from an object to the total reported by FakeObjectStore.BytesRead.
+type countingReader struct {
+	io.Reader
+	store *FakeObjectStore
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+
+	c.store.lock.Lock()
+	defer c.store.lock.Unlock()
+	c.store.bytesRead += int64(n)
+
+	return n, err
+}
+
+// V1Only hides every method of objectStore beyond the v1 ObjectStore API, so that tests can simulate a plugin
+// that only supports v1 whatever versions objectStore implements.
+func V1Only(objectStore velero.ObjectStore) velero.ObjectStore {
+	return struct{ velero.ObjectStore }{objectStore}
+}
+
+// bucket returns the objects of the named bucket. The caller must hold o.lock.
+func (o *FakeObjectStore) bucket(name string) (map[string][]byte, error) {
+	objects, ok := o.buckets[name]
+	if !ok {
+		return nil, errors.Errorf("bucket %s not found", name)
+	}
+	return objects, nil
+}
@@ -0,0 +1,195 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+)
+
+// ThroughputOptions configures the workload MeasureThroughput runs against an object store.
+type ThroughputOptions struct {
+	// Bucket is the bucket the objects are written to and read from.
+	Bucket string
+	// Prefix is prepended to the keys of the objects.
+	Prefix string
+	// Objects is how many objects are uploaded and then downloaded.
+	Objects int
+	// ObjectSize is the size of each object in bytes.
+	ObjectSize int
+	// Concurrency is how many uploads or downloads are run at once.
+	Concurrency int
+}
+
+// LatencyPercentiles summarizes the latencies of a set of object store calls.
+type LatencyPercentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+	Max time.Duration
+}
+
+// ThroughputResult holds what MeasureThroughput measured.
+type ThroughputResult struct {
+	UploadBytesPerSecond   float64
+	DownloadBytesPerSecond float64
+	UploadLatency          LatencyPercentiles
+	DownloadLatency        LatencyPercentiles
+}
+
+func (r ThroughputResult) String() string {
+	return fmt.Sprintf("upload %.1f MB/s (p50 %v, p99 %v), download %.1f MB/s (p50 %v, p99 %v)",
+		r.UploadBytesPerSecond/1e6, r.UploadLatency.P50, r.UploadLatency.P99,
+		r.DownloadBytesPerSecond/1e6, r.DownloadLatency.P50, r.DownloadLatency.P99)
+}
+
+// MeasureThroughput uploads opts.Objects objects of opts.ObjectSize bytes to store, then downloads them again,
+// running opts.Concurrency calls at once, and returns the throughput and latencies of each phase. store may be any
+// object store, e.g. a FakeObjectStore with Latency set, a restartable object store wrapping a plugin, or a real
+// one. The objects are left in the bucket.
+func MeasureThroughput(store velero.ObjectStore, opts ThroughputOptions) (ThroughputResult, error) {
+	if opts.Objects <= 0 || opts.ObjectSize < 0 || opts.Concurrency <= 0 {
+		return ThroughputResult{}, errors.Errorf("invalid options %+v", opts)
+	}
+
+	content := bytes.Repeat([]byte{'x'}, opts.ObjectSize)
+	key := func(i int) string { return fmt.Sprintf("%sobject-%d", opts.Prefix, i) }
+
+	uploadElapsed, uploadLatencies, err := runConcurrently(opts.Objects, opts.Concurrency, func(i int) error {
+		return errors.Wrapf(store.PutObject(opts.Bucket, key(i), bytes.NewReader(content)), "error uploading %s", key(i))
+	})
+	if err != nil {
+		return ThroughputResult{}, err
+	}
+
+	downloadElapsed, downloadLatencies, err := runConcurrently(opts.Objects, opts.Concurrency, func(i int) error {
+		rc, err := store.GetObject(opts.Bucket, key(i))
+		if err != nil {
+			return errors.Wrapf(err, "error downloading %s", key(i))
+		}
+		defer rc.Close()
+		n, err := io.Copy(ioutil.Discard, rc)
+		if err != nil {
+			return errors.Wrapf(err, "error downloading %s", key(i))
+		}
+		if n != int64(opts.ObjectSize) {
+			return errors.Errorf("downloaded %d bytes of %s, expected %d", n, key(i), opts.ObjectSize)
+		}
+		return nil
+	})
+	if err != nil {
+		return ThroughputResult{}, err
+	}
+
+	totalBytes := float64(opts.Objects) * float64(opts.ObjectSize)
+	return ThroughputResult{
+		UploadBytesPerSecond:   totalBytes / uploadElapsed.Seconds(),
+		DownloadBytesPerSecond: totalBytes / downloadElapsed.Seconds(),
+		UploadLatency:          latencyPercentiles(uploadLatencies),
+		DownloadLatency:        latencyPercentiles(downloadLatencies),
+	}, nil
+}
+
+// RequireThroughput runs MeasureThroughput and fails t if it fails or if either the upload or the download
+// throughput is below minBytesPerSecond.
+func RequireThroughput(t testing.TB, store velero.ObjectStore, opts ThroughputOptions, minBytesPerSecond float64) ThroughputResult {
+	t.Helper()
+
+	result, err := MeasureThroughput(store, opts)
+	if err != nil {
+		t.Fatalf("error measuring object store throughput: %v", err)
+	}
+	t.Logf("object store throughput: %s", result)
+	if result.UploadBytesPerSecond < minBytesPerSecond {
+		t.Errorf("upload throughput %.0f B/s is below %.0f B/s", result.UploadBytesPerSecond, minBytesPerSecond)
+	}
+	if result.DownloadBytesPerSecond < minBytesPerSecond {
+		t.Errorf("download throughput %.0f B/s is below %.0f B/s", result.DownloadBytesPerSecond, minBytesPerSecond)
+	}
+	return result
+}
+
+// runConcurrently calls fn for each of 0 to n-1, at most concurrency at a time, and returns how long they took
+// altogether and the latency of each call. It stops starting calls once one fails, and returns the first error.
+func runConcurrently(n, concurrency int, fn func(i int) error) (time.Duration, []time.Duration, error) {
+	var (
+		wg        sync.WaitGroup
+		lock      sync.Mutex
+		latencies = make([]time.Duration, 0, n)
+		firstErr  error
+	)
+	sem := make(chan struct{}, concurrency)
+
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		lock.Lock()
+		failed := firstErr != nil
+		lock.Unlock()
+		if failed {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			callStart := time.Now()
+			err := fn(i)
+			latency := time.Since(callStart)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			latencies = append(latencies, latency)
+		}(i)
+	}
+	wg.Wait()
+
+	return time.Since(start), latencies, firstErr
+}
+
+// latencyPercentiles returns the percentiles of latencies, using the nearest-rank method.
+func latencyPercentiles(latencies []time.Duration) LatencyPercentiles {
+	if len(latencies) == 0 {
+		return LatencyPercentiles{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	percentile := func(p int) time.Duration {
+		rank := (p*len(latencies) + 99) / 100
+		return latencies[rank-1]
+	}
+	return LatencyPercentiles{
+		P50: percentile(50),
+		P90: percentile(90),
+		P99: percentile(99),
+		Max: latencies[len(latencies)-1],
+	}
+}
@@ -23,23 +23,30 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/meta"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
-func NewPeriodicalEnqueueSource(logger logrus.FieldLogger, client client.Client, objList client.ObjectList, period time.Duration) *PeriodicalEnqueueSource {
+// NewPeriodicalEnqueueSource returns a PeriodicalEnqueueSource which enqueues the resources of objList's type every
+// period. listOpts, e.g. client.InNamespace or client.MatchingLabels, restrict the resources which are enqueued.
+func NewPeriodicalEnqueueSource(logger logrus.FieldLogger, client client.Client, objList client.ObjectList, period time.Duration, listOpts ...client.ListOption) *PeriodicalEnqueueSource {
 	return &PeriodicalEnqueueSource{
-		logger:  logger.WithField("resource", reflect.TypeOf(objList).String()),
-		Client:  client,
-		objList: objList,
-		period:  period,
+		logger:   logger.WithField("resource", reflect.TypeOf(objList).String()),
+		Client:   client,
+		objList:  objList,
+		listOpts: listOpts,
+		period:   period,
+		resource: reflect.Indirect(reflect.ValueOf(objList)).Type().Name(),
+		jitter:   wait.Jitter,
+		trigger:  make(chan struct{}, 1),
+		done:     make(chan struct{}),
 	}
 }
 
@@ -48,41 +55,248 @@ func NewPeriodicalEnqueueSource(logger logrus.FieldLogger, client client.Client,
 // the reconcile logic periodically
 type PeriodicalEnqueueSource struct {
 	client.Client
-	logger  logrus.FieldLogger
-	objList client.ObjectList
-	period  time.Duration
+	logger   logrus.FieldLogger
+	objList  client.ObjectList
+	listOpts []client.ListOption
+	period   time.Duration
+	// jitterFactor spreads the cycles out: each one waits for a random time of up to jitterFactor × period on
+	// top of period after the previous one. Zero runs them exactly every period.
+	jitterFactor float64
+	// jitter returns how long to wait between cycles given the period and jitterFactor. It's wait.Jitter except
+	// in tests.
+	jitter func(period time.Duration, maxFactor float64) time.Duration
+	// initialDelay is how long the source waits after starting before its first cycle, e.g. to give caches time
+	// to sync. Zero runs the first cycle right away.
+	initialDelay time.Duration
+	resource     string
+	onCycle      []func(EnqueueCycle)
+	metrics      EnqueueMetrics
+	keyFunc      ObjectKeyFunc
+	// maxPerCycle is the most items a cycle enqueues. Zero means there's no limit.
+	maxPerCycle int
+	// cursor is the index in the list of the item the next cycle starts enqueueing at when maxPerCycle limits
+	// the cycles. It's only used by the source's goroutine.
+	cursor int
+	// trigger requests an enqueue cycle right away. It holds at most one request, so triggers which arrive while
+	// one is pending are coalesced.
+	trigger chan struct{}
+	// done is closed once the goroutine started by Start has exited.
+	done chan struct{}
 }
 
+// EnqueueMetrics receives the counts of a PeriodicalEnqueueSource, labeled by the kind of list it enqueues, e.g.
+// "ScheduleList".
+type EnqueueMetrics interface {
+	// RegisterPeriodicalEnqueued counts the items added to the queue by an enqueue cycle.
+	RegisterPeriodicalEnqueued(resource string, enqueued int)
+	// RegisterPeriodicalEnqueueListError counts an enqueue cycle whose listing failed.
+	RegisterPeriodicalEnqueueListError(resource string)
+}
+
+// EnqueueCycle describes the outcome of one enqueue cycle of a PeriodicalEnqueueSource. Comparing
+// Enqueued with the reconciler's worker count over time gives a hint for sizing MaxConcurrentReconciles.
+type EnqueueCycle struct {
+	// Resource is the kind of list the source enqueues, e.g. "ScheduleList".
+	Resource string
+	// Enqueued is the number of items added to the queue during the cycle.
+	Enqueued int
+	// Duration is how long listing and enqueueing took.
+	Duration time.Duration
+}
+
+// ObjectKeyFunc returns the namespace and name to enqueue a list item under, for list items which aren't a
+// client.Object.
+type ObjectKeyFunc func(obj runtime.Object) (types.NamespacedName, error)
+
+// OnCycle registers a callback that is invoked after every enqueue cycle whose listing succeeded.
+// Callbacks run on the source's goroutine, so they should return quickly.
+func (p *PeriodicalEnqueueSource) OnCycle(fn func(EnqueueCycle)) *PeriodicalEnqueueSource {
+	p.onCycle = append(p.onCycle, fn)
+	return p
+}
+
+// WithJitter makes the source wait for a random time of up to factor × period on top of period between enqueue
+// cycles, so that sources with the same period don't all enqueue at once.
+func (p *PeriodicalEnqueueSource) WithJitter(factor float64) *PeriodicalEnqueueSource {
+	p.jitterFactor = factor
+	return p
+}
+
+// WithMetrics makes the source count the items it enqueues and the listings which fail in metrics.
+func (p *PeriodicalEnqueueSource) WithMetrics(metrics EnqueueMetrics) *PeriodicalEnqueueSource {
+	p.metrics = metrics
+	return p
+}
+
+// WithMaxPerCycle makes each enqueue cycle enqueue at most max items, so that a large list doesn't flood the queue
+// and starve the reconciles of other events. Successive cycles rotate through the list, so every item is enqueued
+// eventually. Zero means there's no limit.
+func (p *PeriodicalEnqueueSource) WithMaxPerCycle(max int) *PeriodicalEnqueueSource {
+	p.maxPerCycle = max
+	return p
+}
+
+// WithKeyFunc makes the source enqueue the list items which aren't a client.Object under the key fn returns for
+// them, instead of skipping them. The predicates passed to Start aren't applied to such items.
+func (p *PeriodicalEnqueueSource) WithKeyFunc(fn ObjectKeyFunc) *PeriodicalEnqueueSource {
+	p.keyFunc = fn
+	return p
+}
+
+// WithInitialDelay makes the source wait for delay after it's started before its first enqueue cycle, so that
+// the resources aren't reconciled before the caches they depend on are warm.
+func (p *PeriodicalEnqueueSource) WithInitialDelay(delay time.Duration) *PeriodicalEnqueueSource {
+	p.initialDelay = delay
+	return p
+}
+
+// TriggerNow makes the source run an enqueue cycle right away instead of waiting for the next period, e.g. to
+// force an immediate scan. It doesn't block, and may be called concurrently with the source's cycles; triggers
+// made while a cycle is already pending result in a single cycle. The next period is counted from the end of the
+// triggered cycle.
+func (p *PeriodicalEnqueueSource) TriggerNow() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Start enqueues the resources every period until ctx is done, after waiting for the initial delay if there is
+// one. Resources which any of the predicates in pre reject as a generic event aren't enqueued. Start must be called
+// at most once.
 func (p *PeriodicalEnqueueSource) Start(ctx context.Context, h handler.EventHandler, q workqueue.RateLimitingInterface, pre ...predicate.Predicate) error {
-	go wait.Until(func() {
-		p.logger.Debug("enqueueing resources ...")
-		if err := p.List(ctx, p.objList); err != nil {
-			p.logger.WithError(err).Error("error listing resources")
+	go func() {
+		defer close(p.done)
+
+		if p.initialDelay > 0 {
+			timer := time.NewTimer(p.initialDelay)
+			defer timer.Stop()
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			case <-p.trigger:
+			}
+		}
+		p.run(ctx, q, pre)
+	}()
+
+	return nil
+}
+
+// run enqueues the resources every period, and whenever TriggerNow is called, until ctx is done.
+func (p *PeriodicalEnqueueSource) run(ctx context.Context, q workqueue.RateLimitingInterface, pre []predicate.Predicate) {
+	for {
+		select {
+		case <-ctx.Done():
 			return
+		default:
 		}
-		if meta.LenList(p.objList) == 0 {
-			p.logger.Debug("no resources, skip")
+		p.enqueue(ctx, q, pre)
+
+		timer := time.NewTimer(p.nextPeriod())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
 			return
+		case <-timer.C:
+		case <-p.trigger:
+			timer.Stop()
+		}
+	}
+}
+
+// nextPeriod returns how long to wait after a cycle before the next one.
+func (p *PeriodicalEnqueueSource) nextPeriod() time.Duration {
+	if p.jitterFactor > 0 {
+		return p.jitter(p.period, p.jitterFactor)
+	}
+	return p.period
+}
+
+// enqueue runs one enqueue cycle, adding the resources the predicates in pre accept to q.
+func (p *PeriodicalEnqueueSource) enqueue(ctx context.Context, q workqueue.RateLimitingInterface, pre []predicate.Predicate) {
+	p.logger.Debug("enqueueing resources ...")
+	start := time.Now()
+	if err := p.List(ctx, p.objList, p.listOpts...); err != nil {
+		p.logger.WithError(err).Error("error listing resources")
+		if p.metrics != nil {
+			p.metrics.RegisterPeriodicalEnqueueListError(p.resource)
+		}
+		return
+	}
+	enqueued := 0
+	defer func() {
+		cycle := EnqueueCycle{Resource: p.resource, Enqueued: enqueued, Duration: time.Since(start)}
+		if p.metrics != nil {
+			p.metrics.RegisterPeriodicalEnqueued(p.resource, enqueued)
+		}
+		for _, fn := range p.onCycle {
+			fn(cycle)
 		}
-		if err := meta.EachListItem(p.objList, func(object runtime.Object) error {
-			obj, ok := object.(metav1.Object)
-			if !ok {
-				p.logger.Error("%s's type isn't metav1.Object", object.GetObjectKind().GroupVersionKind().String())
+	}()
+	if meta.LenList(p.objList) == 0 {
+		p.logger.Debug("no resources, skip")
+		return
+	}
+	var requests []ctrl.Request
+	if err := meta.EachListItem(p.objList, func(object runtime.Object) error {
+		obj, ok := object.(client.Object)
+		if !ok {
+			if p.keyFunc == nil {
+				p.logger.Errorf("%s's type isn't client.Object", object.GetObjectKind().GroupVersionKind().String())
+				return nil
+			}
+			key, err := p.keyFunc(object)
+			if err != nil {
+				p.logger.WithError(err).Errorf("error getting the key of a %s", object.GetObjectKind().GroupVersionKind().String())
 				return nil
 			}
-			q.Add(ctrl.Request{
-				NamespacedName: types.NamespacedName{
-					Namespace: obj.GetNamespace(),
-					Name:      obj.GetName(),
-				},
-			})
-			p.logger.Debugf("resource %s/%s enqueued", obj.GetNamespace(), obj.GetName())
+			requests = append(requests, ctrl.Request{NamespacedName: key})
+			return nil
+		}
+		if !acceptedByPredicates(obj, pre) {
+			p.logger.Debugf("resource %s/%s skipped by predicates", obj.GetNamespace(), obj.GetName())
 			return nil
-		}); err != nil {
-			p.logger.WithError(err).Error("error enqueueing resources")
-			return
 		}
-	}, p.period, ctx.Done())
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+			},
+		})
+		return nil
+	}); err != nil {
+		p.logger.WithError(err).Error("error enqueueing resources")
+		return
+	}
+	for _, request := range p.limitRequests(requests) {
+		q.Add(request)
+		enqueued++
+		p.logger.Debugf("resource %s enqueued", request.NamespacedName)
+	}
+}
 
-	return nil
+// limitRequests returns at most maxPerCycle of requests, starting at the cursor and wrapping around, and moves the
+// cursor past them so that the next cycle continues where this one stopped.
+func (p *PeriodicalEnqueueSource) limitRequests(requests []ctrl.Request) []ctrl.Request {
+	if p.maxPerCycle <= 0 || len(requests) <= p.maxPerCycle {
+		return requests
+	}
+	limited := make([]ctrl.Request, 0, p.maxPerCycle)
+	for i := 0; i < p.maxPerCycle; i++ {
+		limited = append(limited, requests[(p.cursor+i)%len(requests)])
+	}
+	p.cursor = (p.cursor + p.maxPerCycle) % len(requests)
+	return limited
+}
+
+// acceptedByPredicates returns whether every one of predicates accepts obj as a generic event.
+func acceptedByPredicates(obj client.Object, predicates []predicate.Predicate) bool {
+	for _, p := range predicates {
+		if !p.Generic(event.GenericEvent{Object: obj}) {
+			return false
+		}
+	}
+	return true
 }
@@ -17,48 +17,562 @@ limitations under the License.
 package kube
 
 import (
+	"fmt"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/context"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 )
 
-func TestStart(t *testing.T) {
-	require.Nil(t, velerov1.AddToScheme(scheme.Scheme))
+func TestMain(m *testing.M) {
+	// registered once up front rather than by each test, as the sources the tests start read the scheme
+	// concurrently
+	if err := velerov1.AddToScheme(scheme.Scheme); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// enqueueSourceTest holds what a test of a PeriodicalEnqueueSource starts it with: a context, a fake client to list
+// from and the queue to enqueue to.
+type enqueueSourceTest struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	client client.Client
+	queue  workqueue.RateLimitingInterface
+}
+
+func newEnqueueSourceTest(t *testing.T) *enqueueSourceTest {
+	ctx, cancel := context.WithCancel(context.TODO())
+	t.Cleanup(cancel)
+	return &enqueueSourceTest{
+		ctx:    ctx,
+		cancel: cancel,
+		client: (&fake.ClientBuilder{}).Build(),
+		queue:  workqueue.NewRateLimitingQueue(workqueue.DefaultItemBasedRateLimiter()),
+	}
+}
+
+// newSource returns a PeriodicalEnqueueSource which lists objList's type from c every period.
+func (e *enqueueSourceTest) newSource(c client.Client, objList client.ObjectList, period time.Duration, listOpts ...client.ListOption) *PeriodicalEnqueueSource {
+	return NewPeriodicalEnqueueSource(logrus.WithContext(e.ctx), c, objList, period, listOpts...)
+}
+
+// start starts source, and stops it once the test ends so that it doesn't outlive the test.
+func (e *enqueueSourceTest) start(t *testing.T, source *PeriodicalEnqueueSource, pre ...predicate.Predicate) {
+	require.Nil(t, source.Start(e.ctx, nil, e.queue, pre...))
+	t.Cleanup(func() { e.stop(t, source) })
+}
+
+// stop cancels the context source was started with, and waits for it to stop.
+func (e *enqueueSourceTest) stop(t *testing.T, source *PeriodicalEnqueueSource) {
+	e.cancel()
+	select {
+	case <-source.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the source to stop")
+	}
+}
+
+// createSchedules creates a schedule with each of names in the velero namespace.
+func (e *enqueueSourceTest) createSchedules(t *testing.T, names ...string) {
+	for _, name := range names {
+		require.Nil(t, e.client.Create(e.ctx, &velerov1.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "velero",
+				Name:      name,
+			},
+		}))
+	}
+}
 
-	ctx, cancelFunc := context.WithCancel(context.TODO())
-	client := (&fake.ClientBuilder{}).Build()
-	queue := workqueue.NewRateLimitingQueue(workqueue.DefaultItemBasedRateLimiter())
-	source := NewPeriodicalEnqueueSource(logrus.WithContext(ctx), client, &velerov1.ScheduleList{}, 1*time.Second)
+// waitForCycle returns the next enqueue cycle sent to cycles.
+func waitForCycle(t *testing.T, cycles <-chan EnqueueCycle) EnqueueCycle {
+	select {
+	case c := <-cycles:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an enqueue cycle")
+		return EnqueueCycle{}
+	}
+}
 
-	require.Nil(t, source.Start(ctx, nil, queue))
+func TestStart(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second)
+	test.start(t, source)
 
 	// no resources
 	time.Sleep(1 * time.Second)
-	require.Equal(t, queue.Len(), 0)
+	require.Equal(t, test.queue.Len(), 0)
 
 	// contain one resource
-	require.Nil(t, client.Create(ctx, &velerov1.Schedule{
+	require.Nil(t, test.client.Create(test.ctx, &velerov1.Schedule{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "schedule",
 		},
 	}))
 	time.Sleep(2 * time.Second)
-	require.Equal(t, queue.Len(), 1)
+	require.Equal(t, test.queue.Len(), 1)
 
 	// context canceled, the enqueue source shouldn't run anymore
-	item, _ := queue.Get()
-	queue.Forget(item)
-	require.Equal(t, queue.Len(), 0)
-	cancelFunc()
-	time.Sleep(2 * time.Second)
-	require.Equal(t, queue.Len(), 0)
+	item, _ := test.queue.Get()
+	test.queue.Forget(item)
+	require.Equal(t, test.queue.Len(), 0)
+	test.stop(t, source)
+	require.Equal(t, test.queue.Len(), 0)
+}
+
+func TestStartReportsEnqueueCycles(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	cycles := make(chan EnqueueCycle, 10)
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second).
+		OnCycle(func(c EnqueueCycle) { cycles <- c })
+	test.createSchedules(t, "schedule-1", "schedule-2")
+	test.start(t, source)
+
+	c := waitForCycle(t, cycles)
+	require.Equal(t, "ScheduleList", c.Resource)
+	require.Equal(t, 2, c.Enqueued)
+	require.True(t, c.Duration >= 0)
+}
+
+func TestStartWithListOptions(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	schedules := []struct {
+		namespace string
+		name      string
+		labels    map[string]string
+	}{
+		{namespace: "velero", name: "nightly", labels: map[string]string{"team": "a"}},
+		{namespace: "velero", name: "weekly", labels: map[string]string{"team": "b"}},
+		{namespace: "other", name: "nightly", labels: map[string]string{"team": "a"}},
+	}
+	for _, schedule := range schedules {
+		require.Nil(t, test.client.Create(test.ctx, &velerov1.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: schedule.namespace,
+				Name:      schedule.name,
+				Labels:    schedule.labels,
+			},
+		}))
+	}
+
+	cycles := make(chan EnqueueCycle, 10)
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second,
+		client.InNamespace("velero"), client.MatchingLabels{"team": "a"}).
+		OnCycle(func(c EnqueueCycle) { cycles <- c })
+	test.start(t, source)
+
+	require.Equal(t, 1, waitForCycle(t, cycles).Enqueued)
+	require.Equal(t, 1, test.queue.Len())
+	item, _ := test.queue.Get()
+	require.Equal(t, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "velero", Name: "nightly"}}, item)
+}
+
+func TestStartWithJitter(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	period := 10 * time.Millisecond
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, period)
+
+	// without a jitter factor, cycles are exactly a period apart
+	require.Equal(t, period, source.nextPeriod())
+
+	// the jitter is drawn anew for every cycle, from a fake random source which returns 0, 0.5 and 1 in turn
+	var lock sync.Mutex
+	var draws int
+	source.jitter = func(period time.Duration, maxFactor float64) time.Duration {
+		lock.Lock()
+		defer lock.Unlock()
+		r := float64(draws%3) / 2
+		draws++
+		return period + time.Duration(r*maxFactor*float64(period))
+	}
+	source.WithJitter(0.5)
+	require.Equal(t, 10*time.Millisecond, source.nextPeriod())
+	require.Equal(t, 12500*time.Microsecond, source.nextPeriod())
+	require.Equal(t, 15*time.Millisecond, source.nextPeriod())
+
+	// the source waits for the jittered period between its cycles
+	draws = 0
+	cycles := make(chan EnqueueCycle, 100)
+	source.OnCycle(func(c EnqueueCycle) { cycles <- c })
+	test.start(t, source)
+
+	for i := 0; i < 4; i++ {
+		waitForCycle(t, cycles)
+	}
+	test.stop(t, source)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.GreaterOrEqual(t, draws, 3)
+}
+
+func TestNextPeriodWithJitter(t *testing.T) {
+	period := time.Minute
+	source := NewPeriodicalEnqueueSource(logrus.New(), (&fake.ClientBuilder{}).Build(), &velerov1.ScheduleList{}, period).
+		WithJitter(0.5)
+
+	// with a jitter factor of 0.5, cycles are between 1 and 1.5 periods apart, and 1.25 periods on average
+	var total time.Duration
+	for i := 0; i < 1000; i++ {
+		next := source.nextPeriod()
+		require.GreaterOrEqual(t, int64(next), int64(period))
+		require.LessOrEqual(t, int64(next), int64(period*3/2))
+		total += next
+	}
+	average := total / 1000
+	require.InDelta(t, float64(period*5/4), float64(average), float64(period/20))
+}
+
+func TestStartWithPredicates(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	phases := map[string]velerov1.SchedulePhase{
+		"enabled": velerov1.SchedulePhaseEnabled,
+		"new":     velerov1.SchedulePhaseNew,
+		"invalid": velerov1.SchedulePhaseFailedValidation,
+	}
+	for name, phase := range phases {
+		require.Nil(t, test.client.Create(test.ctx, &velerov1.Schedule{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "velero",
+				Name:      name,
+			},
+			Status: velerov1.ScheduleStatus{
+				Phase: phase,
+			},
+		}))
+	}
+
+	cycles := make(chan EnqueueCycle, 10)
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second).
+		OnCycle(func(c EnqueueCycle) { cycles <- c })
+	valid := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.(*velerov1.Schedule).Status.Phase != velerov1.SchedulePhaseFailedValidation
+	})
+	enabled := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.(*velerov1.Schedule).Status.Phase == velerov1.SchedulePhaseEnabled
+	})
+	test.start(t, source, valid, enabled)
+
+	require.Equal(t, 1, waitForCycle(t, cycles).Enqueued)
+	require.Equal(t, 1, test.queue.Len())
+	item, _ := test.queue.Get()
+	require.Equal(t, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "velero", Name: "enabled"}}, item)
+}
+
+func TestStartWithInitialDelay(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	test.createSchedules(t, "schedule")
+
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second).
+		WithInitialDelay(500 * time.Millisecond)
+	test.start(t, source)
+
+	// nothing is enqueued during the initial delay
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, 0, test.queue.Len())
+
+	// the first cycle runs once the initial delay has elapsed
+	time.Sleep(800 * time.Millisecond)
+	require.Equal(t, 1, test.queue.Len())
+}
+
+func TestStartWithInitialDelayStopsWhenContextIsDone(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	test.createSchedules(t, "schedule")
+
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second).
+		WithInitialDelay(500 * time.Millisecond)
+	test.start(t, source)
+
+	// the source exits during the initial delay without ever enqueueing
+	test.stop(t, source)
+	require.Equal(t, 0, test.queue.Len())
+}
+
+type fakeEnqueueMetrics struct {
+	lock       sync.Mutex
+	enqueued   map[string]int
+	listErrors map[string]int
+}
+
+func newFakeEnqueueMetrics() *fakeEnqueueMetrics {
+	return &fakeEnqueueMetrics{enqueued: map[string]int{}, listErrors: map[string]int{}}
+}
+
+func (m *fakeEnqueueMetrics) RegisterPeriodicalEnqueued(resource string, enqueued int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.enqueued[resource] += enqueued
+}
+
+func (m *fakeEnqueueMetrics) RegisterPeriodicalEnqueueListError(resource string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.listErrors[resource]++
+}
+
+func (m *fakeEnqueueMetrics) counts(resource string) (int, int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.enqueued[resource], m.listErrors[resource]
+}
+
+// failingListClient is a client whose List always fails.
+type failingListClient struct {
+	client.Client
+}
+
+func (c *failingListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return errors.New("list failed")
+}
+
+func TestStartWithMetrics(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	test.createSchedules(t, "schedule-1", "schedule-2")
+
+	metrics := newFakeEnqueueMetrics()
+	cycles := make(chan EnqueueCycle, 10)
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, 1*time.Second).
+		WithMetrics(metrics).
+		OnCycle(func(c EnqueueCycle) { cycles <- c })
+	test.start(t, source)
+
+	waitForCycle(t, cycles)
+	enqueued, listErrors := metrics.counts("ScheduleList")
+	require.Equal(t, 2, enqueued)
+	require.Equal(t, 0, listErrors)
+}
+
+func TestStartWithMetricsCountsListErrors(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+
+	metrics := newFakeEnqueueMetrics()
+	source := test.newSource(&failingListClient{Client: test.client}, &velerov1.ScheduleList{}, 1*time.Second).
+		WithMetrics(metrics)
+	test.start(t, source)
+
+	require.Eventually(t, func() bool {
+		_, listErrors := metrics.counts("ScheduleList")
+		return listErrors > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	enqueued, _ := metrics.counts("ScheduleList")
+	require.Equal(t, 0, enqueued)
+	require.Equal(t, 0, test.queue.Len())
+}
+
+func TestStartWithMaxPerCycle(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	for i := 0; i < 10; i++ {
+		test.createSchedules(t, fmt.Sprintf("schedule-%d", i))
+	}
+
+	cycles := make(chan EnqueueCycle, 10)
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, time.Hour).
+		WithMaxPerCycle(3).
+		OnCycle(func(c EnqueueCycle) { cycles <- c })
+	test.start(t, source)
+
+	enqueued := map[string]int{}
+	for i := 0; i < 4; i++ {
+		if i > 0 {
+			source.TriggerNow()
+		}
+		require.Equal(t, 3, waitForCycle(t, cycles).Enqueued)
+
+		// drain the queue, which would otherwise merge the requests of successive cycles
+		require.Equal(t, 3, test.queue.Len())
+		for test.queue.Len() > 0 {
+			item, _ := test.queue.Get()
+			enqueued[item.(ctrl.Request).Name]++
+			test.queue.Done(item)
+		}
+	}
+
+	require.Len(t, enqueued, 10)
+	for name, count := range enqueued {
+		require.GreaterOrEqual(t, count, 1, name)
+	}
+}
+
+// blockingCycles returns an OnCycle callback which sends each cycle to the returned channel and then blocks until
+// it's released by a send on release, so that tests can act while a cycle is in progress.
+func blockingCycles() (fn func(EnqueueCycle), entered <-chan EnqueueCycle, release chan<- struct{}) {
+	enteredCh := make(chan EnqueueCycle, 10)
+	releaseCh := make(chan struct{})
+	return func(c EnqueueCycle) {
+		enteredCh <- c
+		<-releaseCh
+	}, enteredCh, releaseCh
+}
+
+func TestTriggerNowBetweenTicks(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	test.createSchedules(t, "schedule")
+
+	onCycle, entered, release := blockingCycles()
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, time.Hour).OnCycle(onCycle)
+	test.start(t, source)
+
+	// the first cycle runs right away
+	require.Equal(t, 1, waitForCycle(t, entered).Enqueued)
+	release <- struct{}{}
+	item, _ := test.queue.Get()
+	test.queue.Done(item)
+
+	// a trigger runs a cycle without waiting for the next tick, an hour away
+	source.TriggerNow()
+	require.Equal(t, 1, waitForCycle(t, entered).Enqueued)
+
+	// triggers made while a cycle is in progress are coalesced into a single cycle
+	source.TriggerNow()
+	source.TriggerNow()
+	source.TriggerNow()
+	release <- struct{}{}
+	waitForCycle(t, entered)
+	release <- struct{}{}
+	select {
+	case <-entered:
+		t.Fatal("triggers were not coalesced")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestTriggerNowStopsWhenContextIsCancelledMidTrigger(t *testing.T) {
+	test := newEnqueueSourceTest(t)
+	test.createSchedules(t, "schedule")
+
+	onCycle, entered, release := blockingCycles()
+	source := test.newSource(test.client, &velerov1.ScheduleList{}, time.Hour).OnCycle(onCycle)
+	test.start(t, source)
+
+	waitForCycle(t, entered)
+	release <- struct{}{}
+
+	// the context is cancelled while a triggered cycle is in progress and another trigger is pending
+	source.TriggerNow()
+	waitForCycle(t, entered)
+	source.TriggerNow()
+	test.cancel()
+	release <- struct{}{}
+
+	// the source stops without running the pending trigger
+	test.stop(t, source)
+	select {
+	case <-entered:
+		t.Fatal("a cycle ran after the context was cancelled")
+	default:
+	}
+
+	// triggers made after the source stopped don't block
+	source.TriggerNow()
+	source.TriggerNow()
+}
+
+// nameOnlyItem is a list item which isn't a client.Object.
+type nameOnlyItem struct {
+	Namespace string
+	Name      string
+}
+
+func (i *nameOnlyItem) GetObjectKind() schema.ObjectKind { return schema.EmptyObjectKind }
+
+func (i *nameOnlyItem) DeepCopyObject() runtime.Object {
+	out := *i
+	return &out
+}
+
+type nameOnlyList struct {
+	metav1.TypeMeta
+	metav1.ListMeta
+	Items []nameOnlyItem
+}
+
+func (l *nameOnlyList) DeepCopyObject() runtime.Object {
+	out := *l
+	out.Items = append([]nameOnlyItem(nil), l.Items...)
+	return &out
+}
+
+// nameOnlyListClient is a client which lists items into a nameOnlyList.
+type nameOnlyListClient struct {
+	client.Client
+	items []nameOnlyItem
+}
+
+func (c *nameOnlyListClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	list.(*nameOnlyList).Items = c.items
+	return nil
+}
+
+func TestStartWithKeyFunc(t *testing.T) {
+	items := []nameOnlyItem{
+		{Namespace: "velero", Name: "item-1"},
+		{Namespace: "velero", Name: "item-2"},
+		{Namespace: "velero", Name: ""},
+	}
+	keyFunc := func(obj runtime.Object) (types.NamespacedName, error) {
+		item := obj.(*nameOnlyItem)
+		if item.Name == "" {
+			return types.NamespacedName{}, errors.New("item has no name")
+		}
+		return types.NamespacedName{Namespace: item.Namespace, Name: item.Name}, nil
+	}
+
+	tests := []struct {
+		name     string
+		keyFunc  ObjectKeyFunc
+		expected []string
+	}{
+		{
+			name:     "items which aren't client.Objects are skipped by default",
+			expected: []string{},
+		},
+		{
+			name:     "items which aren't client.Objects are enqueued under the key from the key func",
+			keyFunc:  keyFunc,
+			expected: []string{"item-1", "item-2"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			test := newEnqueueSourceTest(t)
+			cycles := make(chan EnqueueCycle, 10)
+			source := test.newSource(&nameOnlyListClient{items: items}, &nameOnlyList{}, time.Hour).
+				OnCycle(func(c EnqueueCycle) { cycles <- c })
+			if tc.keyFunc != nil {
+				source.WithKeyFunc(tc.keyFunc)
+			}
+			test.start(t, source)
+
+			require.Equal(t, len(tc.expected), waitForCycle(t, cycles).Enqueued)
+			enqueued := []string{}
+			for test.queue.Len() > 0 {
+				item, _ := test.queue.Get()
+				require.Equal(t, "velero", item.(ctrl.Request).Namespace)
+				enqueued = append(enqueued, item.(ctrl.Request).Name)
+				test.queue.Done(item)
+			}
+			require.Equal(t, tc.expected, enqueued)
+		})
+	}
 }
@@ -107,7 +107,7 @@ func (f *FilteringCase) CreateResources() error {
 		//Create Configmap
 		configmaptName := f.NSBaseName
 		fmt.Printf("Creating configmap %s in namespaces ...%s\n", configmaptName, namespace)
-		_, err = CreateConfigMap(f.Client.ClientGo, namespace, configmaptName, f.labels)
+		err = CreateConfigMap(f.Ctx, f.Client, namespace, configmaptName, f.labels, f.labels)
 		if err != nil {
 			return errors.Wrap(err, fmt.Sprintf("failed to create configmap in the namespace %q", namespace))
 		}
@@ -19,7 +19,10 @@ package k8s
 import (
 	"sync"
 
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/vmware-tanzu/velero/pkg/client"
@@ -45,29 +48,79 @@ type TestClient struct {
 	// controller runtime framework by v2.0, it is the intent to remove all
 	// client-go API clients. Please use the controller runtime to make API calls for tests.
 	dynamicFactory client.DynamicFactory
+
+	// restConfig is the config the clients were created from, for requests such as port-forwarding
+	// that aren't made through a typed client.
+	restConfig *rest.Config
+}
+
+// DynamicFactory returns the factory of dynamic clients for GroupVersionResources, e.g. to create custom resources
+// Velero has no typed client for.
+//
+// Deprecated, TODO(2.0): presuming all controllers and resources are converted to the
+// controller runtime framework by v2.0, it is the intent to remove all
+// client-go API clients. Please use the controller runtime to make API calls for tests.
+func (c TestClient) DynamicFactory() client.DynamicFactory {
+	return c.dynamicFactory
 }
 
 var (
+	// mu guards once, testClient and err so that ResetTestClient can't race with NewTestClient.
+	mu         sync.Mutex
 	once       sync.Once
 	testClient TestClient
 	err        error
 )
 
 func NewTestClient() (TestClient, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
 	once.Do(func() { // <-- atomic, does not allow repeating
 		testClient, err = InitTestClient() // <-- thread safe
 	})
 	return testClient, err
 }
 
+// ResetTestClient discards the client, or the error, cached by NewTestClient so that the next call
+// initializes a fresh one, e.g. after recovering from a setup failure or switching clusters.
+func ResetTestClient() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	once = sync.Once{}
+	testClient = TestClient{}
+	err = nil
+}
+
 // NewTestClient returns a set of ready-to-use API clients.
 func InitTestClient() (TestClient, error) {
+	return initTestClient("")
+}
+
+// InitTestClientForContext returns a set of ready-to-use API clients for the cluster of the kubeconfig context
+// named kubeContext rather than the current context, e.g. to use a source and a destination cluster at once.
+// Unlike NewTestClient, it creates new clients on every call.
+func InitTestClientForContext(kubeContext string) (TestClient, error) {
+	return initTestClient(kubeContext)
+}
+
+// initTestClient returns a set of ready-to-use API clients for the kubeconfig context named kubeContext, or for
+// the current context if kubeContext is empty.
+func initTestClient(kubeContext string) (TestClient, error) {
 	config, err := client.LoadConfig()
 	if err != nil {
 		return TestClient{}, err
 	}
 
 	f := client.NewFactory("e2e", config)
+	if kubeContext != "" {
+		flags := pflag.NewFlagSet("e2e", pflag.ContinueOnError)
+		f.BindFlags(flags)
+		if err := flags.Set("kubecontext", kubeContext); err != nil {
+			return TestClient{}, errors.Wrapf(err, "failed to select kube context %s", kubeContext)
+		}
+	}
 
 	clientGo, err := f.KubeClient()
 	if err != nil {
@@ -86,9 +139,15 @@ func InitTestClient() (TestClient, error) {
 
 	factory := client.NewDynamicFactory(dynamicClient)
 
+	restConfig, err := f.ClientConfig()
+	if err != nil {
+		return TestClient{}, err
+	}
+
 	return TestClient{
 		Kubebuilder:    kb,
 		ClientGo:       clientGo,
 		dynamicFactory: factory,
+		restConfig:     restConfig,
 	}, nil
 }
@@ -0,0 +1,114 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/vmware-tanzu/velero/pkg/client"
+)
+
+// newFakeAPIServer returns a server which answers the discovery requests made when the API clients are created.
+func newFakeAPIServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api":
+			fmt.Fprint(w, `{"kind":"APIVersions","versions":["v1"]}`)
+		case "/apis":
+			fmt.Fprint(w, `{"kind":"APIGroupList","apiVersion":"v1","groups":[]}`)
+		case "/api/v1":
+			fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"v1","resources":[]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestInitTestClientForContext(t *testing.T) {
+	source, destination := newFakeAPIServer(), newFakeAPIServer()
+	defer source.Close()
+	defer destination.Close()
+
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: source
+  cluster:
+    server: %s
+- name: destination
+  cluster:
+    server: %s
+users:
+- name: user
+contexts:
+- name: source
+  context:
+    cluster: source
+    user: user
+- name: destination
+  context:
+    cluster: destination
+    user: user
+current-context: source
+`, source.URL, destination.URL)
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, ioutil.WriteFile(path, []byte(kubeconfig), 0600))
+	t.Setenv("KUBECONFIG", path)
+	t.Setenv("HOME", t.TempDir())
+
+	sourceClient, err := InitTestClientForContext("source")
+	require.NoError(t, err)
+	destinationClient, err := InitTestClientForContext("destination")
+	require.NoError(t, err)
+	assert.Equal(t, source.URL, sourceClient.restConfig.Host)
+	assert.Equal(t, destination.URL, destinationClient.restConfig.Host)
+
+	_, err = InitTestClientForContext("missing")
+	assert.Error(t, err)
+}
+
+func TestDynamicFactory(t *testing.T) {
+	configMap := &unstructured.Unstructured{}
+	configMap.SetAPIVersion("v1")
+	configMap.SetKind("ConfigMap")
+	configMap.SetNamespace("velero")
+	configMap.SetName("hooks")
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), configMap)
+	testClient := TestClient{dynamicFactory: client.NewDynamicFactory(dynamicClient)}
+
+	resourceClient, err := testClient.DynamicFactory().ClientForGroupVersionResource(
+		schema.GroupVersion{Version: "v1"}, metav1.APIResource{Name: "configmaps", Namespaced: true}, "velero")
+	require.NoError(t, err)
+	got, err := resourceClient.Get("hooks", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "hooks", got.GetName())
+}
@@ -0,0 +1,114 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ClusterSnapshot is a normalized, point-in-time copy of the resources of a set of
+// GroupVersionResources, keyed by GroupVersionResource and then by "namespace/name".
+type ClusterSnapshot map[schema.GroupVersionResource]map[string]map[string]interface{}
+
+// SnapshotClusterState lists all the resources of each of gvrs across all namespaces and
+// returns a normalized copy of them, suitable for comparing the state of the cluster
+// before a backup and after a restore.
+func SnapshotClusterState(ctx context.Context, client TestClient, gvrs []schema.GroupVersionResource) (ClusterSnapshot, error) {
+	snapshot := ClusterSnapshot{}
+	for _, gvr := range gvrs {
+		dynamicClient, err := client.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), metav1.APIResource{Name: gvr.Resource}, "")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get dynamic client for %s", gvr.String())
+		}
+
+		list, err := dynamicClient.List(metav1.ListOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s", gvr.String())
+		}
+
+		items := make(map[string]map[string]interface{}, len(list.Items))
+		for i := range list.Items {
+			item := &list.Items[i]
+			items[item.GetNamespace()+"/"+item.GetName()] = normalizeForSnapshot(item)
+		}
+		snapshot[gvr] = items
+	}
+	return snapshot, nil
+}
+
+// normalizeForSnapshot returns the content of obj without the fields the API server sets
+// and which are expected to differ between an object and its restored copy.
+func normalizeForSnapshot(obj *unstructured.Unstructured) map[string]interface{} {
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "status")
+	for _, field := range []string{"uid", "resourceVersion", "creationTimestamp", "generation", "managedFields", "selfLink"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	return obj.Object
+}
+
+// Diff compares s to other and returns a description of each resource which is missing
+// from other, only present in other, or differs between the two. An empty result means
+// the snapshots are equivalent.
+func (s ClusterSnapshot) Diff(other ClusterSnapshot) []string {
+	var diffs []string
+	for gvr, items := range s {
+		otherItems := other[gvr]
+		for key, item := range items {
+			otherItem, ok := otherItems[key]
+			if !ok {
+				diffs = append(diffs, fmt.Sprintf("%s %s: missing", gvr.String(), key))
+				continue
+			}
+			if !reflect.DeepEqual(item, otherItem) {
+				diffs = append(diffs, fmt.Sprintf("%s %s: changed", gvr.String(), key))
+			}
+		}
+	}
+	for gvr, otherItems := range other {
+		for key := range otherItems {
+			if _, ok := s[gvr][key]; !ok {
+				diffs = append(diffs, fmt.Sprintf("%s %s: unexpected", gvr.String(), key))
+			}
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// InNamespace returns the part of s holding the resources in namespace.
+func (s ClusterSnapshot) InNamespace(namespace string) ClusterSnapshot {
+	res := ClusterSnapshot{}
+	for gvr, items := range s {
+		res[gvr] = make(map[string]map[string]interface{})
+		for key, item := range items {
+			if strings.HasPrefix(key, namespace+"/") {
+				res[gvr][key] = item
+			}
+		}
+	}
+	return res
+}
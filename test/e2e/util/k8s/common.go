@@ -17,26 +17,63 @@ limitations under the License.
 package k8s
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os/exec"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/net/context"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/vmware-tanzu/velero/pkg/builder"
+	veleroexec "github.com/vmware-tanzu/velero/pkg/util/exec"
 	common "github.com/vmware-tanzu/velero/test/e2e/util/common"
 )
 
-// ensureClusterExists returns whether or not a kubernetes cluster exists for tests to be run on.
+// defaultClusterInfoTimeout is how long EnsureClusterExists waits for the cluster if ctx has no deadline.
+const defaultClusterInfoTimeout = 30 * time.Second
+
+// EnsureClusterExists returns whether or not a kubernetes cluster exists for tests to be run on. It gives up when
+// ctx is done, or after 30 seconds if ctx has no deadline, and the error includes kubectl's output.
 func EnsureClusterExists(ctx context.Context) error {
-	return exec.CommandContext(ctx, "kubectl", "cluster-info").Run()
+	if err := ctx.Err(); err != nil {
+		return errors.Wrap(err, "gave up checking whether the cluster exists")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultClusterInfoTimeout)
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+
+	// kubectl would take a timeout of 0 as no timeout at all
+	timeout := time.Until(deadline).Round(time.Second)
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	output, err := runKubectl(ctx, "cluster-info", fmt.Sprintf("--request-timeout=%ds", int(timeout.Seconds())))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the cluster info, output=%s", strings.TrimSpace(output))
+	}
+	return nil
 }
 
+// CreateSecretFromFiles creates an Opaque secret named name in namespace holding the contents of files, which maps
+// the keys of the secret to the paths of the files. It's an error if the secret already exists.
 func CreateSecretFromFiles(ctx context.Context, client TestClient, namespace string, name string, files map[string]string) error {
 	data := make(map[string][]byte)
 
@@ -49,34 +86,181 @@ func CreateSecretFromFiles(ctx context.Context, client TestClient, namespace str
 		data[key] = contents
 	}
 
+	return createSecret(ctx, client, namespace, name, data)
+}
+
+// CreateSecretFromLiterals creates an Opaque secret named name in namespace holding data, without writing it to
+// files first. It's an error if the secret already exists.
+func CreateSecretFromLiterals(ctx context.Context, client TestClient, namespace, name string, data map[string]string) error {
+	secretData := make(map[string][]byte, len(data))
+	for key, value := range data {
+		secretData[key] = []byte(value)
+	}
+	return createSecret(ctx, client, namespace, name, secretData)
+}
+
+func createSecret(ctx context.Context, client TestClient, namespace, name string, data map[string][]byte) error {
 	secret := builder.ForSecret(namespace, name).Data(data).Result()
+	secret.Type = corev1api.SecretTypeOpaque
 	_, err := client.ClientGo.CoreV1().Secrets(namespace).Create(ctx, secret, metav1.CreateOptions{})
-	return err
+	if apierrors.IsAlreadyExists(err) {
+		return errors.Errorf("secret %s/%s already exists", namespace, name)
+	}
+	return errors.Wrapf(err, "failed to create secret %s/%s", namespace, name)
 }
 
-// WaitForPods waits until all of the pods have gone to PodRunning state
+// WaitForPods waits until all of the pods have gone to PodRunning state. If they haven't after the timeout, the
+// error says why each pod which isn't running is stuck, e.g. which of its containers is waiting and for what.
 func WaitForPods(ctx context.Context, client TestClient, namespace string, pods []string) error {
-	timeout := 10 * time.Minute
-	interval := 5 * time.Second
-	err := wait.PollImmediate(interval, timeout, func() (bool, error) {
-		for _, podName := range pods {
+	return waitForPods(ctx, client, namespace, pods, false)
+}
+
+// WaitForPodsReady is like WaitForPods, but also waits until all of the pods are ready, as a running pod whose
+// containers aren't ready yet can't serve the test.
+func WaitForPodsReady(ctx context.Context, client TestClient, namespace string, pods []string) error {
+	return waitForPods(ctx, client, namespace, pods, true)
+}
+
+var (
+	// podPollInterval is how often WaitForPods and WaitForPodsReady check the pods.
+	podPollInterval = 5 * time.Second
+	// podWaitTimeout is how long WaitForPods and WaitForPodsReady wait for the pods.
+	podWaitTimeout = 10 * time.Minute
+)
+
+// podCheckParallelism is how many pods WaitForPods and WaitForPodsReady get at once.
+const podCheckParallelism = 5
+
+func waitForPods(ctx context.Context, client TestClient, namespace string, pods []string, ready bool) error {
+	// the reasons the pods weren't running, or ready, at the last poll
+	var stuck []string
+	err := wait.PollImmediate(podPollInterval, podWaitTimeout, func() (bool, error) {
+		var err error
+		stuck, err = checkPods(ctx, client, namespace, pods, ready)
+		return len(stuck) == 0, err
+	})
+	if err != nil {
+		if len(stuck) > 0 {
+			err = errors.Wrap(err, strings.Join(stuck, "; "))
+		}
+		return errors.Wrapf(err, "Failed to wait for pods in namespace %s to start running", namespace)
+	}
+	return nil
+}
+
+// checkPods gets the pods concurrently, so that a slow request for one of them doesn't hold up checking the
+// others, and returns why each one which isn't running, or ready, is stuck, in the order of pods.
+func checkPods(ctx context.Context, client TestClient, namespace string, pods []string, ready bool) ([]string, error) {
+	reasons := make([]string, len(pods))
+	errs := make([]error, len(pods))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, podCheckParallelism)
+	for i, podName := range pods {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, podName string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
 			checkPod, err := client.ClientGo.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
 			if err != nil {
-				return false, errors.WithMessage(err, fmt.Sprintf("Failed to verify pod %s/%s is %s", namespace, podName, corev1api.PodRunning))
-			}
-			// If any pod is still waiting we don't need to check any more so return and wait for next poll interval
-			if checkPod.Status.Phase != corev1api.PodRunning {
-				fmt.Printf("Pod %s is in state %s waiting for it to be %s\n", podName, checkPod.Status.Phase, corev1api.PodRunning)
-				return false, nil
+				errs[i] = errors.WithMessage(err, fmt.Sprintf("Failed to verify pod %s/%s is %s", namespace, podName, corev1api.PodRunning))
+				return
 			}
+			reasons[i] = podNotRunningReason(checkPod, ready)
+		}(i, podName)
+	}
+	wg.Wait()
+
+	var stuck []string
+	for i, podName := range pods {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if reasons[i] != "" {
+			fmt.Printf("Pod %s is %s\n", podName, reasons[i])
+			stuck = append(stuck, fmt.Sprintf("pod %s is %s", podName, reasons[i]))
 		}
-		// All pods were in PodRunning state, we're successful
-		return true, nil
+	}
+	return stuck, nil
+}
+
+// deploymentPollInterval is how often WaitForDeploymentReady checks the status of the deployment.
+var deploymentPollInterval = PollInterval
+
+// WaitForDeploymentReady waits up to timeout until all of the replicas of the deployment named name in namespace
+// are updated to its latest spec and ready, so it also waits for rollouts and for replaced pods. The error after
+// the timeout says how many of the replicas were ready, available and updated.
+func WaitForDeploymentReady(ctx context.Context, client TestClient, namespace, name string, timeout time.Duration) error {
+	deployment := &appsv1.Deployment{}
+	err := wait.PollImmediate(deploymentPollInterval, timeout, func() (bool, error) {
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: name}, deployment); err != nil {
+			return false, errors.Wrapf(err, "failed to get deployment %s/%s", namespace, name)
+		}
+		replicas := deploymentReplicas(deployment)
+		return deployment.Status.ObservedGeneration >= deployment.Generation &&
+			deployment.Status.UpdatedReplicas == replicas &&
+			deployment.Status.ReadyReplicas == replicas, nil
 	})
-	if err != nil {
-		return errors.Wrapf(err, fmt.Sprintf("Failed to wait for pods in namespace %s to start running", namespace))
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf("deployment %s/%s isn't ready after %v: %d of %d replicas ready, %d available, %d updated",
+			namespace, name, timeout, deployment.Status.ReadyReplicas, deploymentReplicas(deployment),
+			deployment.Status.AvailableReplicas, deployment.Status.UpdatedReplicas)
 	}
-	return nil
+	return err
+}
+
+// deploymentReplicas returns how many replicas deployment should have.
+func deploymentReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// podNotRunningReason describes why pod isn't running yet, or if ready is true, why it isn't ready yet. It returns
+// an empty string if the pod is running, and ready if required.
+func podNotRunningReason(pod *corev1api.Pod, ready bool) string {
+	var reason string
+	switch {
+	case pod.Status.Phase != corev1api.PodRunning:
+		reason = fmt.Sprintf("in state %s waiting for it to be %s", pod.Status.Phase, corev1api.PodRunning)
+	case ready && !podReady(pod):
+		reason = "running but not ready"
+	default:
+		return ""
+	}
+
+	var details []string
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1api.PodScheduled && condition.Status == corev1api.ConditionFalse {
+			details = append(details, fmt.Sprintf("not scheduled: %s: %s", condition.Reason, condition.Message))
+		}
+	}
+	statuses := append(append([]corev1api.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		switch {
+		case status.State.Waiting != nil:
+			details = append(details, fmt.Sprintf("container %s waiting: %s: %s", status.Name, status.State.Waiting.Reason, status.State.Waiting.Message))
+		case ready && status.State.Running != nil && !status.Ready:
+			details = append(details, fmt.Sprintf("container %s not ready", status.Name))
+		}
+	}
+	if len(details) > 0 {
+		reason += " (" + strings.Join(details, ", ") + ")"
+	}
+	return reason
+}
+
+// podReady returns whether pod's Ready condition is true.
+func podReady(pod *corev1api.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1api.PodReady {
+			return condition.Status == corev1api.ConditionTrue
+		}
+	}
+	return false
 }
 
 func GetPvcByPodName(ctx context.Context, namespace, podName string) ([]string, error) {
@@ -99,6 +283,67 @@ func GetPvcByPodName(ctx context.Context, namespace, podName string) ([]string,
 	return common.GetListBy2Pipes(ctx, *CmdLine1, *CmdLine2, *CmdLine3)
 }
 
+// GetPVCNamesFromPod returns the names of the PersistentVolumeClaims the pod named podName in namespace mounts, in
+// the order of the pod's volumes. Unlike GetPvcByPodName, it matches PVCs by the claims the pod references rather
+// than by name, and doesn't need kubectl. It's an error if the pod references a PVC which doesn't exist.
+func GetPVCNamesFromPod(ctx context.Context, client TestClient, namespace, podName string) ([]string, error) {
+	pod := &corev1api.Pod{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: podName}, pod); err != nil {
+		return nil, errors.Wrapf(err, "failed to get pod %s/%s", namespace, podName)
+	}
+	pvcs := &corev1api.PersistentVolumeClaimList{}
+	if err := client.Kubebuilder.List(ctx, pvcs, kbclient.InNamespace(namespace)); err != nil {
+		return nil, errors.Wrapf(err, "failed to list the PersistentVolumeClaims in namespace %s", namespace)
+	}
+	existing := make(map[string]bool, len(pvcs.Items))
+	for _, pvc := range pvcs.Items {
+		existing[pvc.Name] = true
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claimName := volume.PersistentVolumeClaim.ClaimName
+		if !existing[claimName] {
+			return nil, errors.Errorf("pod %s/%s references PersistentVolumeClaim %s, which doesn't exist", namespace, podName, claimName)
+		}
+		if !seen[claimName] {
+			seen[claimName] = true
+			names = append(names, claimName)
+		}
+	}
+	return names, nil
+}
+
+// pvcPollInterval is how often WaitForPVCBound checks the phase of the PersistentVolumeClaim.
+var pvcPollInterval = PollInterval
+
+// WaitForPVCBound waits up to timeout until the PersistentVolumeClaim named pvcName in namespace is bound, and
+// returns the name of the PersistentVolume it's bound to. Unlike GetPvByPvc, it doesn't need the claim to be bound
+// already, and matches the PersistentVolume by the claim's volume name rather than by name.
+func WaitForPVCBound(ctx context.Context, client TestClient, namespace, pvcName string, timeout time.Duration) (string, error) {
+	pvc := &corev1api.PersistentVolumeClaim{}
+	err := wait.PollImmediate(pvcPollInterval, timeout, func() (bool, error) {
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: pvcName}, pvc); err != nil {
+			return false, errors.Wrapf(err, "failed to get PersistentVolumeClaim %s/%s", namespace, pvcName)
+		}
+		if pvc.Status.Phase == corev1api.ClaimLost {
+			return false, errors.Errorf("PersistentVolumeClaim %s/%s lost its PersistentVolume %s", namespace, pvcName, pvc.Spec.VolumeName)
+		}
+		return pvc.Status.Phase == corev1api.ClaimBound && pvc.Spec.VolumeName != "", nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return "", errors.Errorf("PersistentVolumeClaim %s/%s isn't bound after %v, phase %s", namespace, pvcName, timeout, pvc.Status.Phase)
+	}
+	if err != nil {
+		return "", err
+	}
+	return pvc.Spec.VolumeName, nil
+}
+
 func GetPvByPvc(ctx context.Context, pvc string) ([]string, error) {
 	// Example:
 	// 	  NAME                                       CAPACITY   ACCESS MODES   RECLAIM POLICY   STATUS   CLAIM                                              STORAGECLASS             REASON   AGE
@@ -121,23 +366,183 @@ func GetPvByPvc(ctx context.Context, pvc string) ([]string, error) {
 	return common.GetListBy2Pipes(ctx, *CmdLine1, *CmdLine2, *CmdLine3)
 }
 
+// AddLabelToPv applies label, in kubectl's syntax, i.e. "key=value" to set a label or "key-" to remove one, to the
+// PersistentVolume named pv. It overwrites the label if the PersistentVolume already has it.
 func AddLabelToPv(ctx context.Context, pv, label string) error {
-	return exec.CommandContext(ctx, "kubectl", "label", "pv", pv, label).Run()
+	return applyLabel(ctx, builder.ForPersistentVolume(pv).Result(), label)
 }
 
+// AddLabelToPvc is like AddLabelToPv for the PersistentVolumeClaim named pvc in namespace.
 func AddLabelToPvc(ctx context.Context, pvc, namespace, label string) error {
-	args := []string{"label", "pvc", pvc, "-n", namespace, label}
-	fmt.Println(args)
-	return exec.CommandContext(ctx, "kubectl", args...).Run()
+	return applyLabel(ctx, builder.ForPersistentVolumeClaim(namespace, pvc).Result(), label)
 }
 
+// AddLabelToPod is like AddLabelToPv for the pod named podName in namespace.
 func AddLabelToPod(ctx context.Context, podName, namespace, label string) error {
-	args := []string{"label", "pod", podName, "-n", namespace, label}
-	fmt.Println(args)
-	return exec.CommandContext(ctx, "kubectl", args...).Run()
+	return applyLabel(ctx, builder.ForPod(namespace, podName).Result(), label)
+}
+
+// applyLabel applies label, in kubectl's syntax, to obj with the default test client.
+func applyLabel(ctx context.Context, obj kbclient.Object, label string) error {
+	client, err := NewTestClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to create the test client")
+	}
+	if key := strings.TrimSuffix(label, "-"); key != label && !strings.Contains(label, "=") {
+		return RemoveLabel(ctx, client, obj, key)
+	}
+	keyValue := strings.SplitN(label, "=", 2)
+	if len(keyValue) != 2 {
+		return errors.Errorf("invalid label %q, expected key=value or key-", label)
+	}
+	return SetLabel(ctx, client, obj, keyValue[0], keyValue[1])
+}
+
+// SetLabel sets the label key of obj to value, overwriting it if obj already has it. obj only needs its name, and
+// namespace if it's namespaced, to be set; it's updated to the latest state of the object.
+func SetLabel(ctx context.Context, client TestClient, obj kbclient.Object, key, value string) error {
+	return patchLabels(ctx, client, obj, func(labels map[string]string) map[string]string {
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[key] = value
+		return labels
+	})
+}
+
+// RemoveLabel removes the label key from obj, if it has it. obj only needs its name, and namespace if it's
+// namespaced, to be set; it's updated to the latest state of the object.
+func RemoveLabel(ctx context.Context, client TestClient, obj kbclient.Object, key string) error {
+	return patchLabels(ctx, client, obj, func(labels map[string]string) map[string]string {
+		delete(labels, key)
+		return labels
+	})
+}
+
+// patchLabels fetches obj, and patches its labels with the ones update returns given its current ones.
+func patchLabels(ctx context.Context, client TestClient, obj kbclient.Object, update func(map[string]string) map[string]string) error {
+	key := kbclient.ObjectKeyFromObject(obj)
+	if err := client.Kubebuilder.Get(ctx, key, obj); err != nil {
+		return errors.Wrapf(err, "failed to get %T %s", obj, key)
+	}
+	original := obj.DeepCopyObject().(kbclient.Object)
+	obj.SetLabels(update(obj.GetLabels()))
+	if err := client.Kubebuilder.Patch(ctx, obj, kbclient.MergeFrom(original)); err != nil {
+		return errors.Wrapf(err, "failed to patch the labels of %T %s", obj, key)
+	}
+	return nil
+}
+
+// transientKubectlErrors are parts of kubectl's output which mean that a command failed because of a transient
+// API server error, and may succeed when retried.
+var transientKubectlErrors = []string{
+	"connection refused",
+	"etcdserver: leader changed",
+	"the object has been modified",
+}
+
+// kubectlRetryBackoff is how long KubectlApplyByFileWithRetry waits before its first retry. It doubles for every
+// further retry.
+var kubectlRetryBackoff = time.Second
+
+// runKubectl runs kubectl with args and returns its combined stdout and stderr. Tests replace it to stub kubectl.
+var runKubectl = func(ctx context.Context, args ...string) (string, error) {
+	output, err := exec.CommandContext(ctx, "kubectl", args...).CombinedOutput()
+	return string(output), err
 }
 
 func KubectlApplyByFile(ctx context.Context, file string) error {
+	return KubectlApplyByFileWithRetry(ctx, file, 0)
+}
+
+// KubectlApplyByFileWithRetry applies file with kubectl, retrying up to retries times with a backoff if it fails
+// because of a transient API server error, e.g. a refused connection, an etcd leader change or a conflict. The
+// error includes kubectl's output.
+func KubectlApplyByFileWithRetry(ctx context.Context, file string, retries int) error {
 	args := []string{"apply", "-f", file, "--force=true"}
-	return exec.CommandContext(ctx, "kubectl", args...).Run()
+	backoff := kubectlRetryBackoff
+	for attempt := 0; ; attempt++ {
+		output, err := runKubectl(ctx, args...)
+		if err == nil {
+			return nil
+		}
+		err = errors.Wrapf(err, "failed to apply %s, output=%s", file, strings.TrimSpace(output))
+		if attempt >= retries || !isTransientKubectlError(output) {
+			return err
+		}
+
+		fmt.Printf("Retrying to apply %s in %v: %v\n", file, backoff, err)
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "gave up retrying to apply %s", file)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// applyFieldManager is the field manager KubectlApplyByYAML applies objects as.
+const applyFieldManager = "velero-e2e"
+
+// KubectlApplyByYAML applies the objects in manifest, which may hold several YAML documents separated by "---",
+// with server-side apply through the controller-runtime client, so that templated manifests don't need to be
+// written to files or applied with kubectl. Namespaced objects must have their namespace set. It applies every
+// document it can, and the error names each document which couldn't be decoded or applied.
+func KubectlApplyByYAML(ctx context.Context, client TestClient, manifest string) error {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(strings.NewReader(manifest)))
+	var errs []error
+	for document := 1; ; document++ {
+		data, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to read document %d", document))
+			break
+		}
+		if err := applyYAMLDocument(ctx, client, data); err != nil {
+			errs = append(errs, errors.Wrapf(err, "document %d", document))
+		}
+	}
+	return kerrors.NewAggregate(errs)
+}
+
+// applyYAMLDocument applies the object in the YAML document data with server-side apply. Documents without an
+// object, e.g. only comments, are skipped.
+func applyYAMLDocument(ctx context.Context, client TestClient, data []byte) error {
+	jsonData, err := utilyaml.ToJSON(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode YAML")
+	}
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) == 0 || string(trimmed) == "null" {
+		return nil
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(jsonData); err != nil {
+		return errors.Wrap(err, "failed to decode object")
+	}
+	if err := client.Kubebuilder.Patch(ctx, obj, kbclient.Apply, kbclient.ForceOwnership, kbclient.FieldOwner(applyFieldManager)); err != nil {
+		return errors.Wrapf(err, "failed to apply %s %s", obj.GetKind(), kbclient.ObjectKeyFromObject(obj))
+	}
+	return nil
+}
+
+func isTransientKubectlError(output string) bool {
+	for _, transient := range transientKubectlErrors {
+		if strings.Contains(output, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecInPod runs command in container of the pod named podName in namespace with kubectl exec and returns its
+// stdout.
+func ExecInPod(ctx context.Context, namespace, podName, container string, command ...string) (string, error) {
+	args := append([]string{"exec", "-n", namespace, podName, "-c", container, "--"}, command...)
+	stdout, stderr, err := veleroexec.RunCommand(exec.CommandContext(ctx, "kubectl", args...))
+	if err != nil {
+		return stdout, errors.Wrapf(err, "failed to run %v in pod %s, stderr=%s", command, podName, stderr)
+	}
+	return stdout, nil
 }
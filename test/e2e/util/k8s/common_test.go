@@ -0,0 +1,524 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/vmware-tanzu/velero/pkg/builder"
+)
+
+func TestGetPVCNamesFromPod(t *testing.T) {
+	pod := builder.ForPod("ns-1", "kibishii-deployment-0").Volumes(
+		builder.ForVolume("data").PersistentVolumeClaimSource("kibishii-data-kibishii-deployment-0").Result(),
+		&corev1api.Volume{Name: "config", VolumeSource: corev1api.VolumeSource{EmptyDir: &corev1api.EmptyDirVolumeSource{}}},
+		builder.ForVolume("logs").PersistentVolumeClaimSource("logs").Result(),
+	).Result()
+	podWithMissingPVC := builder.ForPod("ns-1", "kibishii-deployment-1").Volumes(
+		builder.ForVolume("missing").PersistentVolumeClaimSource("missing").Result(),
+	).Result()
+
+	client := TestClient{
+		Kubebuilder: fake.NewClientBuilder().WithObjects(
+			pod,
+			podWithMissingPVC,
+			builder.ForPersistentVolumeClaim("ns-1", "kibishii-data-kibishii-deployment-0").Result(),
+			builder.ForPersistentVolumeClaim("ns-1", "logs").Result(),
+			// its name contains the pod's name, but the pod doesn't reference it
+			builder.ForPersistentVolumeClaim("ns-1", "kibishii-deployment-0-unrelated").Result(),
+			builder.ForPersistentVolumeClaim("ns-2", "missing").Result(),
+		).Build(),
+	}
+
+	tests := []struct {
+		name      string
+		podName   string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "the PVCs the pod references are returned in the order of its volumes",
+			podName:  "kibishii-deployment-0",
+			expected: []string{"kibishii-data-kibishii-deployment-0", "logs"},
+		},
+		{
+			name:      "a PVC which doesn't exist in the pod's namespace is an error",
+			podName:   "kibishii-deployment-1",
+			expectErr: true,
+		},
+		{
+			name:      "a pod which doesn't exist is an error",
+			podName:   "kibishii-deployment-2",
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			names, err := GetPVCNamesFromPod(context.TODO(), client, "ns-1", test.podName)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestKubectlApplyByFileWithRetry(t *testing.T) {
+	defer func(run func(context.Context, ...string) (string, error), backoff time.Duration) {
+		runKubectl, kubectlRetryBackoff = run, backoff
+	}(runKubectl, kubectlRetryBackoff)
+	kubectlRetryBackoff = time.Millisecond
+
+	tests := []struct {
+		name          string
+		failures      []string
+		retries       int
+		expectErr     string
+		expectedCalls int
+	}{
+		{
+			name:          "transient errors are retried until the apply succeeds",
+			failures:      []string{"dial tcp 127.0.0.1:6443: connect: connection refused", "etcdserver: leader changed"},
+			retries:       3,
+			expectedCalls: 3,
+		},
+		{
+			name:          "transient errors are retried at most retries times",
+			failures:      []string{"connection refused", "connection refused", "connection refused"},
+			retries:       2,
+			expectErr:     "output=connection refused",
+			expectedCalls: 3,
+		},
+		{
+			name:          "other errors aren't retried",
+			failures:      []string{`error: the path "file.yaml" does not exist`},
+			retries:       3,
+			expectErr:     `output=error: the path "file.yaml" does not exist`,
+			expectedCalls: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			runKubectl = func(ctx context.Context, args ...string) (string, error) {
+				assert.Equal(t, []string{"apply", "-f", "file.yaml", "--force=true"}, args)
+				calls++
+				if calls <= len(test.failures) {
+					return test.failures[calls-1], errors.New("exit status 1")
+				}
+				return "configured", nil
+			}
+
+			err := KubectlApplyByFileWithRetry(context.TODO(), "file.yaml", test.retries)
+			if test.expectErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCalls, calls)
+		})
+	}
+}
+
+func TestWaitForDeploymentReady(t *testing.T) {
+	defer func(interval time.Duration) { deploymentPollInterval = interval }(deploymentPollInterval)
+	deploymentPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		readyAt   int32
+		expectErr string
+	}{
+		{
+			name:    "the deployment becomes ready when all of its replicas are",
+			readyAt: 3,
+		},
+		{
+			name:      "the error after the timeout has the replica counts",
+			readyAt:   2,
+			expectErr: "deployment ns-1/deploy-1 isn't ready after 500ms: 2 of 3 replicas ready, 2 available, 3 updated",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.TODO()
+			deployment := NewDeployment("deploy-1", "ns-1", 3, map[string]string{"app": "deploy-1"})
+			client := TestClient{Kubebuilder: fake.NewClientBuilder().WithObjects(deployment).Build()}
+
+			// roll the replicas out one by one while waiting
+			readyAt := test.readyAt
+			rolledOut := make(chan struct{})
+			go func() {
+				defer close(rolledOut)
+				for ready := int32(1); ready <= readyAt; ready++ {
+					time.Sleep(50 * time.Millisecond)
+					current := &appsv1.Deployment{}
+					if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: "ns-1", Name: "deploy-1"}, current); err != nil {
+						return
+					}
+					current.Status.UpdatedReplicas = 3
+					current.Status.ReadyReplicas = ready
+					current.Status.AvailableReplicas = ready
+					if err := client.Kubebuilder.Update(ctx, current); err != nil {
+						return
+					}
+				}
+			}()
+
+			err := WaitForDeploymentReady(ctx, client, "ns-1", "deploy-1", 500*time.Millisecond)
+			<-rolledOut
+			if test.expectErr != "" {
+				assert.EqualError(t, err, test.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWaitForDeploymentReadyWhenDeploymentDoesNotExist(t *testing.T) {
+	client := TestClient{Kubebuilder: fake.NewClientBuilder().Build()}
+	err := WaitForDeploymentReady(context.TODO(), client, "ns-1", "deploy-1", time.Second)
+	assert.Error(t, err)
+}
+
+func TestCreateSecretFromLiterals(t *testing.T) {
+	ctx := context.TODO()
+	client := TestClient{ClientGo: kubefake.NewSimpleClientset()}
+	data := map[string]string{
+		"cloud":  "[default]\naws_access_key_id=minio\naws_secret_access_key=minio123\n",
+		"region": "minio",
+	}
+
+	require.NoError(t, CreateSecretFromLiterals(ctx, client, "velero", "cloud-credentials", data))
+
+	secret, err := client.ClientGo.CoreV1().Secrets("velero").Get(ctx, "cloud-credentials", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, corev1api.SecretTypeOpaque, secret.Type)
+	assert.Len(t, secret.Data, len(data))
+	for key, value := range data {
+		assert.Equal(t, value, string(secret.Data[key]))
+	}
+
+	err = CreateSecretFromLiterals(ctx, client, "velero", "cloud-credentials", map[string]string{"region": "us-east-1"})
+	assert.EqualError(t, err, "secret velero/cloud-credentials already exists")
+}
+
+func TestEnsureClusterExists(t *testing.T) {
+	defer func(run func(context.Context, ...string) (string, error)) { runKubectl = run }(runKubectl)
+
+	var args []string
+	runKubectl = func(ctx context.Context, a ...string) (string, error) {
+		args = a
+		return "Kubernetes control plane is running at https://127.0.0.1:6443", nil
+	}
+	require.NoError(t, EnsureClusterExists(context.TODO()))
+	assert.Equal(t, []string{"cluster-info", "--request-timeout=30s"}, args)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, EnsureClusterExists(ctx))
+	assert.Equal(t, []string{"cluster-info", "--request-timeout=5s"}, args)
+
+	runKubectl = func(ctx context.Context, a ...string) (string, error) {
+		return "The connection to the server localhost:8080 was refused\n", errors.New("exit status 1")
+	}
+	assert.EqualError(t, EnsureClusterExists(context.TODO()),
+		"failed to get the cluster info, output=The connection to the server localhost:8080 was refused: exit status 1")
+}
+
+func TestEnsureClusterExistsWhenContextIsCancelled(t *testing.T) {
+	defer func(run func(context.Context, ...string) (string, error)) { runKubectl = run }(runKubectl)
+	// kubectl hangs until it's killed
+	runKubectl = func(ctx context.Context, a ...string) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	start := time.Now()
+	assert.Error(t, EnsureClusterExists(ctx))
+
+	ctx, cancel = context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, EnsureClusterExists(ctx))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestWaitForPVCBound(t *testing.T) {
+	defer func(interval time.Duration) { pvcPollInterval = interval }(pvcPollInterval)
+	pvcPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name       string
+		bindAfter  time.Duration
+		expectedPV string
+		expectErr  string
+	}{
+		{
+			name:       "the PV is returned once the PVC is bound",
+			bindAfter:  50 * time.Millisecond,
+			expectedPV: "pvc-3f784366-58db-40b2-8fec-77307807e74b",
+		},
+		{
+			name:      "the error after the timeout has the phase of the PVC",
+			expectErr: "PersistentVolumeClaim ns-1/kibishii-data isn't bound after 200ms, phase Pending",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.TODO()
+			pvc := builder.ForPersistentVolumeClaim("ns-1", "kibishii-data").Result()
+			pvc.Status.Phase = corev1api.ClaimPending
+			client := TestClient{Kubebuilder: fake.NewClientBuilder().WithObjects(
+				pvc,
+				// its name contains the PVC's name, but the PVC isn't bound to it
+				builder.ForPersistentVolume("kibishii-data-old").Result(),
+			).Build()}
+
+			// the PV controller binds the PVC after a while, if at all
+			bindAfter := test.bindAfter
+			bound := make(chan struct{})
+			go func() {
+				defer close(bound)
+				if bindAfter == 0 {
+					return
+				}
+				time.Sleep(bindAfter)
+				current := &corev1api.PersistentVolumeClaim{}
+				if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: "ns-1", Name: "kibishii-data"}, current); err != nil {
+					return
+				}
+				current.Spec.VolumeName = "pvc-3f784366-58db-40b2-8fec-77307807e74b"
+				current.Status.Phase = corev1api.ClaimBound
+				_ = client.Kubebuilder.Update(ctx, current)
+			}()
+
+			pv, err := WaitForPVCBound(ctx, client, "ns-1", "kibishii-data", 200*time.Millisecond)
+			<-bound
+			if test.expectErr != "" {
+				assert.EqualError(t, err, test.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedPV, pv)
+		})
+	}
+}
+
+func TestSetLabelAndRemoveLabel(t *testing.T) {
+	ctx := context.TODO()
+	pod := builder.ForPod("ns-1", "pod-1").ObjectMeta(builder.WithLabels("app", "kibishii")).Result()
+	client := TestClient{Kubebuilder: fake.NewClientBuilder().WithObjects(pod).Build()}
+
+	getLabels := func() map[string]string {
+		current := &corev1api.Pod{}
+		require.NoError(t, client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: "ns-1", Name: "pod-1"}, current))
+		return current.Labels
+	}
+
+	// a new label is added to the existing ones
+	require.NoError(t, SetLabel(ctx, client, builder.ForPod("ns-1", "pod-1").Result(), "for", "1"))
+	assert.Equal(t, map[string]string{"app": "kibishii", "for": "1"}, getLabels())
+
+	// an existing label is overwritten, and obj is updated
+	obj := builder.ForPod("ns-1", "pod-1").Result()
+	require.NoError(t, SetLabel(ctx, client, obj, "for", "2"))
+	assert.Equal(t, map[string]string{"app": "kibishii", "for": "2"}, getLabels())
+	assert.Equal(t, map[string]string{"app": "kibishii", "for": "2"}, obj.Labels)
+
+	// removing a label leaves the others
+	require.NoError(t, RemoveLabel(ctx, client, builder.ForPod("ns-1", "pod-1").Result(), "for"))
+	assert.Equal(t, map[string]string{"app": "kibishii"}, getLabels())
+
+	// removing a label the object doesn't have is a no-op
+	require.NoError(t, RemoveLabel(ctx, client, builder.ForPod("ns-1", "pod-1").Result(), "for"))
+	assert.Equal(t, map[string]string{"app": "kibishii"}, getLabels())
+
+	assert.Error(t, SetLabel(ctx, client, builder.ForPod("ns-1", "pod-2").Result(), "for", "1"))
+}
+
+// applyPatchClient emulates server-side apply, which the fake client doesn't support, by creating or replacing the
+// applied object.
+type applyPatchClient struct {
+	kbclient.Client
+}
+
+func (c *applyPatchClient) Patch(ctx context.Context, obj kbclient.Object, patch kbclient.Patch, opts ...kbclient.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if err := c.Get(ctx, kbclient.ObjectKeyFromObject(obj), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return c.Create(ctx, obj)
+		}
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+func TestKubectlApplyByYAML(t *testing.T) {
+	ctx := context.TODO()
+	client := TestClient{Kubebuilder: &applyPatchClient{Client: fake.NewClientBuilder().Build()}}
+
+	manifest := `---
+# the hooks of the workload
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hooks
+  namespace: ns-1
+data:
+  pre: "sync"
+---
+apiVersion: v1
+metadata:
+  name: broken
+  namespace: ns-1
+`
+	err := KubectlApplyByYAML(ctx, client, manifest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "document 2")
+	assert.NotContains(t, err.Error(), "document 1")
+
+	configMap := &corev1api.ConfigMap{}
+	require.NoError(t, client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: "ns-1", Name: "hooks"}, configMap))
+	assert.Equal(t, map[string]string{"pre": "sync"}, configMap.Data)
+
+	// applying again updates the object
+	require.NoError(t, KubectlApplyByYAML(ctx, client, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: hooks
+  namespace: ns-1
+data:
+  pre: "fsfreeze"
+`))
+	require.NoError(t, client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: "ns-1", Name: "hooks"}, configMap))
+	assert.Equal(t, map[string]string{"pre": "fsfreeze"}, configMap.Data)
+}
+
+func TestWaitForPods(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		podPollInterval, podWaitTimeout = interval, timeout
+	}(podPollInterval, podWaitTimeout)
+	podPollInterval = 10 * time.Millisecond
+	podWaitTimeout = 500 * time.Millisecond
+
+	tests := []struct {
+		name string
+		pods int
+		// startAfter is when each pod starts running; pods which aren't in it never do
+		startAfter map[string]time.Duration
+		expectErr  []string
+	}{
+		{
+			name:       "a single pod",
+			pods:       1,
+			startAfter: map[string]time.Duration{"pod-0": 50 * time.Millisecond},
+		},
+		{
+			name: "pods which start at different times are all waited for",
+			pods: 7,
+			startAfter: map[string]time.Duration{
+				"pod-0": 0,
+				"pod-1": 50 * time.Millisecond,
+				"pod-2": 100 * time.Millisecond,
+				"pod-3": 0,
+				"pod-4": 150 * time.Millisecond,
+				"pod-5": 50 * time.Millisecond,
+				"pod-6": 0,
+			},
+		},
+		{
+			name: "the error names the pods which never started",
+			pods: 4,
+			startAfter: map[string]time.Duration{
+				"pod-0": 0,
+				"pod-2": 50 * time.Millisecond,
+			},
+			expectErr: []string{"pod pod-1 is in state Pending", "pod pod-3 is in state Pending"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.TODO()
+			var pods []string
+			clientGo := kubefake.NewSimpleClientset()
+			for i := 0; i < test.pods; i++ {
+				pod := builder.ForPod("ns-1", fmt.Sprintf("pod-%d", i)).Result()
+				pod.Status.Phase = corev1api.PodPending
+				_, err := clientGo.CoreV1().Pods("ns-1").Create(ctx, pod, metav1.CreateOptions{})
+				require.NoError(t, err)
+				pods = append(pods, pod.Name)
+			}
+
+			var wg sync.WaitGroup
+			for name, after := range test.startAfter {
+				wg.Add(1)
+				go func(name string, after time.Duration) {
+					defer wg.Done()
+					time.Sleep(after)
+					pod, err := clientGo.CoreV1().Pods("ns-1").Get(ctx, name, metav1.GetOptions{})
+					if err != nil {
+						return
+					}
+					pod.Status.Phase = corev1api.PodRunning
+					_, _ = clientGo.CoreV1().Pods("ns-1").UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+				}(name, after)
+			}
+
+			err := WaitForPods(ctx, TestClient{ClientGo: clientGo}, "ns-1", pods)
+			wg.Wait()
+			if len(test.expectErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, expected := range test.expectErr {
+				assert.Contains(t, err.Error(), expected)
+			}
+			for name := range test.startAfter {
+				assert.NotContains(t, err.Error(), "pod "+name+" ")
+			}
+		})
+	}
+}
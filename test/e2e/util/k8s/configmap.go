@@ -17,6 +17,7 @@ limitations under the License.
 package k8s
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -26,20 +27,40 @@ import (
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	waitutil "k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
+
+	"github.com/vmware-tanzu/velero/pkg/builder"
 )
 
-func CreateConfigMap(c clientset.Interface, ns, name string, data map[string]string) (*v1.ConfigMap, error) {
-	cm := &v1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   name,
-			Labels: data,
-		},
-		Data: data,
+// CreateConfigMap creates a ConfigMap with the given data and labels.
+func CreateConfigMap(ctx context.Context, client TestClient, namespace, name string, data map[string]string, labels map[string]string) error {
+	cm := builder.ForConfigMap(namespace, name).ObjectMeta(builder.WithLabelsMap(labels)).Result()
+	cm.Data = data
+	_, err := client.ClientGo.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{})
+	return err
+}
+
+// UpsertConfigMap creates a ConfigMap with the given data and labels, or, if it already exists, merges
+// them into the existing ConfigMap so that configuration can be layered by successive calls.
+func UpsertConfigMap(ctx context.Context, client TestClient, namespace, name string, data map[string]string, labels map[string]string) error {
+	err := CreateConfigMap(ctx, client, namespace, name, data, labels)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": labels},
+		"data":     data,
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal configmap patch")
 	}
-	return c.CoreV1().ConfigMaps(ns).Create(context.TODO(), cm, metav1.CreateOptions{})
+	_, err = client.ClientGo.CoreV1().ConfigMaps(namespace).Patch(ctx, name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+	return errors.Wrapf(err, "failed to patch configmap %s/%s", namespace, name)
 }
 
 // WaitForConfigMapComplete uses c to wait for completions to complete for the Job jobName in namespace ns.
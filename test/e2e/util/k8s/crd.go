@@ -0,0 +1,71 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// crdPollInterval is how often WaitForCRDEstablished checks the conditions of the CustomResourceDefinition.
+var crdPollInterval = PollInterval
+
+// WaitForCRDEstablished waits up to timeout until the CustomResourceDefinition named crdName, e.g.
+// "datauploads.velero.io", exists and is established, so that resources of its kind can be created. It's meant for
+// the CRDs plugins register when they're installed. The error after the timeout includes the conditions of the
+// CustomResourceDefinition which aren't true, e.g. why its names weren't accepted.
+func WaitForCRDEstablished(ctx context.Context, client TestClient, crdName string, timeout time.Duration) error {
+	var crd *apiextv1.CustomResourceDefinition
+	err := wait.PollImmediate(crdPollInterval, timeout, func() (bool, error) {
+		current := &apiextv1.CustomResourceDefinition{}
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Name: crdName}, current); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, errors.Wrapf(err, "failed to get CustomResourceDefinition %s", crdName)
+		}
+		crd = current
+		for _, condition := range crd.Status.Conditions {
+			if condition.Type == apiextv1.Established {
+				return condition.Status == apiextv1.ConditionTrue, nil
+			}
+		}
+		return false, nil
+	})
+	if err != wait.ErrWaitTimeout {
+		return err
+	}
+	if crd == nil {
+		return errors.Errorf("CustomResourceDefinition %s doesn't exist after %v", crdName, timeout)
+	}
+
+	var pending []string
+	for _, condition := range crd.Status.Conditions {
+		if condition.Status != apiextv1.ConditionTrue {
+			pending = append(pending, fmt.Sprintf("%s is %s: %s", condition.Type, condition.Status, condition.Message))
+		}
+	}
+	return errors.Errorf("CustomResourceDefinition %s isn't established after %v: [%s]", crdName, timeout, strings.Join(pending, "; "))
+}
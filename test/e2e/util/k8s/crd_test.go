@@ -0,0 +1,106 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWaitForCRDEstablished(t *testing.T) {
+	defer func(interval time.Duration) { crdPollInterval = interval }(crdPollInterval)
+	crdPollInterval = 10 * time.Millisecond
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, apiextv1.AddToScheme(scheme))
+
+	tests := []struct {
+		name           string
+		establishAfter time.Duration
+		expectErr      string
+	}{
+		{
+			name:           "the helper returns once the CRD is established",
+			establishAfter: 50 * time.Millisecond,
+		},
+		{
+			name:      "the error after the timeout has the conditions which aren't true",
+			expectErr: "CustomResourceDefinition datauploads.velero.io isn't established after 200ms: [Established is False: not all names are accepted]",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.TODO()
+			crd := &apiextv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "datauploads.velero.io"},
+				Status: apiextv1.CustomResourceDefinitionStatus{
+					Conditions: []apiextv1.CustomResourceDefinitionCondition{
+						{Type: apiextv1.NamesAccepted, Status: apiextv1.ConditionTrue},
+						{Type: apiextv1.Established, Status: apiextv1.ConditionFalse, Message: "not all names are accepted"},
+					},
+				},
+			}
+			client := TestClient{Kubebuilder: fake.NewClientBuilder().WithScheme(scheme).WithObjects(crd).Build()}
+
+			// the API server establishes the CRD after a while, if at all
+			establishAfter := test.establishAfter
+			established := make(chan struct{})
+			go func() {
+				defer close(established)
+				if establishAfter == 0 {
+					return
+				}
+				time.Sleep(establishAfter)
+				current := &apiextv1.CustomResourceDefinition{}
+				if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Name: "datauploads.velero.io"}, current); err != nil {
+					return
+				}
+				current.Status.Conditions[1] = apiextv1.CustomResourceDefinitionCondition{Type: apiextv1.Established, Status: apiextv1.ConditionTrue}
+				_ = client.Kubebuilder.Update(ctx, current)
+			}()
+
+			err := WaitForCRDEstablished(ctx, client, "datauploads.velero.io", 200*time.Millisecond)
+			<-established
+			if test.expectErr != "" {
+				assert.EqualError(t, err, test.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWaitForCRDEstablishedWhenCRDDoesNotExist(t *testing.T) {
+	defer func(interval time.Duration) { crdPollInterval = interval }(crdPollInterval)
+	crdPollInterval = 10 * time.Millisecond
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, apiextv1.AddToScheme(scheme))
+	client := TestClient{Kubebuilder: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+	err := WaitForCRDEstablished(context.TODO(), client, "datauploads.velero.io", 100*time.Millisecond)
+	assert.EqualError(t, err, "CustomResourceDefinition datauploads.velero.io doesn't exist after 100ms")
+}
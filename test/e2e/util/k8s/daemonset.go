@@ -0,0 +1,70 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	apps "k8s.io/api/apps/v1"
+	corev1api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NodeAgentDaemonSet is the name of the DaemonSet running Velero's node agent, which performs filesystem backups
+// and restores of pod volumes.
+const NodeAgentDaemonSet = "restic"
+
+// WaitForNodeAgentReady waits up to timeout until a node agent pod is ready on every node the node agent DaemonSet
+// in namespace is scheduled on, printing the readiness of each node's pod while waiting. Filesystem backups of pod
+// volumes on a node fail if its node agent isn't ready.
+func WaitForNodeAgentReady(ctx context.Context, client TestClient, namespace string, timeout time.Duration) error {
+	err := wait.PollImmediate(PollInterval, timeout, func() (bool, error) {
+		daemonSet := &apps.DaemonSet{}
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: NodeAgentDaemonSet}, daemonSet); err != nil {
+			return false, errors.Wrapf(err, "failed to get daemonset %s", NodeAgentDaemonSet)
+		}
+		if daemonSet.Status.DesiredNumberScheduled > 0 && daemonSet.Status.NumberReady == daemonSet.Status.DesiredNumberScheduled {
+			return true, nil
+		}
+
+		fmt.Printf("Daemonset %s has %d of %d pods ready, waiting for the rest\n", NodeAgentDaemonSet,
+			daemonSet.Status.NumberReady, daemonSet.Status.DesiredNumberScheduled)
+		pods := &corev1api.PodList{}
+		if err := client.Kubebuilder.List(ctx, pods, kbclient.InNamespace(namespace), kbclient.MatchingLabels(daemonSet.Spec.Selector.MatchLabels)); err != nil {
+			return false, errors.Wrapf(err, "failed to list the pods of daemonset %s", NodeAgentDaemonSet)
+		}
+		for _, pod := range pods.Items {
+			fmt.Printf("  node %s: pod %s is %s, ready: %t\n", pod.Spec.NodeName, pod.Name, pod.Status.Phase, isPodReady(&pod))
+		}
+		return false, nil
+	})
+	return errors.Wrapf(err, "failed to wait for daemonset %s to be ready", NodeAgentDaemonSet)
+}
+
+// isPodReady returns whether pod's Ready condition is true.
+func isPodReady(pod *corev1api.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1api.PodReady {
+			return condition.Status == corev1api.ConditionTrue
+		}
+	}
+	return false
+}
@@ -93,3 +93,26 @@ func WaitForReadyDeployment(c clientset.Interface, ns, name string) error {
 	}
 	return nil
 }
+
+// WaitForDeploymentAvailable waits for the latest rollout of a deployment to complete, i.e. until the deployment
+// controller has observed its latest spec and all of its replicas are updated and available.
+func WaitForDeploymentAvailable(ctx context.Context, client TestClient, namespace, name string) error {
+	err := wait.PollImmediate(PollInterval, PollTimeout, func() (bool, error) {
+		deployment, err := client.ClientGo.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("failed to get deployment %q: %v", name, err)
+		}
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		return deployment.Status.ObservedGeneration >= deployment.Generation &&
+			deployment.Status.UpdatedReplicas == replicas &&
+			deployment.Status.Replicas == replicas &&
+			deployment.Status.AvailableReplicas == replicas, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to wait for the rollout of deployment %s/%s: %v", namespace, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// veleroMetadataPrefix is the prefix of the keys of the labels and annotations Velero sets on resources.
+const veleroMetadataPrefix = "velero.io/"
+
+// VerifyMetadataPreserved checks that the restored resource of gvr named name in namespace carries each of the
+// labels and annotations in expected, which holds both: each key must be set to its value as either a label or
+// an annotation. It also checks that no labels or annotations Velero sets on the resources it backs up are left
+// on the resource, except for the labels a restore adds to identify the backup and restore it came from.
+func VerifyMetadataPreserved(ctx context.Context, client TestClient, namespace string, gvr schema.GroupVersionResource, name string, expected map[string]string) error {
+	resourceClient, err := client.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), metav1.APIResource{Name: gvr.Resource, Namespaced: true}, namespace)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get dynamic client for %s", gvr.String())
+	}
+	obj, err := resourceClient.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get %s %s in namespace %s", gvr.String(), name, namespace)
+	}
+	labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+
+	var problems []string
+	for key, value := range expected {
+		actual, ok := labels[key]
+		if !ok {
+			actual, ok = annotations[key]
+		}
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is missing", key))
+		case actual != value:
+			problems = append(problems, fmt.Sprintf("%s is %q, expected %q", key, actual, value))
+		}
+	}
+	for kind, metadata := range map[string]map[string]string{"label": labels, "annotation": annotations} {
+		for key := range metadata {
+			if _, ok := expected[key]; ok || !strings.HasPrefix(key, veleroMetadataPrefix) {
+				continue
+			}
+			if kind == "label" && (key == velerov1api.BackupNameLabel || key == velerov1api.RestoreNameLabel) {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("unexpected Velero %s %s", kind, key))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.Errorf("metadata of %s %s in namespace %s wasn't preserved: %s", gvr.String(), name, namespace, strings.Join(problems, "; "))
+	}
+	fmt.Printf("Metadata of %s %s in namespace %s was preserved\n", gvr.String(), name, namespace)
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -28,7 +29,9 @@ import (
 	corev1api "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeerrs "k8s.io/apimachinery/pkg/util/errors"
 	waitutil "k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/vmware-tanzu/velero/pkg/builder"
 )
@@ -134,3 +137,144 @@ func WaitAllSelectedNSDeleted(ctx context.Context, client TestClient, label stri
 			}
 		})
 }
+
+// namespaceDeletionPollInterval is how often WaitForNamespaceGone and DeleteNamespaceAndWait check whether a
+// namespace is gone.
+var namespaceDeletionPollInterval = 5 * time.Second
+
+// WaitForNamespaceGone waits until the namespace has been fully deleted. If it's still present when the timeout
+// expires, the returned error lists the finalizers and the remaining content that are blocking its deletion.
+func WaitForNamespaceGone(ctx context.Context, client TestClient, name string, timeout time.Duration) error {
+	var ns *corev1api.Namespace
+	err := waitutil.PollImmediate(namespaceDeletionPollInterval, timeout, func() (bool, error) {
+		var err error
+		ns, err = client.ClientGo.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		logrus.Debugf("namespace %q is still being deleted...", name)
+		return false, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if err != waitutil.ErrWaitTimeout || ns == nil {
+		return errors.Wrapf(err, "failed to wait for namespace %q to be deleted", name)
+	}
+	return namespaceNotDeletedError(ns, timeout)
+}
+
+// DeleteNamespaceAndWait deletes the namespace with the controller-runtime client and waits up to timeout until
+// it's fully gone, so that a namespace with the same name can be created right after. If it's still terminating
+// when the timeout expires, the returned error lists the finalizers and the remaining content that are blocking
+// its deletion.
+func DeleteNamespaceAndWait(ctx context.Context, client TestClient, namespace string, timeout time.Duration) error {
+	if err := client.Kubebuilder.Delete(ctx, builder.ForNamespace(namespace).Result()); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to delete namespace %q", namespace)
+	}
+
+	var ns *corev1api.Namespace
+	err := waitutil.PollImmediate(namespaceDeletionPollInterval, timeout, func() (bool, error) {
+		current := &corev1api.Namespace{}
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Name: namespace}, current); err != nil {
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, err
+		}
+		ns = current
+		logrus.Debugf("namespace %q is still being deleted...", namespace)
+		return false, nil
+	})
+	if err == nil {
+		return nil
+	}
+	if err != waitutil.ErrWaitTimeout || ns == nil {
+		return errors.Wrapf(err, "failed to wait for namespace %q to be deleted", namespace)
+	}
+	return namespaceNotDeletedError(ns, timeout)
+}
+
+// namespaceNotDeletedError returns the error for ns not having been deleted within timeout, which lists the
+// finalizers and the remaining content that are blocking its deletion.
+func namespaceNotDeletedError(ns *corev1api.Namespace, timeout time.Duration) error {
+	var blockers []string
+	for _, finalizer := range ns.Spec.Finalizers {
+		blockers = append(blockers, fmt.Sprintf("finalizer %s", finalizer))
+	}
+	for _, finalizer := range ns.Finalizers {
+		blockers = append(blockers, fmt.Sprintf("finalizer %s", finalizer))
+	}
+	for _, condition := range ns.Status.Conditions {
+		if condition.Status != corev1api.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case corev1api.NamespaceContentRemaining, corev1api.NamespaceFinalizersRemaining, corev1api.NamespaceDeletionContentFailure:
+			blockers = append(blockers, condition.Message)
+		}
+	}
+	return errors.Errorf("namespace %q was not deleted within %s, phase %s, blocked by: [%s]", ns.Name, timeout, ns.Status.Phase, strings.Join(blockers, "; "))
+}
+
+// namespaceSetupParallelism is how many namespaces CreateNamespacesWithWorkload sets up at once.
+const namespaceSetupParallelism = 10
+
+// CreateNamespacesWithWorkload creates count namespaces named prefix-0 to prefix-<count-1> concurrently, calls
+// workloadFn to deploy a workload into each one, and waits for the pods of every namespace to be running. It
+// carries on setting up the other namespaces when one fails, and returns the errors of all of them.
+func CreateNamespacesWithWorkload(ctx context.Context, client TestClient, prefix string, count int, workloadFn func(ns string) error) error {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, namespaceSetupParallelism)
+	for i := 0; i < count; i++ {
+		ns := fmt.Sprintf("%s-%d", prefix, i)
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := createNamespaceWithWorkload(ctx, client, ns, workloadFn); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return kubeerrs.NewAggregate(errs)
+}
+
+func createNamespaceWithWorkload(ctx context.Context, client TestClient, ns string, workloadFn func(ns string) error) error {
+	if err := CreateNamespace(ctx, client, ns); err != nil {
+		return errors.Wrapf(err, "failed to create namespace %s", ns)
+	}
+	if err := workloadFn(ns); err != nil {
+		return errors.Wrapf(err, "failed to create the workload in namespace %s", ns)
+	}
+
+	err := waitutil.PollImmediate(5*time.Second, 10*time.Minute, func() (bool, error) {
+		pods, err := client.ClientGo.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1api.PodRunning && pod.Status.Phase != corev1api.PodSucceeded {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	return errors.Wrapf(err, "failed to wait for the pods in namespace %s to be running", ns)
+}
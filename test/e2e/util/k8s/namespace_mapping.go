@@ -0,0 +1,68 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// VerifyNamespaceMapping checks that a restore mapping sourceNS to targetNS restored resources of each of gvrs
+// into targetNS and none into sourceNS. Resources left in sourceNS are fine as long as they weren't created by
+// a restore.
+func VerifyNamespaceMapping(ctx context.Context, client TestClient, sourceNS, targetNS string, gvrs []schema.GroupVersionResource) error {
+	var problems []string
+	for _, gvr := range gvrs {
+		targetClient, err := client.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), metav1.APIResource{Name: gvr.Resource, Namespaced: true}, targetNS)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get dynamic client for %s", gvr.String())
+		}
+		restored, err := targetClient.List(metav1.ListOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to list %s in namespace %s", gvr.String(), targetNS)
+		}
+		if len(restored.Items) == 0 {
+			problems = append(problems, fmt.Sprintf("no %s were restored into namespace %s", gvr.String(), targetNS))
+		}
+
+		sourceClient, err := client.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), metav1.APIResource{Name: gvr.Resource, Namespaced: true}, sourceNS)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get dynamic client for %s", gvr.String())
+		}
+		misplaced, err := sourceClient.List(metav1.ListOptions{LabelSelector: velerov1api.RestoreNameLabel})
+		if err != nil {
+			return errors.Wrapf(err, "failed to list %s in namespace %s", gvr.String(), sourceNS)
+		}
+		for _, item := range misplaced.Items {
+			problems = append(problems, fmt.Sprintf("%s %s was restored into source namespace %s by restore %s",
+				gvr.String(), item.GetName(), sourceNS, item.GetLabels()[velerov1api.RestoreNameLabel]))
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.Errorf("restore didn't map namespace %s to %s: %s", sourceNS, targetNS, strings.Join(problems, "; "))
+	}
+	fmt.Printf("Resources were restored into namespace %s instead of %s\n", targetNS, sourceNS)
+	return nil
+}
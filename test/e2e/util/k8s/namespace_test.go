@@ -0,0 +1,93 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1api "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/vmware-tanzu/velero/pkg/builder"
+)
+
+func TestDeleteNamespaceAndWait(t *testing.T) {
+	defer func(interval time.Duration) { namespaceDeletionPollInterval = interval }(namespaceDeletionPollInterval)
+	namespaceDeletionPollInterval = 10 * time.Millisecond
+
+	tests := []struct {
+		name           string
+		finalizedAfter time.Duration
+		expectErr      string
+		expectExists   bool
+	}{
+		{
+			name:           "the namespace is gone once its finalizers are done",
+			finalizedAfter: 50 * time.Millisecond,
+		},
+		{
+			name:         "the error after the timeout has the remaining finalizers",
+			expectErr:    `namespace "ns-1" was not deleted within 200ms, phase Terminating, blocked by: [finalizer example.io/cleanup]`,
+			expectExists: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.TODO()
+			ns := builder.ForNamespace("ns-1").ObjectMeta(builder.WithFinalizers("example.io/cleanup")).Phase(corev1api.NamespaceTerminating).Result()
+			client := TestClient{Kubebuilder: fake.NewClientBuilder().WithObjects(ns).Build()}
+
+			// the finalizer's controller removes it after a while, if at all
+			finalizedAfter := test.finalizedAfter
+			finalized := make(chan struct{})
+			go func() {
+				defer close(finalized)
+				if finalizedAfter == 0 {
+					return
+				}
+				time.Sleep(finalizedAfter)
+				current := &corev1api.Namespace{}
+				if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Name: "ns-1"}, current); err != nil {
+					return
+				}
+				current.Finalizers = nil
+				_ = client.Kubebuilder.Update(ctx, current)
+			}()
+
+			err := DeleteNamespaceAndWait(ctx, client, "ns-1", 200*time.Millisecond)
+			<-finalized
+			if test.expectErr != "" {
+				assert.EqualError(t, err, test.expectErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			err = client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Name: "ns-1"}, &corev1api.Namespace{})
+			assert.Equal(t, test.expectExists, err == nil)
+			assert.Equal(t, !test.expectExists, apierrors.IsNotFound(err))
+		})
+	}
+}
+
+func TestDeleteNamespaceAndWaitWhenNamespaceDoesNotExist(t *testing.T) {
+	client := TestClient{Kubebuilder: fake.NewClientBuilder().Build()}
+	assert.NoError(t, DeleteNamespaceAndWait(context.TODO(), client, "ns-1", time.Second))
+}
@@ -0,0 +1,155 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	networkingv1api "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// VeleroPodSelector matches the pods of the Velero server deployment.
+var VeleroPodSelector = map[string]string{"deploy": "velero"}
+
+// BlockEgressToEndpoint partitions the pods matching podSelector in namespace from endpoint by
+// applying a NetworkPolicy that allows all egress except to the endpoint's addresses. The endpoint
+// may be a URL (e.g. the BSL's s3Url), a host name, an IP or a CIDR; host names are resolved from
+// where the test runs, so in-cluster endpoints should be given as pod IPs or CIDRs. The returned
+// function removes the policy and may be called more than once.
+//
+// Enforcement is up to the cluster's network plugin; on clusters whose CNI ignores NetworkPolicy
+// the call succeeds but has no effect.
+func BlockEgressToEndpoint(ctx context.Context, client TestClient, namespace string, podSelector map[string]string, endpoint string) (func() error, error) {
+	cidrs, err := endpointCIDRs(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &networkingv1api.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:    namespace,
+			GenerateName: "e2e-block-egress-",
+		},
+		Spec: networkingv1api.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: podSelector},
+			PolicyTypes: []networkingv1api.PolicyType{networkingv1api.PolicyTypeEgress},
+			Egress: []networkingv1api.NetworkPolicyEgressRule{
+				{To: allowAllExcept(cidrs)},
+			},
+		},
+	}
+	policy, err = client.ClientGo.NetworkingV1().NetworkPolicies(namespace).Create(ctx, policy, metav1.CreateOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create network policy blocking egress to %q", endpoint)
+	}
+	fmt.Printf("Blocked egress from %s/%v to %v with network policy %s\n", namespace, podSelector, cidrs, policy.Name)
+
+	var once sync.Once
+	var cleanupErr error
+	cleanup := func() error {
+		once.Do(func() {
+			err := client.ClientGo.NetworkingV1().NetworkPolicies(namespace).Delete(context.Background(), policy.Name, metav1.DeleteOptions{})
+			if err != nil && !apierrors.IsNotFound(err) {
+				cleanupErr = errors.Wrapf(err, "failed to delete network policy %s/%s", namespace, policy.Name)
+			}
+		})
+		return cleanupErr
+	}
+	return cleanup, nil
+}
+
+// BlockEgressToEndpointFor works like BlockEgressToEndpoint, but lifts the partition on its own once
+// duration has elapsed. The returned function lifts it early.
+func BlockEgressToEndpointFor(ctx context.Context, client TestClient, namespace string, podSelector map[string]string, endpoint string, duration time.Duration) (func() error, error) {
+	cleanup, err := BlockEgressToEndpoint(ctx, client, namespace, podSelector, endpoint)
+	if err != nil {
+		return nil, err
+	}
+	timer := time.AfterFunc(duration, func() {
+		if err := cleanup(); err != nil {
+			fmt.Printf("Failed to lift egress partition to %q: %v\n", endpoint, err)
+		}
+	})
+	return func() error {
+		timer.Stop()
+		return cleanup()
+	}, nil
+}
+
+// endpointCIDRs returns the CIDRs that endpoint refers to.
+func endpointCIDRs(endpoint string) ([]string, error) {
+	if _, ipNet, err := net.ParseCIDR(endpoint); err == nil {
+		return []string{ipNet.String()}, nil
+	}
+
+	host := endpoint
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse endpoint %q", endpoint)
+		}
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(endpoint); err == nil {
+		host = h
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = append(ips, ip)
+	} else {
+		resolved, err := net.LookupIP(host)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve endpoint %q", endpoint)
+		}
+		ips = resolved
+	}
+
+	var cidrs []string
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			cidrs = append(cidrs, ip.String()+"/32")
+		} else {
+			cidrs = append(cidrs, ip.String()+"/128")
+		}
+	}
+	return cidrs, nil
+}
+
+// allowAllExcept returns egress peers that allow every address apart from cidrs.
+func allowAllExcept(cidrs []string) []networkingv1api.NetworkPolicyPeer {
+	var v4, v6 []string
+	for _, cidr := range cidrs {
+		if strings.Contains(cidr, ":") {
+			v6 = append(v6, cidr)
+		} else {
+			v4 = append(v4, cidr)
+		}
+	}
+	return []networkingv1api.NetworkPolicyPeer{
+		{IPBlock: &networkingv1api.IPBlock{CIDR: "0.0.0.0/0", Except: v4}},
+		{IPBlock: &networkingv1api.IPBlock{CIDR: "::/0", Except: v6}},
+	}
+}
@@ -0,0 +1,78 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1api "k8s.io/api/core/v1"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/label"
+)
+
+// VerifyPVProperties checks that the PersistentVolume named pvName has the expected reclaim policy and storage
+// class.
+func VerifyPVProperties(ctx context.Context, client TestClient, pvName string, expectedReclaim corev1api.PersistentVolumeReclaimPolicy, expectedSC string) error {
+	pv := &corev1api.PersistentVolume{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Name: pvName}, pv); err != nil {
+		return errors.Wrapf(err, "failed to get PersistentVolume %s", pvName)
+	}
+	return checkPVProperties(pv, expectedReclaim, expectedSC)
+}
+
+// VerifyRestoredPVProperties checks that every PersistentVolume restored from the backup named backupName has the
+// expected reclaim policy and storage class. It's an error if no PersistentVolume was restored from the backup.
+func VerifyRestoredPVProperties(ctx context.Context, client TestClient, backupName string, expectedReclaim corev1api.PersistentVolumeReclaimPolicy, expectedSC string) error {
+	pvs := &corev1api.PersistentVolumeList{}
+	if err := client.Kubebuilder.List(ctx, pvs, kbclient.MatchingLabels{velerov1api.BackupNameLabel: label.GetValidName(backupName)}); err != nil {
+		return errors.Wrapf(err, "failed to list the PersistentVolumes restored from backup %s", backupName)
+	}
+	if len(pvs.Items) == 0 {
+		return errors.Errorf("no PersistentVolumes were restored from backup %s", backupName)
+	}
+
+	var mismatches []string
+	for i := range pvs.Items {
+		if err := checkPVProperties(&pvs.Items[i], expectedReclaim, expectedSC); err != nil {
+			mismatches = append(mismatches, err.Error())
+		}
+	}
+	if len(mismatches) > 0 {
+		return errors.Errorf("PersistentVolumes restored from backup %s don't have the expected properties: %s", backupName, strings.Join(mismatches, "; "))
+	}
+	fmt.Printf("%d PersistentVolumes restored from backup %s have reclaim policy %s and storage class %q\n", len(pvs.Items), backupName, expectedReclaim, expectedSC)
+	return nil
+}
+
+func checkPVProperties(pv *corev1api.PersistentVolume, expectedReclaim corev1api.PersistentVolumeReclaimPolicy, expectedSC string) error {
+	var mismatches []string
+	if pv.Spec.PersistentVolumeReclaimPolicy != expectedReclaim {
+		mismatches = append(mismatches, fmt.Sprintf("reclaim policy is %s, not %s", pv.Spec.PersistentVolumeReclaimPolicy, expectedReclaim))
+	}
+	if pv.Spec.StorageClassName != expectedSC {
+		mismatches = append(mismatches, fmt.Sprintf("storage class is %q, not %q", pv.Spec.StorageClassName, expectedSC))
+	}
+	if len(mismatches) > 0 {
+		return errors.Errorf("PersistentVolume %s: %s", pv.Name, strings.Join(mismatches, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForward forwards a free local port to port of the pod named podName in namespace, like
+// `kubectl port-forward`. It returns the local port, and a function which stops forwarding and may be
+// called more than once. Forwarding also stops when ctx is done.
+func PortForward(ctx context.Context, client TestClient, namespace, podName string, port int) (int, func(), error) {
+	if client.restConfig == nil {
+		return 0, nil, errors.New("test client has no REST config to port-forward with")
+	}
+
+	transport, upgrader, err := spdy.RoundTripperFor(client.restConfig)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "failed to create port-forward round tripper")
+	}
+	url := client.ClientGo.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(namespace).Name(podName).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	forwarder, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", port)}, stopCh, readyCh, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "failed to port-forward to pod %s/%s", namespace, podName)
+	}
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() { close(stopCh) })
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-stopCh:
+		}
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		stop()
+		return 0, nil, errors.Wrapf(err, "failed to port-forward to pod %s/%s", namespace, podName)
+	case <-ctx.Done():
+		stop()
+		return 0, nil, errors.Wrapf(ctx.Err(), "timed out port-forwarding to pod %s/%s", namespace, podName)
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil {
+		stop()
+		return 0, nil, errors.Wrapf(err, "failed to get the local port forwarded to pod %s/%s", namespace, podName)
+	}
+	if len(ports) == 0 {
+		stop()
+		return 0, nil, errors.Errorf("no local port forwarded to pod %s/%s", namespace, podName)
+	}
+	return int(ports[0].Local), stop, nil
+}
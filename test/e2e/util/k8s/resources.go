@@ -0,0 +1,39 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CountRestoredItems returns the number of resources of gvr in namespace.
+func CountRestoredItems(ctx context.Context, client TestClient, namespace string, gvr schema.GroupVersionResource) (int, error) {
+	dynamicClient, err := client.dynamicFactory.ClientForGroupVersionResource(gvr.GroupVersion(), metav1.APIResource{Name: gvr.Resource}, namespace)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get dynamic client for %s", gvr.String())
+	}
+
+	list, err := dynamicClient.List(metav1.ListOptions{})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to list %s in namespace %s", gvr.String(), namespace)
+	}
+	return len(list.Items), nil
+}
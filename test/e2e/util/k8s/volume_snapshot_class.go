@@ -0,0 +1,79 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// VolumeSnapshotClassSelectorLabel is the label Velero uses to select the VolumeSnapshotClass for a CSI driver.
+const VolumeSnapshotClassSelectorLabel = "velero.io/csi-volumesnapshot-class"
+
+var volumeSnapshotClassGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotclasses"}
+
+// EnsureVolumeSnapshotClass makes sure a VolumeSnapshotClass with the given name exists for driver and is
+// labeled for Velero to select it. An existing class with the same name is labeled if needed; it's an error
+// if it belongs to a different driver.
+func EnsureVolumeSnapshotClass(ctx context.Context, client TestClient, name, driver string, deletionPolicy string) error {
+	dynamicClient, err := client.dynamicFactory.ClientForGroupVersionResource(volumeSnapshotClassGVR.GroupVersion(), metav1.APIResource{Name: volumeSnapshotClassGVR.Resource}, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to get dynamic client for volumesnapshotclasses")
+	}
+
+	class := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": volumeSnapshotClassGVR.GroupVersion().String(),
+		"kind":       "VolumeSnapshotClass",
+		"metadata": map[string]interface{}{
+			"name": name,
+			"labels": map[string]interface{}{
+				VolumeSnapshotClassSelectorLabel: "true",
+			},
+		},
+		"driver":         driver,
+		"deletionPolicy": deletionPolicy,
+	}}
+	if _, err := dynamicClient.Create(class); err == nil {
+		fmt.Printf("Created VolumeSnapshotClass %s for driver %s\n", name, driver)
+		return nil
+	} else if !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "failed to create VolumeSnapshotClass %s", name)
+	}
+
+	existing, err := dynamicClient.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrapf(err, "failed to get VolumeSnapshotClass %s", name)
+	}
+	if existingDriver, _, _ := unstructured.NestedString(existing.Object, "driver"); existingDriver != driver {
+		return errors.Errorf("VolumeSnapshotClass %s already exists for driver %s, not %s", name, existingDriver, driver)
+	}
+	if existing.GetLabels()[VolumeSnapshotClassSelectorLabel] == "true" {
+		return nil
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"labels":{%q:"true"}}}`, VolumeSnapshotClassSelectorLabel)
+	if _, err := dynamicClient.Patch(name, []byte(patch)); err != nil {
+		return errors.Wrapf(err, "failed to label VolumeSnapshotClass %s", name)
+	}
+	return nil
+}
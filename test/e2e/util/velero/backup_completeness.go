@@ -0,0 +1,87 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/cmd/util/downloadrequest"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+const backupResourceListTimeout = time.Minute
+
+// VerifyBackupCompleteness checks that the backup named backupName in veleroNamespace contains every resource of
+// each of includedGVRs that currently exists in namespace, by comparing them against the resource list the backup
+// recorded. The resources missing from the backup are reported per GroupVersionResource.
+func VerifyBackupCompleteness(ctx context.Context, client TestClient, veleroNamespace, namespace string, includedGVRs []schema.GroupVersionResource, backupName string) error {
+	buf := new(bytes.Buffer)
+	if err := downloadrequest.Stream(ctx, client.Kubebuilder, veleroNamespace, backupName, velerov1api.DownloadTargetKindBackupResourceList, buf, backupResourceListTimeout, false, ""); err != nil {
+		return errors.Wrapf(err, "failed to download the resource list of backup %s", backupName)
+	}
+	resourceList := make(map[string][]string)
+	if err := json.NewDecoder(buf).Decode(&resourceList); err != nil {
+		return errors.Wrapf(err, "failed to decode the resource list of backup %s", backupName)
+	}
+
+	var discrepancies []string
+	for _, gvr := range includedGVRs {
+		gvk, err := client.Kubebuilder.RESTMapper().KindFor(gvr)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get the kind of %s", gvr.String())
+		}
+
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := client.Kubebuilder.List(ctx, list, kbclient.InNamespace(namespace)); err != nil {
+			return errors.Wrapf(err, "failed to list %s in namespace %s", gvr.String(), namespace)
+		}
+
+		backedUp := make(map[string]bool)
+		for _, entry := range resourceList[gvk.GroupVersion().String()+"/"+gvk.Kind] {
+			backedUp[entry] = true
+		}
+		var missing []string
+		for _, item := range list.Items {
+			if !backedUp[namespace+"/"+item.GetName()] {
+				missing = append(missing, item.GetName())
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			discrepancies = append(discrepancies, fmt.Sprintf("%s: %d of %d missing (%s)", gvr.String(), len(missing), len(list.Items), strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(discrepancies) > 0 {
+		return errors.Errorf("backup %s is missing resources from namespace %s: %s", backupName, namespace, strings.Join(discrepancies, "; "))
+	}
+	fmt.Printf("Backup %s contains every resource of namespace %s\n", backupName, namespace)
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	pkgbackup "github.com/vmware-tanzu/velero/pkg/backup"
+	"github.com/vmware-tanzu/velero/pkg/persistence"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// ExpireBackup sets the expiration of the backup named name in namespace to a time in the past, so that the
+// garbage collection controller deletes it the next time it checks the backup, without waiting for its TTL.
+// Backups have no status subresource, so the status is patched along with the rest of the object.
+func ExpireBackup(ctx context.Context, client TestClient, namespace, name string) error {
+	backup := &velerov1api.Backup{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: name}, backup); err != nil {
+		return errors.Wrapf(err, "failed to get backup %s", name)
+	}
+
+	patch := kbclient.MergeFrom(backup.DeepCopy())
+	backup.Status.Expiration = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+	if err := client.Kubebuilder.Patch(ctx, backup, patch); err != nil {
+		return errors.Wrapf(err, "failed to expire backup %s", name)
+	}
+	fmt.Printf("Expired backup %s\n", name)
+	return nil
+}
+
+// WaitForBackupRemoved waits until the backup named name in namespace has been deleted and there are no objects
+// left under its directory in its backup storage location, which store must already be initialized for.
+func WaitForBackupRemoved(ctx context.Context, client TestClient, store velero.ObjectStore, namespace, name string) error {
+	backup := &velerov1api.Backup{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: name}, backup); err != nil {
+		return errors.Wrapf(err, "failed to get backup %s", name)
+	}
+	bucket, backupDir, err := backupObjectsPrefix(ctx, client, backup)
+	if err != nil {
+		return err
+	}
+	return waitForBackupRemoved(ctx, client, store, backup, bucket, backupDir)
+}
+
+// backupObjectsPrefix returns the bucket and the prefix of the objects of backup in its backup storage location.
+func backupObjectsPrefix(ctx context.Context, client TestClient, backup *velerov1api.Backup) (string, string, error) {
+	location := &velerov1api.BackupStorageLocation{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: backup.Namespace, Name: backup.Spec.StorageLocation}, location); err != nil {
+		return "", "", errors.Wrapf(err, "failed to get backup storage location %s", backup.Spec.StorageLocation)
+	}
+	if location.Spec.ObjectStorage == nil {
+		return "", "", errors.Errorf("backup storage location %s has no object storage", location.Name)
+	}
+	backupDir := persistence.NewObjectStoreLayout(location.Spec.ObjectStorage.Prefix).GetBackupDir(backup.Name)
+	return location.Spec.ObjectStorage.Bucket, backupDir, nil
+}
+
+// waitForBackupRemoved waits until backup has been deleted and there are no objects left under backupDir in bucket.
+func waitForBackupRemoved(ctx context.Context, client TestClient, store velero.ObjectStore, backup *velerov1api.Backup, bucket, backupDir string) error {
+	name := backup.Name
+	err := wait.PollImmediateUntil(5*time.Second, func() (bool, error) {
+		err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: backup.Namespace, Name: name}, &velerov1api.Backup{})
+		if err == nil {
+			fmt.Printf("Backup %s still exists, waiting for it to be deleted\n", name)
+			return false, nil
+		}
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "failed to get backup %s", name)
+		}
+
+		keys, err := store.ListObjects(bucket, backupDir)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to list the objects of backup %s", name)
+		}
+		if len(keys) > 0 {
+			fmt.Printf("%d objects of backup %s are left in bucket %s, waiting for them to be deleted\n", len(keys), name, bucket)
+			return false, nil
+		}
+		return true, nil
+	}, ctx.Done())
+	return errors.Wrapf(err, "failed to wait for backup %s to be removed", name)
+}
+
+// DeleteBackupAndWait deletes the backup named backupName in namespace by creating a DeleteBackupRequest, then
+// waits up to timeout for both the backup and its objects in its backup storage location to be removed, which
+// store must already be initialized for. The errors the request reports are included if the wait fails.
+func DeleteBackupAndWait(ctx context.Context, client TestClient, store velero.ObjectStore, namespace, backupName string, timeout time.Duration) error {
+	backup := &velerov1api.Backup{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: backupName}, backup); err != nil {
+		return errors.Wrapf(err, "failed to get backup %s", backupName)
+	}
+	// resolve the location first, the backup can't be looked up once it's deleted
+	bucket, backupDir, err := backupObjectsPrefix(ctx, client, backup)
+	if err != nil {
+		return err
+	}
+
+	req := pkgbackup.NewDeleteBackupRequest(backup.Name, string(backup.UID))
+	req.Namespace = namespace
+	if err := client.Kubebuilder.Create(ctx, req); err != nil {
+		return errors.Wrapf(err, "failed to create DeleteBackupRequest for backup %s", backupName)
+	}
+	fmt.Printf("Created DeleteBackupRequest %s for backup %s\n", req.Name, backupName)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err = waitForBackupRemoved(waitCtx, client, store, backup, bucket, backupDir)
+	if err == nil {
+		return nil
+	}
+
+	if getErr := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: req.Name}, req); getErr == nil && len(req.Status.Errors) > 0 {
+		return errors.Wrapf(err, "DeleteBackupRequest %s is %s with errors: %s", req.Name, req.Status.Phase, strings.Join(req.Status.Errors, "; "))
+	}
+	return err
+}
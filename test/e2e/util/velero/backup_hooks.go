@@ -0,0 +1,147 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/cmd/util/downloadrequest"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// HookOutcome is the outcome of the exec hooks run on a pod during a backup.
+type HookOutcome string
+
+const (
+	// HookSucceeded means every exec hook run on the pod succeeded.
+	HookSucceeded HookOutcome = "Succeeded"
+	// HookFailed means at least one exec hook run on the pod failed.
+	HookFailed HookOutcome = "Failed"
+	// HookSkipped means no exec hook was run on the pod.
+	HookSkipped HookOutcome = "Skipped"
+)
+
+const (
+	backupLogTimeout = time.Minute
+
+	// these are the messages the backup logs for each exec hook it runs on a pod, and for each that fails
+	hookRunMessage    = "running exec hook"
+	hookFailedMessage = "Error executing hook"
+)
+
+// GetBackupHookOutcome reports whether the exec hooks of the backup named backupName ran on the pod named podName in
+// namespace, and whether they succeeded, according to the backup's log. If sentinelFile isn't empty, it's the path
+// of a file the hooks create in container of the pod, and a hook reported to have succeeded is only trusted if the
+// file exists, while a hook reported to have been skipped must not have created it.
+func GetBackupHookOutcome(ctx context.Context, client TestClient, veleroNamespace, backupName, namespace, podName, container, sentinelFile string) (HookOutcome, error) {
+	buf := new(bytes.Buffer)
+	if err := downloadrequest.Stream(ctx, client.Kubebuilder, veleroNamespace, backupName, velerov1api.DownloadTargetKindBackupLog, buf, backupLogTimeout, false, ""); err != nil {
+		return "", errors.Wrapf(err, "failed to download the log of backup %s", backupName)
+	}
+
+	outcome := HookSkipped
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := parseLogEntry(scanner.Text())
+		if entry["namespace"] != namespace || entry["name"] != podName {
+			continue
+		}
+		switch entry["msg"] {
+		case hookRunMessage:
+			if outcome == HookSkipped {
+				outcome = HookSucceeded
+			}
+		case hookFailedMessage:
+			fmt.Printf("%s hook of backup %s failed on pod %s: %s\n", entry["hookPhase"], backupName, podName, entry["error"])
+			outcome = HookFailed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", errors.Wrapf(err, "failed to read the log of backup %s", backupName)
+	}
+
+	if sentinelFile == "" || outcome == HookFailed {
+		return outcome, nil
+	}
+	_, err := ExecInPod(ctx, namespace, podName, container, "test", "-e", sentinelFile)
+	created := err == nil
+	switch {
+	case outcome == HookSucceeded && !created:
+		return outcome, errors.Errorf("hooks of backup %s succeeded on pod %s but didn't create %s", backupName, podName, sentinelFile)
+	case outcome == HookSkipped && created:
+		return outcome, errors.Errorf("hooks of backup %s didn't run on pod %s but %s exists", backupName, podName, sentinelFile)
+	}
+	return outcome, nil
+}
+
+// parseLogEntry returns the fields of a line of a Velero log, which is either a JSON object or logfmt-formatted
+// depending on the server's log format. Only string values are returned.
+func parseLogEntry(line string) map[string]string {
+	entry := make(map[string]string)
+	if strings.HasPrefix(line, "{") {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return entry
+		}
+		for key, value := range fields {
+			if s, ok := value.(string); ok {
+				entry[key] = s
+			}
+		}
+		// JSON logs nest the error message under error.message
+		if message, ok := entry["error.message"]; ok {
+			entry["error"] = message
+		}
+		return entry
+	}
+
+	for rest := strings.TrimSpace(line); rest != ""; rest = strings.TrimSpace(rest) {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				break
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		} else if space := strings.IndexByte(rest, ' '); space >= 0 {
+			value, rest = rest[:space], rest[space:]
+		} else {
+			value, rest = rest, ""
+		}
+		entry[key] = value
+	}
+	return entry
+}
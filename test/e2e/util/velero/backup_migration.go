@@ -0,0 +1,113 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware-tanzu/velero/pkg/persistence"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	osv2 "github.com/vmware-tanzu/velero/pkg/plugin/velero/objectstore/v2"
+)
+
+// MigrateBackup copies the objects of the backup named backupName from bucket in srcStore to bucket in dstStore,
+// which must both already be initialized for backup storage locations without a prefix, then checks that
+// dstStore has as many objects for the backup as srcStore. Objects already in dstStore with the same size are
+// skipped, so a migration that failed part way can be resumed by calling MigrateBackup again. Server-side copies
+// only work within a bucket of a single object store, so each object is streamed from srcStore to dstStore.
+func MigrateBackup(ctx context.Context, srcStore, dstStore velero.ObjectStore, bucket, backupName string) error {
+	backupDir := persistence.NewObjectStoreLayout("").GetBackupDir(backupName)
+	keys, err := srcStore.ListObjects(bucket, backupDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the objects of backup %s", backupName)
+	}
+	if len(keys) == 0 {
+		return errors.Errorf("backup %s has no objects in bucket %s", backupName, bucket)
+	}
+
+	copied := 0
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrapf(err, "migration of backup %s interrupted after %d objects", backupName, copied)
+		}
+
+		migrated, err := objectMigrated(ctx, srcStore, dstStore, bucket, key)
+		if err != nil {
+			return err
+		}
+		if migrated {
+			continue
+		}
+
+		if err := copyObject(srcStore, dstStore, bucket, key); err != nil {
+			return err
+		}
+		copied++
+	}
+
+	dstKeys, err := dstStore.ListObjects(bucket, backupDir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list the migrated objects of backup %s", backupName)
+	}
+	if len(dstKeys) != len(keys) {
+		return errors.Errorf("backup %s has %d objects after migration, expected %d", backupName, len(dstKeys), len(keys))
+	}
+	fmt.Printf("Migrated backup %s: copied %d of its %d objects\n", backupName, copied, len(keys))
+	return nil
+}
+
+// objectMigrated returns whether the object with the given key has already been copied from srcStore to dstStore.
+// Objects are written whole, so one that exists in dstStore is complete; its size is compared too where both
+// object stores can report it, in case the source object was rewritten since.
+func objectMigrated(ctx context.Context, srcStore, dstStore velero.ObjectStore, bucket, key string) (bool, error) {
+	exists, err := dstStore.ObjectExists(bucket, key)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to check whether object %s was migrated", key)
+	}
+	if !exists {
+		return false, nil
+	}
+
+	src, srcOK := srcStore.(osv2.ObjectStore)
+	dst, dstOK := dstStore.(osv2.ObjectStore)
+	if !srcOK || !dstOK {
+		return true, nil
+	}
+	srcInfo, srcErr := src.GetObjectInfoV2(ctx, bucket, key)
+	dstInfo, dstErr := dst.GetObjectInfoV2(ctx, bucket, key)
+	if srcErr != nil || dstErr != nil {
+		return true, nil
+	}
+	return srcInfo.Size == dstInfo.Size, nil
+}
+
+// copyObject streams the object with the given key from srcStore to dstStore.
+func copyObject(srcStore, dstStore velero.ObjectStore, bucket, key string) error {
+	body, err := srcStore.GetObject(bucket, key)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get object %s", key)
+	}
+	defer body.Close()
+
+	if err := dstStore.PutObject(bucket, key, body); err != nil {
+		return errors.Wrapf(err, "failed to copy object %s", key)
+	}
+	return nil
+}
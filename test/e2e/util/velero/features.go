@@ -0,0 +1,77 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// SetVeleroFeatureFlags replaces the feature flags the Velero server in namespace runs with by flags, clearing
+// them if flags is empty, and waits for the server to be restarted with them.
+func SetVeleroFeatureFlags(ctx context.Context, client TestClient, namespace string, flags []string) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, err := client.ClientGo.AppsV1().Deployments(namespace).Get(ctx, "velero", metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		containers := deployment.Spec.Template.Spec.Containers
+		for i := range containers {
+			if containers[i].Name != "velero" {
+				continue
+			}
+			containers[i].Args = withFeatures(containers[i].Args, flags)
+			_, err = client.ClientGo.AppsV1().Deployments(namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+			return err
+		}
+		return errors.Errorf("deployment %s/velero has no velero container", namespace)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set the feature flags of Velero in namespace %s", namespace)
+	}
+
+	fmt.Printf("Set the feature flags of Velero in namespace %s to %v, waiting for it to restart\n", namespace, flags)
+	return WaitForDeploymentAvailable(ctx, client, namespace, "velero")
+}
+
+// withFeatures returns args with any --features flag replaced by one enabling flags.
+func withFeatures(args []string, flags []string) []string {
+	res := make([]string, 0, len(args)+1)
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--features" {
+			// the value is the next argument
+			i++
+			continue
+		}
+		if strings.HasPrefix(args[i], "--features=") {
+			continue
+		}
+		res = append(res, args[i])
+	}
+	if len(flags) > 0 {
+		res = append(res, "--features="+strings.Join(flags, ","))
+	}
+	return res
+}
@@ -123,11 +123,11 @@ func configvSpherePlugin() error {
 	if err := WaitForSecretsComplete(cli.ClientGo, VeleroCfg.VeleroNamespace, vsphereSecret); err != nil {
 		return errors.Wrap(err, "Failed to ensure velero-vsphere-config-secret secret completion in namespace kube-system")
 	}
-	_, err = CreateConfigMap(cli.ClientGo, VeleroCfg.VeleroNamespace, configmaptName, map[string]string{
+	err = CreateConfigMap(context.Background(), cli, VeleroCfg.VeleroNamespace, configmaptName, map[string]string{
 		"cluster_flavor":           "VANILLA",
 		"vsphere_secret_name":      vsphereSecret,
 		"vsphere_secret_namespace": VeleroCfg.VeleroNamespace,
-	})
+	}, nil)
 	if err != nil {
 		return errors.WithMessagef(err, "Failed to create velero-vsphere-plugin-config configmap in %s namespace", VeleroCfg.VeleroNamespace)
 	}
@@ -0,0 +1,129 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	corev1api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// veleroMetricsPort is the port the Velero server exposes Prometheus metrics on by default.
+const veleroMetricsPort = 8085
+
+// ScrapeVeleroMetrics port-forwards to the metrics port of the running Velero server pod in namespace and
+// returns the metrics it exports, keyed by MetricKey. Histograms and summaries are flattened into their
+// _sum and _count series.
+func ScrapeVeleroMetrics(ctx context.Context, client TestClient, namespace string) (map[string]float64, error) {
+	pods, err := client.ClientGo.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(VeleroPodSelector).String(),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list Velero pods in namespace %s", namespace)
+	}
+	var podName string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1api.PodRunning {
+			podName = pod.Name
+			break
+		}
+	}
+	if podName == "" {
+		return nil, errors.Errorf("no running Velero pod found in namespace %s", namespace)
+	}
+
+	localPort, stop, err := PortForward(ctx, client, namespace, podName, veleroMetricsPort)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/metrics", localPort), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to scrape metrics of pod %s/%s", namespace, podName)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to scrape metrics of pod %s/%s: %s", namespace, podName, resp.Status)
+	}
+
+	families, err := new(expfmt.TextParser).TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse metrics of pod %s/%s", namespace, podName)
+	}
+
+	metrics := make(map[string]float64)
+	for name, family := range families {
+		for _, metric := range family.Metric {
+			metricLabels := make(map[string]string, len(metric.Label))
+			for _, label := range metric.Label {
+				metricLabels[label.GetName()] = label.GetValue()
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				metrics[MetricKey(name, metricLabels)] = metric.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				metrics[MetricKey(name, metricLabels)] = metric.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				metrics[MetricKey(name+"_sum", metricLabels)] = metric.GetHistogram().GetSampleSum()
+				metrics[MetricKey(name+"_count", metricLabels)] = float64(metric.GetHistogram().GetSampleCount())
+			case dto.MetricType_SUMMARY:
+				metrics[MetricKey(name+"_sum", metricLabels)] = metric.GetSummary().GetSampleSum()
+				metrics[MetricKey(name+"_count", metricLabels)] = float64(metric.GetSummary().GetSampleCount())
+			default:
+				metrics[MetricKey(name, metricLabels)] = metric.GetUntyped().GetValue()
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// MetricKey returns the key of the series with the given name and labels in the map returned by
+// ScrapeVeleroMetrics, in the Prometheus text format, e.g. `velero_backup_success_total{schedule=""}`.
+func MetricKey(name string, metricLabels map[string]string) string {
+	if len(metricLabels) == 0 {
+		return name
+	}
+
+	names := make([]string, 0, len(metricLabels))
+	for labelName := range metricLabels {
+		names = append(names, labelName)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, labelName := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labelName, metricLabels[labelName]))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
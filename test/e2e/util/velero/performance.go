@@ -0,0 +1,115 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/label"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// OperationPerformance records how long a completed backup or restore took, and how fast it moved data.
+type OperationPerformance struct {
+	Name string
+	// Duration is the wall-clock time between the start and completion timestamps in the status of the
+	// backup or restore.
+	Duration time.Duration
+	// TotalBytes is the total number of bytes of the pod volumes backed up or restored.
+	TotalBytes int64
+	// BytesPerSecond is TotalBytes divided by Duration, or zero if the duration is zero.
+	BytesPerSecond float64
+}
+
+func (p OperationPerformance) String() string {
+	return fmt.Sprintf("%s: %d bytes in %s (%.0f bytes/s)", p.Name, p.TotalBytes, p.Duration, p.BytesPerSecond)
+}
+
+// GetBackupPerformance returns the duration and throughput of the completed backup named backupName. The
+// bytes transferred are the total bytes recorded in the status of the backup's pod volume backups.
+func GetBackupPerformance(ctx context.Context, client TestClient, veleroNamespace, backupName string) (*OperationPerformance, error) {
+	backup := &velerov1api.Backup{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: veleroNamespace, Name: backupName}, backup); err != nil {
+		return nil, errors.Wrapf(err, "failed to get backup %s", backupName)
+	}
+
+	podVolumeBackups := &velerov1api.PodVolumeBackupList{}
+	if err := client.Kubebuilder.List(ctx, podVolumeBackups, kbclient.InNamespace(veleroNamespace),
+		kbclient.MatchingLabels{velerov1api.BackupNameLabel: label.GetValidName(backupName)}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list pod volume backups of backup %s", backupName)
+	}
+	var totalBytes int64
+	for _, podVolumeBackup := range podVolumeBackups.Items {
+		totalBytes += podVolumeBackup.Status.Progress.TotalBytes
+	}
+
+	return newOperationPerformance("backup "+backupName, backup.Status.StartTimestamp, backup.Status.CompletionTimestamp, totalBytes)
+}
+
+// GetRestorePerformance returns the duration and throughput of the completed restore named restoreName. The
+// bytes transferred are the total bytes recorded in the status of the restore's pod volume restores.
+func GetRestorePerformance(ctx context.Context, client TestClient, veleroNamespace, restoreName string) (*OperationPerformance, error) {
+	restore := &velerov1api.Restore{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: veleroNamespace, Name: restoreName}, restore); err != nil {
+		return nil, errors.Wrapf(err, "failed to get restore %s", restoreName)
+	}
+
+	podVolumeRestores := &velerov1api.PodVolumeRestoreList{}
+	if err := client.Kubebuilder.List(ctx, podVolumeRestores, kbclient.InNamespace(veleroNamespace),
+		kbclient.MatchingLabels{velerov1api.RestoreNameLabel: label.GetValidName(restoreName)}); err != nil {
+		return nil, errors.Wrapf(err, "failed to list pod volume restores of restore %s", restoreName)
+	}
+	var totalBytes int64
+	for _, podVolumeRestore := range podVolumeRestores.Items {
+		totalBytes += podVolumeRestore.Status.Progress.TotalBytes
+	}
+
+	return newOperationPerformance("restore "+restoreName, restore.Status.StartTimestamp, restore.Status.CompletionTimestamp, totalBytes)
+}
+
+func newOperationPerformance(name string, start, completion *metav1.Time, totalBytes int64) (*OperationPerformance, error) {
+	if start == nil || completion == nil {
+		return nil, errors.Errorf("%s has not completed", name)
+	}
+
+	perf := &OperationPerformance{
+		Name:       name,
+		Duration:   completion.Sub(start.Time),
+		TotalBytes: totalBytes,
+	}
+	if perf.Duration > 0 {
+		perf.BytesPerSecond = float64(totalBytes) / perf.Duration.Seconds()
+	}
+	fmt.Println(perf.String())
+	return perf, nil
+}
+
+// ThroughputShouldBeAtLeast returns an error if perf moved data slower than minBytesPerSecond, so that tests
+// can fail on performance regressions.
+func ThroughputShouldBeAtLeast(perf *OperationPerformance, minBytesPerSecond float64) error {
+	if perf.BytesPerSecond < minBytesPerSecond {
+		return errors.Errorf("throughput of %s is below the expected minimum of %.0f bytes/s", perf, minBytesPerSecond)
+	}
+	return nil
+}
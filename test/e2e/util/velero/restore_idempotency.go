@@ -0,0 +1,60 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// VerifyRestoreIsIdempotent restores namespace from backupName twice, as restoreNamePrefix-1 and
+// restoreNamePrefix-2, and checks that the second restore leaves the resources of gvrs in namespace exactly as
+// the first one left them. Resources which already exist are skipped by the second restore with a warning, so
+// the returned error lists any resource that was nevertheless mutated, removed or duplicated.
+func VerifyRestoreIsIdempotent(ctx context.Context, client TestClient, veleroCLI, veleroNamespace, backupName, restoreNamePrefix, namespace string, gvrs []schema.GroupVersionResource) error {
+	firstRestore := restoreNamePrefix + "-1"
+	if err := VeleroRestore(ctx, veleroCLI, veleroNamespace, firstRestore, backupName); err != nil {
+		RunDebug(context.Background(), veleroCLI, veleroNamespace, "", firstRestore)
+		return errors.Wrapf(err, "failed to restore %s", firstRestore)
+	}
+	before, err := SnapshotClusterState(ctx, client, gvrs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to snapshot namespace %s after restore %s", namespace, firstRestore)
+	}
+
+	secondRestore := restoreNamePrefix + "-2"
+	if err := VeleroRestore(ctx, veleroCLI, veleroNamespace, secondRestore, backupName); err != nil {
+		RunDebug(context.Background(), veleroCLI, veleroNamespace, "", secondRestore)
+		return errors.Wrapf(err, "failed to restore %s", secondRestore)
+	}
+	after, err := SnapshotClusterState(ctx, client, gvrs)
+	if err != nil {
+		return errors.Wrapf(err, "failed to snapshot namespace %s after restore %s", namespace, secondRestore)
+	}
+
+	if diffs := before.InNamespace(namespace).Diff(after.InNamespace(namespace)); len(diffs) > 0 {
+		return errors.Errorf("restoring backup %s again changed namespace %s:\n%s", backupName, namespace, strings.Join(diffs, "\n"))
+	}
+	fmt.Printf("Restoring backup %s twice left namespace %s unchanged\n", backupName, namespace)
+	return nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/persistence"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	pkgrestore "github.com/vmware-tanzu/velero/pkg/restore"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// GetRestoreResults waits for the restore named restoreName in namespace to finish, then reads the warnings and
+// errors it recorded from its backup storage location through store, which must already be initialized for the
+// location. Messages about a namespace are prefixed with the namespace's name, e.g. "ns-1: could not restore".
+func GetRestoreResults(ctx context.Context, client TestClient, store velero.ObjectStore, namespace, restoreName string) (warnings, errs []string, err error) {
+	restore := &velerov1api.Restore{}
+	err = wait.PollImmediateUntil(5*time.Second, func() (bool, error) {
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: restoreName}, restore); err != nil {
+			return false, errors.Wrapf(err, "failed to get restore %s", restoreName)
+		}
+		switch restore.Status.Phase {
+		case velerov1api.RestorePhaseCompleted, velerov1api.RestorePhasePartiallyFailed, velerov1api.RestorePhaseFailed:
+			return true, nil
+		}
+		fmt.Printf("Restore %s is in phase %s, waiting for it to finish\n", restoreName, restore.Status.Phase)
+		return false, nil
+	}, ctx.Done())
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to wait for restore %s to finish", restoreName)
+	}
+
+	backup := &velerov1api.Backup{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: restore.Spec.BackupName}, backup); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get backup %s of restore %s", restore.Spec.BackupName, restoreName)
+	}
+	location := &velerov1api.BackupStorageLocation{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: namespace, Name: backup.Spec.StorageLocation}, location); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get backup storage location %s", backup.Spec.StorageLocation)
+	}
+	if location.Spec.ObjectStorage == nil {
+		return nil, nil, errors.Errorf("backup storage location %s has no object storage", location.Name)
+	}
+
+	key := persistence.NewObjectStoreLayout(location.Spec.ObjectStorage.Prefix).GetRestoreResultsKey(restoreName)
+	rc, err := store.GetObject(location.Spec.ObjectStorage.Bucket, key)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get results of restore %s", restoreName)
+	}
+	defer rc.Close()
+
+	gzr, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to decompress results of restore %s", restoreName)
+	}
+	defer gzr.Close()
+
+	results := make(map[string]pkgrestore.Result)
+	if err := json.NewDecoder(gzr).Decode(&results); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to decode results of restore %s", restoreName)
+	}
+	return restoreResultMessages(results["warnings"]), restoreResultMessages(results["errors"]), nil
+}
+
+// restoreResultMessages flattens result into a list of messages, prefixing those about a namespace with its name.
+func restoreResultMessages(result pkgrestore.Result) []string {
+	messages := append([]string{}, result.Velero...)
+	messages = append(messages, result.Cluster...)
+
+	namespaces := make([]string, 0, len(result.Namespaces))
+	for ns := range result.Namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		for _, message := range result.Namespaces[ns] {
+			messages = append(messages, ns+": "+message)
+		}
+	}
+	return messages
+}
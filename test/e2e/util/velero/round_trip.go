@@ -0,0 +1,108 @@
+/*
+Copyright the Velero contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package velero
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/builder"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
+)
+
+// RoundTripRestore restores the backup named backupName into targetNS, which must not exist yet, and waits up to
+// timeout for the restore to complete. The backup must include exactly one namespace, which is mapped to
+// targetNS. The restore's warning and error counts are returned. targetNS is deleted once the restore has
+// completed, and is left behind for debugging otherwise.
+func RoundTripRestore(ctx context.Context, client TestClient, backupName, targetNS string, timeout time.Duration) (warnings, errs int, err error) {
+	backup, err := getBackupByName(ctx, client, backupName)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(backup.Spec.IncludedNamespaces) != 1 || backup.Spec.IncludedNamespaces[0] == "*" {
+		return 0, 0, errors.Errorf("backup %s must include exactly one namespace to be restored into %s, it includes %v",
+			backupName, targetNS, backup.Spec.IncludedNamespaces)
+	}
+	sourceNS := backup.Spec.IncludedNamespaces[0]
+
+	if _, err := GetNamespace(ctx, client, targetNS); err == nil {
+		return 0, 0, errors.Errorf("namespace %s already exists", targetNS)
+	} else if !apierrors.IsNotFound(err) {
+		return 0, 0, errors.Wrapf(err, "failed to get namespace %s", targetNS)
+	}
+
+	restore := builder.ForRestore(backup.Namespace, fmt.Sprintf("%s-%s", backupName, targetNS)).
+		Backup(backupName).
+		IncludedNamespaces(sourceNS).
+		NamespaceMappings(sourceNS, targetNS).
+		Result()
+	if err := client.Kubebuilder.Create(ctx, restore); err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to create restore %s", restore.Name)
+	}
+	fmt.Printf("Created restore %s of backup %s into namespace %s\n", restore.Name, backupName, targetNS)
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err = wait.PollImmediateUntil(5*time.Second, func() (bool, error) {
+		if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKeyFromObject(restore), restore); err != nil {
+			return false, errors.Wrapf(err, "failed to get restore %s", restore.Name)
+		}
+		switch restore.Status.Phase {
+		case velerov1api.RestorePhaseCompleted, velerov1api.RestorePhasePartiallyFailed,
+			velerov1api.RestorePhaseFailed, velerov1api.RestorePhaseFailedValidation:
+			return true, nil
+		}
+		fmt.Printf("Restore %s is %s, waiting for it to finish\n", restore.Name, restore.Status.Phase)
+		return false, nil
+	}, waitCtx.Done())
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to wait for restore %s to finish", restore.Name)
+	}
+
+	warnings, errs = restore.Status.Warnings, restore.Status.Errors
+	if restore.Status.Phase != velerov1api.RestorePhaseCompleted {
+		return warnings, errs, errors.Errorf("restore %s is %s with %d warnings and %d errors, expected %s",
+			restore.Name, restore.Status.Phase, warnings, errs, velerov1api.RestorePhaseCompleted)
+	}
+	fmt.Printf("Restore %s completed with %d warnings and %d errors\n", restore.Name, warnings, errs)
+
+	if err := DeleteNamespace(ctx, client, targetNS, true); err != nil {
+		return warnings, errs, err
+	}
+	return warnings, errs, nil
+}
+
+// getBackupByName returns the backup named name, in whichever namespace Velero is installed in.
+func getBackupByName(ctx context.Context, client TestClient, name string) (*velerov1api.Backup, error) {
+	backups := &velerov1api.BackupList{}
+	if err := client.Kubebuilder.List(ctx, backups); err != nil {
+		return nil, errors.Wrap(err, "failed to list backups")
+	}
+	for i := range backups.Items {
+		if backups.Items[i].Name == name {
+			return &backups.Items[i], nil
+		}
+	}
+	return nil, errors.Errorf("backup %s not found", name)
+}
@@ -34,13 +34,16 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
+	kbclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	velerov1api "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	cliinstall "github.com/vmware-tanzu/velero/pkg/cmd/cli/install"
 	"github.com/vmware-tanzu/velero/pkg/cmd/util/flag"
 	veleroexec "github.com/vmware-tanzu/velero/pkg/util/exec"
 	common "github.com/vmware-tanzu/velero/test/e2e/util/common"
+	. "github.com/vmware-tanzu/velero/test/e2e/util/k8s"
 )
 
 const BackupObjectsPrefix = "backups"
@@ -329,6 +332,22 @@ func VeleroCmdExec(ctx context.Context, veleroCLI string, args []string) error {
 	return err
 }
 
+// RunVeleroJSON runs the velero CLI with args and "-o json", and unmarshals its output into out, so that
+// tests can make assertions on the CLI's structured output instead of scraping text. The raw output is
+// included in the error if it can't be unmarshaled.
+func RunVeleroJSON(ctx context.Context, veleroCLI string, args []string, out interface{}) error {
+	cmd := exec.CommandContext(ctx, veleroCLI, append(append([]string{}, args...), "-o", "json")...)
+	fmt.Printf("velero cmd =%v\n", cmd)
+	stdout, stderr, err := veleroexec.RunCommand(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "failed to run velero %s: %s", strings.Join(args, " "), stderr)
+	}
+	if err := json.Unmarshal([]byte(stdout), out); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal the output of velero %s: %s", strings.Join(args, " "), stdout)
+	}
+	return nil
+}
+
 func VeleroBackupLogs(ctx context.Context, veleroCLI string, veleroNamespace string, backupName string) error {
 	args := []string{
 		"--namespace", veleroNamespace, "backup", "describe", backupName,
@@ -797,3 +816,32 @@ func GetResticRepositories(ctx context.Context, veleroNamespace, targetNamespace
 
 	return common.GetListBy2Pipes(ctx, *CmdLine1, *CmdLine2, *CmdLine3)
 }
+
+// RestoredItemsCountShouldMatchBackup checks that the number of resources of gvrs restored into namespace
+// matches the number of items recorded in the status of the backup. The namespace itself is counted as one
+// item, as it is included in a backup of the namespace, so gvrs should cover every other kind of resource
+// in the backup.
+func RestoredItemsCountShouldMatchBackup(ctx context.Context, client TestClient, veleroNamespace, backupName, namespace string, gvrs []schema.GroupVersionResource) error {
+	backup := &velerov1api.Backup{}
+	if err := client.Kubebuilder.Get(ctx, kbclient.ObjectKey{Namespace: veleroNamespace, Name: backupName}, backup); err != nil {
+		return errors.Wrapf(err, "failed to get backup %s", backupName)
+	}
+	if backup.Status.Progress == nil {
+		return errors.Errorf("backup %s has no progress recorded in its status", backupName)
+	}
+
+	restored := 1
+	for _, gvr := range gvrs {
+		count, err := CountRestoredItems(ctx, client, namespace, gvr)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d %s restored in namespace %s\n", count, gvr.String(), namespace)
+		restored += count
+	}
+
+	if restored != backup.Status.Progress.ItemsBackedUp {
+		return errors.Errorf("restored items count %d in namespace %s is not as expected %d from backup %s", restored, namespace, backup.Status.Progress.ItemsBackedUp, backupName)
+	}
+	return nil
+}